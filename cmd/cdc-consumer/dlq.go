@@ -0,0 +1,280 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/pool"
+	"github.com/sparkiss/pos-cdc/internal/processor"
+	"github.com/sparkiss/pos-cdc/internal/schema"
+	"github.com/sparkiss/pos-cdc/internal/writer"
+	"github.com/sparkiss/pos-cdc/pkg/logger"
+)
+
+const dlqUsage = `Usage: cdc-consumer dlq <command> [flags]
+
+Commands:
+  list     List entries, most recent last
+  stats    Count entries by table, operation, reason and error
+  show     Print a single entry by its index
+  replay   Re-apply DLQ entries and keep only those that still fail
+
+Run 'cdc-consumer dlq <command> -h' for the command's flags.
+`
+
+// runDLQ handles the "dlq" subcommand and returns the process exit code.
+func runDLQ(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, dlqUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "list":
+		return runDLQList(args[1:])
+	case "stats":
+		return runDLQStats(args[1:])
+	case "show":
+		return runDLQShow(args[1:])
+	case "replay":
+		return runDLQReplay(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown dlq command %q\n\n%s", args[0], dlqUsage)
+		return 2
+	}
+}
+
+// dlqFilterFlags registers the entry filter flags shared by dlq commands.
+func dlqFilterFlags(fs *flag.FlagSet) func() (pool.DLQFilter, error) {
+	table := fs.String("table", "", "only entries for this table")
+	op := fs.String("op", "", "only entries with this operation (c, u, d or INSERT, UPDATE, DELETE)")
+	errContains := fs.String("error", "", "only entries whose error contains this text")
+	since := fs.String("since", "", "only entries dead-lettered at or after this time (RFC3339)")
+	until := fs.String("until", "", "only entries dead-lettered at or before this time (RFC3339)")
+
+	return func() (pool.DLQFilter, error) {
+		filter := pool.DLQFilter{Table: *table, Op: *op, ErrorContains: *errContains}
+		var err error
+		if *since != "" {
+			if filter.Since, err = time.Parse(time.RFC3339, *since); err != nil {
+				return filter, fmt.Errorf("invalid -since: %w", err)
+			}
+		}
+		if *until != "" {
+			if filter.Until, err = time.Parse(time.RFC3339, *until); err != nil {
+				return filter, fmt.Errorf("invalid -until: %w", err)
+			}
+		}
+		return filter, nil
+	}
+}
+
+func runDLQList(args []string) int {
+	fs := flag.NewFlagSet("dlq list", flag.ContinueOnError)
+	path := fs.String("file", config.DLQPathFromEnv(), "DLQ file to read, with its rotated files")
+	limit := fs.Int("limit", 50, "show only the most recent N matching entries (0 for all)")
+	parseFilter := dlqFilterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	filter, err := parseFilter()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	entries, err := pool.ReadDLQFiles(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read DLQ: %v\n", err)
+		return 1
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEX\tTIME\tTABLE\tOP\tREASON\tRETRIES\tERROR")
+	for _, e := range pool.FilterDLQ(entries, filter, *limit) {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			e.Index,
+			e.Timestamp.Format(time.RFC3339),
+			e.Event.SourceTable,
+			e.Event.GetOperation(),
+			e.Reason,
+			e.Retries,
+			truncate(e.Error, 80))
+	}
+	_ = tw.Flush()
+	return 0
+}
+
+func runDLQStats(args []string) int {
+	fs := flag.NewFlagSet("dlq stats", flag.ContinueOnError)
+	path := fs.String("file", config.DLQPathFromEnv(), "DLQ file to read, with its rotated files")
+	parseFilter := dlqFilterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	filter, err := parseFilter()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	entries, err := pool.ReadDLQFiles(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read DLQ: %v\n", err)
+		return 1
+	}
+
+	stats := pool.SummarizeDLQ(entries, filter)
+	fmt.Printf("Total: %d\n", stats.Total)
+	if stats.Oldest != nil {
+		fmt.Printf("Oldest: %s\nNewest: %s\n", stats.Oldest.Format(time.RFC3339), stats.Newest.Format(time.RFC3339))
+	}
+	printCounts("Table", stats.ByTable)
+	printCounts("Operation", stats.ByOperation)
+	printCounts("Reason", stats.ByReason)
+	printCounts("Error", stats.ByError)
+	return 0
+}
+
+func runDLQShow(args []string) int {
+	fs := flag.NewFlagSet("dlq show", flag.ContinueOnError)
+	path := fs.String("file", config.DLQPathFromEnv(), "DLQ file to read, with its rotated files")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Usage: cdc-consumer dlq show [flags] <index>\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	index, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid index %q\n", fs.Arg(0))
+		return 2
+	}
+
+	entries, err := pool.ReadDLQFiles(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read DLQ: %v\n", err)
+		return 1
+	}
+	if index < 1 || index > len(entries) {
+		fmt.Fprintf(os.Stderr, "no entry %d (DLQ has %d)\n", index, len(entries))
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(pool.IndexedEntry{Index: index, DLQEntry: entries[index-1]})
+	return 0
+}
+
+// printCounts prints a count table sorted by count, largest first.
+func printCounts(title string, counts map[string]int) {
+	keys := slices.Collect(maps.Keys(counts))
+	slices.SortFunc(keys, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+
+	fmt.Printf("\n%s:\n", title)
+	for _, k := range keys {
+		fmt.Printf("  %6d  %s\n", counts[k], truncate(k, 100))
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+func runDLQReplay(args []string) int {
+	fs := flag.NewFlagSet("dlq replay", flag.ContinueOnError)
+	path := fs.String("file", config.DLQPathFromEnv(), "DLQ file to replay, with its rotated files")
+	parseFilter := dlqFilterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Usage: cdc-consumer dlq replay [flags]\n\n"+
+			"Re-applies matching entries through the configured target and rewrites\n"+
+			"the file with the entries that still fail. Refuses to run while a\n"+
+			"consumer has the file open.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	filter, err := parseFilter()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	if err := logger.Init(cfg.LogLevel, cfg.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to init logger: %v\n", err)
+		return 1
+	}
+	defer logger.Sync()
+
+	dbWriter, err := newWriter(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to target: %v\n", err)
+		return 1
+	}
+	defer func() { _ = dbWriter.Close() }()
+
+	schemaCache := schema.New(dbWriter.DB(), cfg.TargetDatabase(), cfg.TargetType)
+	proc := processor.New(schemaCache, cfg.SourceLocation, cfg.TargetLocation, cfg.TargetType)
+	if cfg.UnknownColumns == config.UnknownColumnsDrop {
+		proc.DropUnknownColumns()
+	}
+
+	// Interrupting keeps the entries not yet replayed and still rewrites the file
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := pool.ReplayDLQ(ctx, *path, filter, proc, dbWriter)
+	fmt.Printf("entries: %d, matched: %d, replayed: %d, still failing: %d\n",
+		result.Total, result.Matched, result.Replayed, result.Failed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Replay failed: %v\n", err)
+		return 1
+	}
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// newWriter connects to the configured target database.
+func newWriter(cfg *config.Config) (writer.Writer, error) {
+	if cfg.TargetType == config.TargetPostgres {
+		if cfg.TargetPG.Pipeline {
+			return writer.NewPgx(cfg)
+		}
+		return writer.NewPostgres(cfg)
+	}
+	return writer.NewMySQL(cfg)
+}
@@ -25,6 +25,9 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "dlq" {
+		os.Exit(runDLQ(os.Args[2:]))
+	}
 
 	cfg, err := config.Load()
 	if err != nil {
@@ -41,6 +44,8 @@ func main() {
 	logger.Log.Info("CDC Consumer starting",
 		zap.String("log_level", cfg.LogLevel),
 		zap.String("target_type", string(cfg.TargetType)),
+		zap.String("delivery_mode", string(cfg.DeliveryMode)),
+		zap.String("routing_mode", string(cfg.RoutingMode)),
 		zap.String("source_tz", cfg.SourceTimezone),
 		zap.String("target_tz", cfg.TargetTimezone))
 
@@ -55,7 +60,11 @@ func main() {
 	// Create database writer based on target type
 	var dbWriter writer.Writer
 	if cfg.TargetType == config.TargetPostgres {
-		dbWriter, err = writer.NewPostgres(cfg)
+		if cfg.TargetPG.Pipeline {
+			dbWriter, err = writer.NewPgx(cfg)
+		} else {
+			dbWriter, err = writer.NewPostgres(cfg)
+		}
 		if err != nil {
 			logger.Log.Fatal("Failed to connect to PostgreSQL", zap.Error(err))
 		}
@@ -75,11 +84,80 @@ func main() {
 	}
 	defer func() { _ = dbWriter.Close() }()
 
+	// Exactly-once: offsets live in the target database next to the data
+	var offsetStore writer.OffsetStore
+	if cfg.ExactlyOnce() {
+		store, ok := dbWriter.(writer.OffsetStore)
+		if !ok {
+			logger.Log.Fatal("Writer does not support stored offsets")
+		}
+		if err := store.EnsureOffsetsTable(); err != nil {
+			logger.Log.Fatal("Failed to prepare offsets table", zap.Error(err))
+		}
+		offsetStore = store
+	}
+
 	schemaCache := schema.New(dbWriter.DB(), cfg.TargetDatabase(), cfg.TargetType)
 	proc := processor.New(schemaCache, cfg.SourceLocation, cfg.TargetLocation, cfg.TargetType)
+	if cfg.UnknownColumns == config.UnknownColumnsDrop {
+		proc.DropUnknownColumns()
+	}
 
 	// Create worker pool
 	workerPool := pool.New(cfg.WorkerCount, cfg.BatchSize, proc, dbWriter)
+	workerPool.UseBuildErrorPolicy(cfg.BuildErrorActionFor)
+	if cfg.RoutingMode == config.RoutingKey {
+		workerPool.UseKeyRouting()
+	}
+	if cfg.CoalesceEvents {
+		workerPool.UseCoalescing()
+	}
+	if cfg.MultiRowInserts {
+		workerPool.UseMultiRowInserts()
+	}
+	if cfg.BulkLoadMinRows > 0 {
+		workerPool.UseBulkLoad(cfg.BulkLoadBatchSize)
+	}
+
+	// Dead-lettered events go to the local file unless a Kafka topic is
+	// configured, and to the file when publishing to the topic fails
+	fileSink, err := pool.NewFileSink(cfg.DLQPath, pool.RotationPolicy{
+		MaxBytes:   int64(cfg.DLQMaxSizeMB) << 20,
+		MaxAge:     time.Duration(cfg.DLQRotateHours) * time.Hour,
+		Retention:  time.Duration(cfg.DLQRetentionDays) * 24 * time.Hour,
+		MaxBackups: cfg.DLQMaxFiles,
+	})
+	if err != nil {
+		logger.Log.Fatal("Failed to open DLQ file", zap.String("path", cfg.DLQPath), zap.Error(err))
+	}
+	if cfg.DLQSink == config.DLQSinkKafka {
+		sink, err := pool.DialKafkaSink(cfg.KafkaBrokers, cfg.DLQTopic)
+		if err != nil {
+			logger.Log.Fatal("Failed to create DLQ producer", zap.Error(err))
+		}
+		workerPool.UseDLQ(pool.NewDLQWithFallback(sink, fileSink))
+		logger.Log.Info("Publishing dead-lettered events to Kafka", zap.String("topic", cfg.DLQTopic))
+	} else {
+		workerPool.UseDLQ(pool.NewDLQWithSink(fileSink))
+	}
+	// Failed events get delayed retries before they are dead-lettered
+	if cfg.RetryAttempts > 0 {
+		retryQueue, err := pool.NewRetryQueue(cfg.RetryPath, pool.RetryPolicy{
+			MaxAttempts: cfg.RetryAttempts,
+			BaseDelay:   time.Duration(cfg.RetryDelaySec) * time.Second,
+			MaxDelay:    time.Duration(cfg.RetryMaxDelaySec) * time.Second,
+		}, proc, dbWriter, workerPool.DLQ())
+		if err != nil {
+			logger.Log.Fatal("Failed to load retry queue", zap.String("path", cfg.RetryPath), zap.Error(err))
+		}
+		workerPool.UseRetryQueue(retryQueue)
+	}
+	if err := workerPool.DLQ().LoadStats(); err != nil {
+		logger.Log.Warn("Failed to count existing DLQ entries, /dlq counts new ones only",
+			zap.String("path", workerPool.DLQ().Path()),
+			zap.Error(err))
+	}
+	healthServer.SetDLQ(workerPool.DLQ())
 
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
@@ -98,6 +176,11 @@ func main() {
 	}
 	defer func() { _ = kafkaConsumer.Close() }()
 
+	if offsetStore != nil {
+		kafkaConsumer.UseStoredOffsets(offsetStore)
+	}
+	kafkaConsumer.UseDeadLetter(workerPool.DeadLetter)
+
 	healthServer.UpdateCheck("kafka", health.CheckResult{
 		Healthy: true,
 		Message: "Connected",
@@ -155,12 +238,17 @@ func main() {
 		zap.Int("health_port", cfg.HealthPort),
 		zap.Int("metrics_port", cfg.MetricsPort))
 
-	// Wait for shutdown signal
+	// Wait for shutdown signal, or for the pool to halt on an event
 	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
-	<-sigterm
 
-	logger.Log.Info("Shutdown signal received, draining...")
+	var haltErr error
+	select {
+	case <-sigterm:
+		logger.Log.Info("Shutdown signal received, draining...")
+	case haltErr = <-workerPool.Halted():
+		logger.Log.Error("Worker pool halted, shutting down", zap.Error(haltErr))
+	}
 
 	// Mark as not ready (stop accepting new work)
 	healthServer.SetReady(false)
@@ -184,6 +272,7 @@ func main() {
 
 	// Stop worker pool (drain queues and wait for workers)
 	workerPool.Stop()
+	workerPool.DLQ().Close()
 
 	// Stop health server
 	if err := healthServer.Stop(); err != nil {
@@ -192,4 +281,8 @@ func main() {
 
 	logger.Log.Info("CDC Consumer stopped")
 
+	if haltErr != nil {
+		logger.Sync()
+		os.Exit(1)
+	}
 }
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
@@ -19,6 +21,100 @@ const (
 	TargetPostgres TargetType = "postgres"
 )
 
+// DeliveryMode controls how consumed Kafka offsets are tracked
+type DeliveryMode string
+
+const (
+	// DeliveryAtLeastOnce commits offsets to the consumer group after the target write
+	DeliveryAtLeastOnce DeliveryMode = "at-least-once"
+	// DeliveryExactlyOnce stores offsets in the target database, in the same
+	// transaction as each batch, and seeks to them on startup
+	DeliveryExactlyOnce DeliveryMode = "exactly-once"
+)
+
+// RoutingMode controls how events are spread across workers
+type RoutingMode string
+
+const (
+	// RoutingPartition sends each topic partition to one worker, keeping
+	// every event of a table in order
+	RoutingPartition RoutingMode = "partition"
+	// RoutingKey sends each row (table plus primary key) to one worker, so a
+	// busy table is applied in parallel while changes to a row stay in order
+	RoutingKey RoutingMode = "key"
+)
+
+// TombstoneMode controls how Kafka tombstones (null-value messages) are handled
+type TombstoneMode string
+
+const (
+	// TombstoneIgnore skips tombstones; the preceding delete event is applied
+	TombstoneIgnore TombstoneMode = "ignore"
+	// TombstoneDelete applies tombstones as deletes using the message key
+	TombstoneDelete TombstoneMode = "delete"
+)
+
+// UnknownColumnPolicy controls payload columns missing from the target table
+type UnknownColumnPolicy string
+
+const (
+	// UnknownColumnsDrop applies the event without the unknown columns
+	UnknownColumnsDrop UnknownColumnPolicy = "drop"
+	// UnknownColumnsFail halts consuming until the target schema is fixed
+	UnknownColumnsFail UnknownColumnPolicy = "fail"
+	// UnknownColumnsDLQ sends the event to the dead letter queue
+	UnknownColumnsDLQ UnknownColumnPolicy = "dlq"
+)
+
+// reasonUnknownColumn is processor.ReasonUnknownColumn, the build error
+// reason the fail and dlq policies map to
+const reasonUnknownColumn = "unknown_column"
+
+// DefaultDLQPath is where the file DLQ sink writes unless DLQ_PATH is set
+var DefaultDLQPath = filepath.Join("var", "dlq", "dlq.jsonl")
+
+// DLQSinkType selects where dead-lettered events are written
+type DLQSinkType string
+
+const (
+	// DLQSinkFile appends entries to a local JSONL file
+	DLQSinkFile DLQSinkType = "file"
+	// DLQSinkKafka republishes the original messages to DLQTopic
+	DLQSinkKafka DLQSinkType = "kafka"
+)
+
+// MessageFormat is the shape of Debezium message values
+type MessageFormat string
+
+const (
+	// FormatUnwrapped is the ExtractNewRecordState SMT output with __op,
+	// __ts_ms, __source_db and __source_table fields
+	FormatUnwrapped MessageFormat = "unwrapped"
+	// FormatEnvelope is the raw Debezium envelope (before, after, source, op)
+	FormatEnvelope MessageFormat = "envelope"
+)
+
+// MessageEncoding is the serialization of Kafka keys and values
+type MessageEncoding string
+
+const (
+	EncodingJSON MessageEncoding = "json"
+	// EncodingAvro is Confluent wire-format Avro resolved via a schema registry
+	EncodingAvro MessageEncoding = "avro"
+)
+
+// FailureAction is what to do with an event that cannot be applied
+type FailureAction string
+
+const (
+	// FailureSkip drops the event after logging and counting it
+	FailureSkip FailureAction = "skip"
+	// FailureDLQ writes the event to the dead letter queue
+	FailureDLQ FailureAction = "dlq"
+	// FailureHalt stops consuming without committing the event's offset
+	FailureHalt FailureAction = "halt"
+)
+
 // Config holds all application configuration
 type Config struct {
 	// Target database selection
@@ -34,6 +130,65 @@ type Config struct {
 	KafkaBrokers         []string
 	KafkaGroupID         string
 	KafkaAutoOffsetReset string
+	DeliveryMode         DeliveryMode
+	RoutingMode          RoutingMode
+
+	// Topic selection
+	// Topics must start with KafkaTopicPrefix and match the include regex
+	// (if set) but not the exclude regex (if set). KafkaTopicTableMap
+	// overrides the table name derived from the topic.
+	KafkaTopicPrefix       string
+	KafkaTopicInclude      string
+	KafkaTopicIncludeRegex *regexp.Regexp
+	KafkaTopicExclude      string
+	KafkaTopicExcludeRegex *regexp.Regexp
+	KafkaTopicTableMap     map[string]string
+
+	// Shape of message values (unwrapped or envelope)
+	MessageFormat MessageFormat
+
+	// Serialization of keys and values (json or avro)
+	MessageEncoding        MessageEncoding
+	SchemaRegistryURL      string
+	SchemaRegistryUser     string
+	SchemaRegistryPassword string
+
+	// How to handle tombstones (ignore or delete)
+	TombstoneMode TombstoneMode
+
+	// How often to re-list topics and subscribe to new ones (0 disables)
+	TopicRefreshSec int
+
+	// What to do with events that fail SQL generation, by reason code
+	// (e.g. no_primary_key); reasons not listed use BuildErrorAction
+	BuildErrorAction  FailureAction
+	BuildErrorActions map[string]FailureAction
+
+	// What to do with payload columns missing from the target table
+	UnknownColumns UnknownColumnPolicy
+
+	// Where dead-lettered events go (file or kafka)
+	DLQSink  DLQSinkType
+	DLQTopic string
+
+	// File DLQ location and rotation. The file is rotated when it would grow
+	// past DLQMaxSizeMB or its oldest entry is DLQRotateHours old; rotated
+	// files are gzipped and deleted after DLQRetentionDays or beyond the
+	// newest DLQMaxFiles. Zero disables each limit.
+	DLQPath          string
+	DLQMaxSizeMB     int
+	DLQRotateHours   int
+	DLQRetentionDays int
+	DLQMaxFiles      int
+
+	// Events that fail to apply are retried after RetryDelaySec, doubling up
+	// to RetryMaxDelaySec, RetryAttempts times before going to the DLQ
+	// (0, the default, sends them straight there). Pending retries persist
+	// at RetryPath.
+	RetryAttempts    int
+	RetryDelaySec    int
+	RetryMaxDelaySec int
+	RetryPath        string
 
 	// Application behavior
 	LogLevel       string
@@ -43,6 +198,28 @@ type Config struct {
 	MaxRetries     int
 	RetryBackoffMS int
 
+	// Deadline for applying one batch, retries and backoff included, and
+	// for each statement in it. Zero disables either limit.
+	BatchTimeoutSec    int
+	StatementTimeoutMS int
+
+	// Merge consecutive changes to the same row within a batch
+	CoalesceEvents bool
+
+	// Combine consecutive inserts into one table into multi-row upserts
+	MultiRowInserts bool
+
+	// Prepared statements each writer keeps, least recently used evicted
+	// first. Zero disables the cache.
+	StmtCacheSize int
+
+	// Load runs of at least this many snapshot rows for one table with
+	// COPY and a single merge (PostgreSQL only). Zero disables it.
+	BulkLoadMinRows int
+	// With bulk loading, batches of only snapshot rows grow to this many
+	// events instead of BatchSize, so a snapshot is loaded in large COPYs
+	BulkLoadBatchSize int
+
 	// Tables to exclude from replication
 	ExcludedTables []string
 
@@ -76,6 +253,10 @@ type PGConfig struct {
 	Password string
 	Database string
 	SSLMode  string // disable, require, verify-ca, verify-full
+
+	// Pipeline applies batches on a native pgx pool, sending each batch's
+	// statements in one round trip, instead of through database/sql
+	Pipeline bool
 }
 
 // Load reads configuration from environment variables
@@ -101,21 +282,54 @@ func Load() (*Config, error) {
 			Password: getEnv("TARGET_PG_PASSWORD", ""),
 			Database: getEnv("TARGET_PG_DATABASE", "pos_replica"),
 			SSLMode:  getEnv("TARGET_PG_SSLMODE", "disable"),
+			Pipeline: getEnvBool("TARGET_PG_PIPELINE", false),
 		},
-		KafkaBrokers:         strings.Split(getEnv("KAFKA_BROKERS", "localhost:9092"), ","),
-		KafkaGroupID:         getEnv("KAFKA_GROUP_ID", "cdc-consumer-group"),
-		KafkaAutoOffsetReset: getEnv("KAFKA_AUTO_OFFSET_RESET", "earliest"),
-		LogLevel:             getEnv("LOG_LEVEL", "info"),
-		LogFormat:            getEnv("LOG_FORMAT", "text"),
-		WorkerCount:          getEnvInt("WORKER_COUNT", 4),
-		BatchSize:            getEnvInt("BATCH_SIZE", 100),
-		MaxRetries:           getEnvInt("MAX_RETRIES", 3),
-		RetryBackoffMS:       getEnvInt("RETRY_BACKOFF_MS", 1000),
-		ExcludedTables:       parseList(getEnv("EXCLUDED_TABLES", "")),
-		MetricsPort:          getEnvInt("METRICS_PORT", 9090),
-		HealthPort:           getEnvInt("HEALTH_PORT", 8081),
-		SourceTimezone:       getEnv("SOURCE_DB_TIMEZONE", "UTC"),
-		TargetTimezone:       getEnv("TARGET_DB_TIMEZONE", "UTC"),
+		KafkaBrokers:           strings.Split(getEnv("KAFKA_BROKERS", "localhost:9092"), ","),
+		KafkaGroupID:           getEnv("KAFKA_GROUP_ID", "cdc-consumer-group"),
+		KafkaAutoOffsetReset:   getEnv("KAFKA_AUTO_OFFSET_RESET", "earliest"),
+		DeliveryMode:           DeliveryMode(getEnv("DELIVERY_MODE", string(DeliveryAtLeastOnce))),
+		RoutingMode:            RoutingMode(getEnv("ROUTING_MODE", string(RoutingPartition))),
+		KafkaTopicPrefix:       getEnv("KAFKA_TOPIC_PREFIX", "pos_mysql.pos."),
+		KafkaTopicInclude:      getEnv("KAFKA_TOPIC_INCLUDE", ""),
+		KafkaTopicExclude:      getEnv("KAFKA_TOPIC_EXCLUDE", ""),
+		TopicRefreshSec:        getEnvInt("KAFKA_TOPIC_REFRESH_SEC", 60),
+		TombstoneMode:          TombstoneMode(getEnv("TOMBSTONE_MODE", string(TombstoneIgnore))),
+		BuildErrorAction:       FailureAction(getEnv("BUILD_ERROR_ACTION", string(FailureDLQ))),
+		UnknownColumns:         UnknownColumnPolicy(getEnv("UNKNOWN_COLUMNS", string(UnknownColumnsDLQ))),
+		DLQSink:                DLQSinkType(getEnv("DLQ_SINK", string(DLQSinkFile))),
+		DLQTopic:               getEnv("DLQ_TOPIC", "cdc.dlq"),
+		DLQPath:                getEnv("DLQ_PATH", DefaultDLQPath),
+		DLQMaxSizeMB:           getEnvInt("DLQ_MAX_SIZE_MB", 100),
+		DLQRotateHours:         getEnvInt("DLQ_ROTATE_HOURS", 24),
+		DLQRetentionDays:       getEnvInt("DLQ_RETENTION_DAYS", 30),
+		DLQMaxFiles:            getEnvInt("DLQ_MAX_FILES", 0),
+		RetryAttempts:          getEnvInt("RETRY_ATTEMPTS", 0),
+		RetryDelaySec:          getEnvInt("RETRY_DELAY_SEC", 30),
+		RetryMaxDelaySec:       getEnvInt("RETRY_MAX_DELAY_SEC", 600),
+		RetryPath:              getEnv("RETRY_PATH", filepath.Join("var", "retry", "retry.jsonl")),
+		MessageFormat:          MessageFormat(getEnv("MESSAGE_FORMAT", string(FormatUnwrapped))),
+		MessageEncoding:        MessageEncoding(getEnv("MESSAGE_ENCODING", string(EncodingJSON))),
+		SchemaRegistryURL:      getEnv("SCHEMA_REGISTRY_URL", ""),
+		SchemaRegistryUser:     getEnv("SCHEMA_REGISTRY_USER", ""),
+		SchemaRegistryPassword: getEnv("SCHEMA_REGISTRY_PASSWORD", ""),
+		LogLevel:               getEnv("LOG_LEVEL", "info"),
+		LogFormat:              getEnv("LOG_FORMAT", "text"),
+		WorkerCount:            getEnvInt("WORKER_COUNT", 4),
+		BatchSize:              getEnvInt("BATCH_SIZE", 100),
+		CoalesceEvents:         getEnvBool("COALESCE_EVENTS", false),
+		MultiRowInserts:        getEnvBool("MULTI_ROW_INSERTS", false),
+		BulkLoadMinRows:        getEnvInt("BULK_LOAD_MIN_ROWS", 0),
+		BulkLoadBatchSize:      getEnvInt("BULK_LOAD_BATCH_SIZE", 10000),
+		StmtCacheSize:          getEnvInt("STMT_CACHE_SIZE", 256),
+		MaxRetries:             getEnvInt("MAX_RETRIES", 3),
+		RetryBackoffMS:         getEnvInt("RETRY_BACKOFF_MS", 1000),
+		BatchTimeoutSec:        getEnvInt("BATCH_TIMEOUT_SEC", 60),
+		StatementTimeoutMS:     getEnvInt("STATEMENT_TIMEOUT_MS", 0),
+		ExcludedTables:         parseList(getEnv("EXCLUDED_TABLES", "")),
+		MetricsPort:            getEnvInt("METRICS_PORT", 9090),
+		HealthPort:             getEnvInt("HEALTH_PORT", 8081),
+		SourceTimezone:         getEnv("SOURCE_DB_TIMEZONE", "UTC"),
+		TargetTimezone:         getEnv("TARGET_DB_TIMEZONE", "UTC"),
 	}
 
 	// Validate target type
@@ -123,6 +337,113 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid TARGET_TYPE %q: must be 'mysql' or 'postgres'", cfg.TargetType)
 	}
 
+	// Validate delivery mode
+	if cfg.DeliveryMode != DeliveryAtLeastOnce && cfg.DeliveryMode != DeliveryExactlyOnce {
+		return nil, fmt.Errorf("invalid DELIVERY_MODE %q: must be 'at-least-once' or 'exactly-once'", cfg.DeliveryMode)
+	}
+
+	// Validate routing mode. Stored offsets are the highest offset of each
+	// batch, which is only safe while a partition is applied by one worker.
+	if cfg.RoutingMode != RoutingPartition && cfg.RoutingMode != RoutingKey {
+		return nil, fmt.Errorf("invalid ROUTING_MODE %q: must be 'partition' or 'key'", cfg.RoutingMode)
+	}
+	if cfg.RoutingMode == RoutingKey && cfg.DeliveryMode == DeliveryExactlyOnce {
+		return nil, fmt.Errorf("ROUTING_MODE=key is not supported with DELIVERY_MODE=exactly-once")
+	}
+
+	// Validate message format
+	if cfg.MessageFormat != FormatUnwrapped && cfg.MessageFormat != FormatEnvelope {
+		return nil, fmt.Errorf("invalid MESSAGE_FORMAT %q: must be 'unwrapped' or 'envelope'", cfg.MessageFormat)
+	}
+
+	// Validate message encoding
+	if cfg.MessageEncoding != EncodingJSON && cfg.MessageEncoding != EncodingAvro {
+		return nil, fmt.Errorf("invalid MESSAGE_ENCODING %q: must be 'json' or 'avro'", cfg.MessageEncoding)
+	}
+	if cfg.MessageEncoding == EncodingAvro && cfg.SchemaRegistryURL == "" {
+		return nil, fmt.Errorf("SCHEMA_REGISTRY_URL is required for avro encoding")
+	}
+
+	// Validate tombstone mode
+	if cfg.TombstoneMode != TombstoneIgnore && cfg.TombstoneMode != TombstoneDelete {
+		return nil, fmt.Errorf("invalid TOMBSTONE_MODE %q: must be 'ignore' or 'delete'", cfg.TombstoneMode)
+	}
+
+	// Validate build error handling
+	if !cfg.BuildErrorAction.valid() {
+		return nil, fmt.Errorf("invalid BUILD_ERROR_ACTION %q: must be 'skip', 'dlq' or 'halt'", cfg.BuildErrorAction)
+	}
+	actions, err := parseMap(getEnv("BUILD_ERROR_ACTIONS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid BUILD_ERROR_ACTIONS: %w", err)
+	}
+	cfg.BuildErrorActions = make(map[string]FailureAction, len(actions))
+	for reason, action := range actions {
+		if !FailureAction(action).valid() {
+			return nil, fmt.Errorf("invalid BUILD_ERROR_ACTIONS action %q for %s: must be 'skip', 'dlq' or 'halt'", action, reason)
+		}
+		cfg.BuildErrorActions[reason] = FailureAction(action)
+	}
+
+	// Validate unknown column policy. An explicit BUILD_ERROR_ACTIONS entry
+	// for unknown_column takes precedence.
+	switch cfg.UnknownColumns {
+	case UnknownColumnsDrop:
+	case UnknownColumnsFail:
+		if _, ok := cfg.BuildErrorActions[reasonUnknownColumn]; !ok {
+			cfg.BuildErrorActions[reasonUnknownColumn] = FailureHalt
+		}
+	case UnknownColumnsDLQ:
+		if _, ok := cfg.BuildErrorActions[reasonUnknownColumn]; !ok {
+			cfg.BuildErrorActions[reasonUnknownColumn] = FailureDLQ
+		}
+	default:
+		return nil, fmt.Errorf("invalid UNKNOWN_COLUMNS %q: must be 'drop', 'fail' or 'dlq'", cfg.UnknownColumns)
+	}
+
+	// Validate DLQ sink
+	if cfg.DLQSink != DLQSinkFile && cfg.DLQSink != DLQSinkKafka {
+		return nil, fmt.Errorf("invalid DLQ_SINK %q: must be 'file' or 'kafka'", cfg.DLQSink)
+	}
+	if cfg.DLQSink == DLQSinkKafka && cfg.DLQTopic == "" {
+		return nil, fmt.Errorf("DLQ_TOPIC is required for the kafka DLQ sink")
+	}
+	if cfg.DLQMaxSizeMB < 0 || cfg.DLQRotateHours < 0 || cfg.DLQRetentionDays < 0 || cfg.DLQMaxFiles < 0 {
+		return nil, fmt.Errorf("DLQ_MAX_SIZE_MB, DLQ_ROTATE_HOURS, DLQ_RETENTION_DAYS and DLQ_MAX_FILES must not be negative")
+	}
+
+	// Validate delayed retries
+	if cfg.RetryAttempts < 0 {
+		return nil, fmt.Errorf("invalid RETRY_ATTEMPTS %d: must not be negative", cfg.RetryAttempts)
+	}
+	if cfg.RetryAttempts > 0 && (cfg.RetryDelaySec <= 0 || cfg.RetryMaxDelaySec < cfg.RetryDelaySec) {
+		return nil, fmt.Errorf("invalid RETRY_DELAY_SEC %d / RETRY_MAX_DELAY_SEC %d: delay must be positive and not above the maximum",
+			cfg.RetryDelaySec, cfg.RetryMaxDelaySec)
+	}
+
+	if cfg.StmtCacheSize < 0 {
+		return nil, fmt.Errorf("invalid STMT_CACHE_SIZE %d: must not be negative", cfg.StmtCacheSize)
+	}
+
+	// Validate write timeouts
+	if cfg.BatchTimeoutSec < 0 {
+		return nil, fmt.Errorf("invalid BATCH_TIMEOUT_SEC %d: must not be negative", cfg.BatchTimeoutSec)
+	}
+	if cfg.StatementTimeoutMS < 0 {
+		return nil, fmt.Errorf("invalid STATEMENT_TIMEOUT_MS %d: must not be negative", cfg.StatementTimeoutMS)
+	}
+
+	// Validate bulk loading
+	if cfg.BulkLoadMinRows < 0 {
+		return nil, fmt.Errorf("invalid BULK_LOAD_MIN_ROWS %d: must not be negative", cfg.BulkLoadMinRows)
+	}
+	if cfg.BulkLoadBatchSize < 0 {
+		return nil, fmt.Errorf("invalid BULK_LOAD_BATCH_SIZE %d: must not be negative", cfg.BulkLoadBatchSize)
+	}
+	if cfg.BulkLoadMinRows > 0 && cfg.TargetType != TargetPostgres {
+		return nil, fmt.Errorf("BULK_LOAD_MIN_ROWS requires TARGET_TYPE 'postgres'")
+	}
+
 	// Validate required fields based on target type
 	if cfg.TargetType == TargetMySQL && cfg.TargetDB.Password == "" {
 		return nil, fmt.Errorf("TARGET_DB_PASSWORD is required for MySQL target")
@@ -131,6 +452,29 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("TARGET_PG_PASSWORD is required for PostgreSQL target")
 	}
 
+	// Compile topic filters
+	if cfg.KafkaTopicInclude != "" {
+		re, err := regexp.Compile(cfg.KafkaTopicInclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KAFKA_TOPIC_INCLUDE %s: %w", cfg.KafkaTopicInclude, err)
+		}
+		cfg.KafkaTopicIncludeRegex = re
+	}
+	if cfg.KafkaTopicExclude != "" {
+		re, err := regexp.Compile(cfg.KafkaTopicExclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KAFKA_TOPIC_EXCLUDE %s: %w", cfg.KafkaTopicExclude, err)
+		}
+		cfg.KafkaTopicExcludeRegex = re
+	}
+
+	// Parse topic-to-table overrides
+	tableMap, err := parseMap(getEnv("KAFKA_TOPIC_TABLE_MAP", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid KAFKA_TOPIC_TABLE_MAP: %w", err)
+	}
+	cfg.KafkaTopicTableMap = tableMap
+
 	// Parse source timezone
 	sourceLoc, err := time.LoadLocation(cfg.SourceTimezone)
 	if err != nil {
@@ -180,6 +524,62 @@ func (c *Config) TargetDatabase() string {
 	return c.TargetDB.Database
 }
 
+// ExactlyOnce reports whether offsets are stored in the target database
+func (c *Config) ExactlyOnce() bool {
+	return c.DeliveryMode == DeliveryExactlyOnce
+}
+
+// BuildErrorActionFor returns the action for an event that failed SQL
+// generation with the given reason code
+func (c *Config) BuildErrorActionFor(reason string) FailureAction {
+	if action, ok := c.BuildErrorActions[reason]; ok {
+		return action
+	}
+	return c.BuildErrorAction
+}
+
+func (a FailureAction) valid() bool {
+	return a == FailureSkip || a == FailureDLQ || a == FailureHalt
+}
+
+// DLQPathFromEnv returns the DLQ file path from DLQ_PATH (or .env) without
+// loading and validating the rest of the configuration, for the dlq tools
+func DLQPathFromEnv() string {
+	_ = godotenv.Load()
+	return getEnv("DLQ_PATH", DefaultDLQPath)
+}
+
+// IsTopicSelected checks if a topic should be consumed
+func (c *Config) IsTopicSelected(topic string) bool {
+	if !strings.HasPrefix(topic, c.KafkaTopicPrefix) {
+		return false
+	}
+	if c.KafkaTopicIncludeRegex != nil && !c.KafkaTopicIncludeRegex.MatchString(topic) {
+		return false
+	}
+	if c.KafkaTopicExcludeRegex != nil && c.KafkaTopicExcludeRegex.MatchString(topic) {
+		return false
+	}
+	return true
+}
+
+// TableForTopic returns the target table for a topic.
+// Explicit mappings win; otherwise the topic prefix is stripped, or, without
+// a prefix, the last dot-separated segment is used (Debezium's
+// <prefix>.<database>.<table> naming).
+func (c *Config) TableForTopic(topic string) string {
+	if table, ok := c.KafkaTopicTableMap[topic]; ok {
+		return table
+	}
+	if c.KafkaTopicPrefix != "" && strings.HasPrefix(topic, c.KafkaTopicPrefix) {
+		return strings.TrimPrefix(topic, c.KafkaTopicPrefix)
+	}
+	if i := strings.LastIndex(topic, "."); i >= 0 {
+		return topic[i+1:]
+	}
+	return topic
+}
+
 // IsTableExcluded checks if a table should be skipped
 func (c *Config) IsTableExcluded(tableName string) bool {
 	return slices.Contains(c.ExcludedTables, tableName)
@@ -203,6 +603,16 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// Helper: get env var as bool with default
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
 // Helper: parse comma-separated list
 func parseList(value string) []string {
 	if value == "" {
@@ -217,3 +627,17 @@ func parseList(value string) []string {
 	}
 	return result
 }
+
+// Helper: parse comma-separated key=value pairs
+func parseMap(value string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range parseList(value) {
+		key, val, ok := strings.Cut(pair, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || key == "" || val == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		result[key] = val
+	}
+	return result, nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"regexp"
 	"testing"
 	"time"
 )
@@ -131,6 +132,30 @@ func TestGetEnvInt(t *testing.T) {
 	}
 }
 
+func TestGetEnvBool(t *testing.T) {
+	tests := []struct {
+		name         string
+		envValue     string
+		defaultValue bool
+		want         bool
+	}{
+		{"returns default when empty", "", true, true},
+		{"parses true", "true", false, true},
+		{"parses 1", "1", false, true},
+		{"parses false", "false", true, false},
+		{"returns default when invalid", "yes please", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_GET_ENV_BOOL", tt.envValue)
+			if got := getEnvBool("TEST_GET_ENV_BOOL", tt.defaultValue); got != tt.want {
+				t.Errorf("getEnvBool() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseList(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -388,3 +413,492 @@ func TestLoad_ValidConfig(t *testing.T) {
 		t.Errorf("SourceLocation = %v, want %v", cfg.SourceLocation, denverLoc)
 	}
 }
+
+func TestLoad_DeliveryMode(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	t.Setenv("DELIVERY_MODE", "")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DeliveryMode != DeliveryAtLeastOnce {
+		t.Errorf("DeliveryMode = %v, want %v", cfg.DeliveryMode, DeliveryAtLeastOnce)
+	}
+	if cfg.ExactlyOnce() {
+		t.Error("ExactlyOnce() should be false by default")
+	}
+
+	t.Setenv("DELIVERY_MODE", "exactly-once")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.ExactlyOnce() {
+		t.Error("ExactlyOnce() should be true for DELIVERY_MODE=exactly-once")
+	}
+
+	t.Setenv("DELIVERY_MODE", "at-most-once")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid DELIVERY_MODE")
+	}
+}
+
+func TestParseMap(t *testing.T) {
+	got, err := parseMap(" pos_mysql.pos.orders = orders_v2 ,other.db.items=items")
+	if err != nil {
+		t.Fatalf("parseMap() error = %v", err)
+	}
+	if got["pos_mysql.pos.orders"] != "orders_v2" {
+		t.Errorf("parseMap()[orders] = %q, want orders_v2", got["pos_mysql.pos.orders"])
+	}
+	if got["other.db.items"] != "items" {
+		t.Errorf("parseMap()[items] = %q, want items", got["other.db.items"])
+	}
+
+	if got, err := parseMap(""); err != nil || len(got) != 0 {
+		t.Errorf("parseMap(\"\") = %v, %v, want empty map", got, err)
+	}
+
+	for _, bad := range []string{"no_equals", "=table", "topic="} {
+		if _, err := parseMap(bad); err == nil {
+			t.Errorf("parseMap(%q) should return error", bad)
+		}
+	}
+}
+
+func TestConfig_IsTopicSelected(t *testing.T) {
+	cfg := &Config{
+		KafkaTopicPrefix:       "pos_mysql.pos.",
+		KafkaTopicIncludeRegex: regexp.MustCompile(`\.(orders|items|customers)$`),
+		KafkaTopicExcludeRegex: regexp.MustCompile(`customers$`),
+	}
+
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{"pos_mysql.pos.orders", true},
+		{"pos_mysql.pos.items", true},
+		{"pos_mysql.pos.customers", false}, // excluded
+		{"pos_mysql.pos.log", false},       // not included
+		{"other.pos.orders", false},        // wrong prefix
+		{"pos_mysql", false},               // Debezium schema-change topic
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			if got := cfg.IsTopicSelected(tt.topic); got != tt.want {
+				t.Errorf("IsTopicSelected(%q) = %v, want %v", tt.topic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_TableForTopic(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		tableMap map[string]string
+		topic    string
+		want     string
+	}{
+		{"prefix stripped", "pos_mysql.pos.", nil, "pos_mysql.pos.orders", "orders"},
+		{"other connector prefix", "store2.pos_eu.", nil, "store2.pos_eu.orders", "orders"},
+		{"no prefix uses last segment", "", nil, "pos_mysql.pos.orders", "orders"},
+		{"explicit mapping wins", "pos_mysql.pos.", map[string]string{"pos_mysql.pos.orders": "orders_archive"}, "pos_mysql.pos.orders", "orders_archive"},
+		{"no dots", "", nil, "orders", "orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{KafkaTopicPrefix: tt.prefix, KafkaTopicTableMap: tt.tableMap}
+			if got := cfg.TableForTopic(tt.topic); got != tt.want {
+				t.Errorf("TableForTopic(%q) = %q, want %q", tt.topic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_TopicSelection(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+	t.Setenv("KAFKA_TOPIC_PREFIX", "store2.pos.")
+	t.Setenv("KAFKA_TOPIC_INCLUDE", `^store2\.pos\.(orders|items)$`)
+	t.Setenv("KAFKA_TOPIC_EXCLUDE", "")
+	t.Setenv("KAFKA_TOPIC_TABLE_MAP", "store2.pos.items=order_items")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.IsTopicSelected("store2.pos.orders") {
+		t.Error("store2.pos.orders should be selected")
+	}
+	if cfg.IsTopicSelected("pos_mysql.pos.orders") {
+		t.Error("pos_mysql.pos.orders should not be selected with a different prefix")
+	}
+	if got := cfg.TableForTopic("store2.pos.items"); got != "order_items" {
+		t.Errorf("TableForTopic() = %q, want order_items", got)
+	}
+
+	t.Setenv("KAFKA_TOPIC_EXCLUDE", "([")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid KAFKA_TOPIC_EXCLUDE")
+	}
+
+	t.Setenv("KAFKA_TOPIC_EXCLUDE", "")
+	t.Setenv("KAFKA_TOPIC_TABLE_MAP", "broken")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid KAFKA_TOPIC_TABLE_MAP")
+	}
+}
+
+func TestLoad_TombstoneMode(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	t.Setenv("TOMBSTONE_MODE", "")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.TombstoneMode != TombstoneIgnore {
+		t.Errorf("TombstoneMode = %v, want %v", cfg.TombstoneMode, TombstoneIgnore)
+	}
+
+	t.Setenv("TOMBSTONE_MODE", "delete")
+	if cfg, err = Load(); err != nil || cfg.TombstoneMode != TombstoneDelete {
+		t.Errorf("Load() = %v, %v, want TombstoneMode delete", cfg, err)
+	}
+
+	t.Setenv("TOMBSTONE_MODE", "drop")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid TOMBSTONE_MODE")
+	}
+}
+
+func TestLoad_MessageFormat(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	t.Setenv("MESSAGE_FORMAT", "")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.MessageFormat != FormatUnwrapped {
+		t.Errorf("MessageFormat = %v, want %v", cfg.MessageFormat, FormatUnwrapped)
+	}
+
+	t.Setenv("MESSAGE_FORMAT", "envelope")
+	if cfg, err = Load(); err != nil || cfg.MessageFormat != FormatEnvelope {
+		t.Errorf("Load() = %v, %v, want MessageFormat envelope", cfg, err)
+	}
+
+	t.Setenv("MESSAGE_FORMAT", "avro")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid MESSAGE_FORMAT")
+	}
+}
+
+func TestLoad_MessageEncoding(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+	t.Setenv("SCHEMA_REGISTRY_URL", "")
+
+	t.Setenv("MESSAGE_ENCODING", "")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.MessageEncoding != EncodingJSON {
+		t.Errorf("MessageEncoding = %v, want %v", cfg.MessageEncoding, EncodingJSON)
+	}
+
+	t.Setenv("MESSAGE_ENCODING", "avro")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should require SCHEMA_REGISTRY_URL for avro")
+	}
+
+	t.Setenv("SCHEMA_REGISTRY_URL", "http://redpanda:8081")
+	if cfg, err = Load(); err != nil || cfg.MessageEncoding != EncodingAvro {
+		t.Errorf("Load() = %v, %v, want MessageEncoding avro", cfg, err)
+	}
+
+	t.Setenv("MESSAGE_ENCODING", "protobuf")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid MESSAGE_ENCODING")
+	}
+}
+
+func TestLoad_BuildErrorActions(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	t.Setenv("BUILD_ERROR_ACTION", "")
+	t.Setenv("BUILD_ERROR_ACTIONS", "no_columns=skip, schema_lookup=halt")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.BuildErrorActionFor("no_columns"); got != FailureSkip {
+		t.Errorf("BuildErrorActionFor(no_columns) = %v, want skip", got)
+	}
+	if got := cfg.BuildErrorActionFor("schema_lookup"); got != FailureHalt {
+		t.Errorf("BuildErrorActionFor(schema_lookup) = %v, want halt", got)
+	}
+	if got := cfg.BuildErrorActionFor("missing_primary_key"); got != FailureDLQ {
+		t.Errorf("BuildErrorActionFor(missing_primary_key) = %v, want dlq default", got)
+	}
+
+	t.Setenv("BUILD_ERROR_ACTION", "halt")
+	if cfg, err = Load(); err != nil || cfg.BuildErrorActionFor("missing_primary_key") != FailureHalt {
+		t.Errorf("Load() = %v, %v, want default action halt", cfg, err)
+	}
+
+	t.Setenv("BUILD_ERROR_ACTION", "ignore")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid BUILD_ERROR_ACTION")
+	}
+
+	t.Setenv("BUILD_ERROR_ACTION", "dlq")
+	t.Setenv("BUILD_ERROR_ACTIONS", "no_columns=drop")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid BUILD_ERROR_ACTIONS action")
+	}
+}
+
+func TestLoad_DLQSink(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	t.Setenv("DLQ_SINK", "")
+	t.Setenv("DLQ_TOPIC", "")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DLQSink != DLQSinkFile || cfg.DLQTopic != "cdc.dlq" {
+		t.Errorf("DLQSink, DLQTopic = %v, %v, want file, cdc.dlq", cfg.DLQSink, cfg.DLQTopic)
+	}
+
+	t.Setenv("DLQ_SINK", "kafka")
+	t.Setenv("DLQ_TOPIC", "pos.dead")
+	if cfg, err = Load(); err != nil || cfg.DLQSink != DLQSinkKafka || cfg.DLQTopic != "pos.dead" {
+		t.Errorf("Load() = %v, %v, want kafka sink to pos.dead", cfg, err)
+	}
+
+	t.Setenv("DLQ_SINK", "s3")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid DLQ_SINK")
+	}
+}
+
+func TestLoad_DLQRotation(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	t.Setenv("DLQ_PATH", "/data/dlq/events.jsonl")
+	t.Setenv("DLQ_MAX_SIZE_MB", "10")
+	t.Setenv("DLQ_ROTATE_HOURS", "0")
+	t.Setenv("DLQ_RETENTION_DAYS", "7")
+	t.Setenv("DLQ_MAX_FILES", "5")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DLQPath != "/data/dlq/events.jsonl" || cfg.DLQMaxSizeMB != 10 || cfg.DLQRotateHours != 0 ||
+		cfg.DLQRetentionDays != 7 || cfg.DLQMaxFiles != 5 {
+		t.Errorf("DLQ settings = %s %d %d %d %d", cfg.DLQPath, cfg.DLQMaxSizeMB, cfg.DLQRotateHours, cfg.DLQRetentionDays, cfg.DLQMaxFiles)
+	}
+	if got := DLQPathFromEnv(); got != "/data/dlq/events.jsonl" {
+		t.Errorf("DLQPathFromEnv() = %q, want /data/dlq/events.jsonl", got)
+	}
+
+	t.Setenv("DLQ_MAX_FILES", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for negative DLQ_MAX_FILES")
+	}
+}
+
+func TestLoad_Retry(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.RetryAttempts != 0 || cfg.RetryDelaySec != 30 || cfg.RetryMaxDelaySec != 600 {
+		t.Errorf("retry defaults = %d, %d, %d, want 0, 30, 600", cfg.RetryAttempts, cfg.RetryDelaySec, cfg.RetryMaxDelaySec)
+	}
+
+	t.Setenv("RETRY_ATTEMPTS", "5")
+	t.Setenv("RETRY_DELAY_SEC", "120")
+	t.Setenv("RETRY_MAX_DELAY_SEC", "60")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error when RETRY_DELAY_SEC exceeds RETRY_MAX_DELAY_SEC")
+	}
+
+	// Disabled retries skip the delay checks
+	t.Setenv("RETRY_ATTEMPTS", "0")
+	if _, err := Load(); err != nil {
+		t.Errorf("Load() error = %v, want nil with retries disabled", err)
+	}
+
+	t.Setenv("RETRY_ATTEMPTS", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for negative RETRY_ATTEMPTS")
+	}
+}
+
+func TestLoad_RoutingMode(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	cfg, err := Load()
+	if err != nil || cfg.RoutingMode != RoutingPartition {
+		t.Fatalf("Load() = %v, %v, want partition routing by default", cfg, err)
+	}
+
+	t.Setenv("ROUTING_MODE", "key")
+	if cfg, err = Load(); err != nil || cfg.RoutingMode != RoutingKey {
+		t.Errorf("Load() = %v, %v, want key routing", cfg, err)
+	}
+
+	t.Setenv("DELIVERY_MODE", "exactly-once")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should reject key routing with exactly-once delivery")
+	}
+
+	t.Setenv("DELIVERY_MODE", "")
+	t.Setenv("ROUTING_MODE", "table")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid ROUTING_MODE")
+	}
+}
+
+func TestLoad_BulkLoad(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "postgres")
+	t.Setenv("TARGET_PG_PASSWORD", "test_password")
+
+	cfg, err := Load()
+	if err != nil || cfg.BulkLoadMinRows != 0 {
+		t.Fatalf("Load() = %v, %v, want bulk loading disabled by default", cfg, err)
+	}
+
+	t.Setenv("BULK_LOAD_MIN_ROWS", "500")
+	if cfg, err = Load(); err != nil || cfg.BulkLoadMinRows != 500 {
+		t.Fatalf("Load() = %v, %v, want BulkLoadMinRows 500", cfg, err)
+	}
+	if cfg.BulkLoadBatchSize != 10000 {
+		t.Errorf("BulkLoadBatchSize = %d, want default 10000", cfg.BulkLoadBatchSize)
+	}
+
+	t.Setenv("BULK_LOAD_BATCH_SIZE", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for negative BULK_LOAD_BATCH_SIZE")
+	}
+	t.Setenv("BULK_LOAD_BATCH_SIZE", "")
+
+	t.Setenv("BULK_LOAD_MIN_ROWS", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for negative BULK_LOAD_MIN_ROWS")
+	}
+
+	t.Setenv("BULK_LOAD_MIN_ROWS", "500")
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for bulk loading into MySQL")
+	}
+}
+
+func TestLoad_PGPipeline(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "postgres")
+	t.Setenv("TARGET_PG_PASSWORD", "test_password")
+
+	cfg, err := Load()
+	if err != nil || cfg.TargetPG.Pipeline {
+		t.Fatalf("Load() = %v, %v, want database/sql writer by default", cfg, err)
+	}
+
+	t.Setenv("TARGET_PG_PIPELINE", "true")
+	if cfg, err = Load(); err != nil || !cfg.TargetPG.Pipeline {
+		t.Errorf("Load() = %v, %v, want pipeline enabled", cfg, err)
+	}
+}
+
+func TestLoad_StmtCacheSize(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	cfg, err := Load()
+	if err != nil || cfg.StmtCacheSize != 256 {
+		t.Fatalf("Load() = %v, %v, want StmtCacheSize 256", cfg, err)
+	}
+
+	t.Setenv("STMT_CACHE_SIZE", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for negative STMT_CACHE_SIZE")
+	}
+}
+
+func TestLoad_UnknownColumns(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	tests := []struct {
+		policy  string
+		actions string
+		want    FailureAction
+	}{
+		{"", "", FailureDLQ},
+		{"dlq", "", FailureDLQ},
+		{"fail", "", FailureHalt},
+		{"fail", "unknown_column=skip", FailureSkip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy+"/"+tt.actions, func(t *testing.T) {
+			t.Setenv("UNKNOWN_COLUMNS", tt.policy)
+			t.Setenv("BUILD_ERROR_ACTIONS", tt.actions)
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got := cfg.BuildErrorActionFor("unknown_column"); got != tt.want {
+				t.Errorf("BuildErrorActionFor(unknown_column) = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Setenv("UNKNOWN_COLUMNS", "ignore")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for invalid UNKNOWN_COLUMNS")
+	}
+}
+
+func TestLoad_Timeouts(t *testing.T) {
+	t.Setenv("TARGET_TYPE", "mysql")
+	t.Setenv("TARGET_DB_PASSWORD", "test_password")
+
+	cfg, err := Load()
+	if err != nil || cfg.BatchTimeoutSec != 60 || cfg.StatementTimeoutMS != 0 {
+		t.Fatalf("Load() = %v, %v, want BatchTimeoutSec 60, StatementTimeoutMS 0", cfg, err)
+	}
+
+	t.Setenv("BATCH_TIMEOUT_SEC", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for negative BATCH_TIMEOUT_SEC")
+	}
+
+	t.Setenv("BATCH_TIMEOUT_SEC", "30")
+	t.Setenv("STATEMENT_TIMEOUT_MS", "-5")
+	if _, err := Load(); err == nil {
+		t.Error("Load() should return error for negative STATEMENT_TIMEOUT_MS")
+	}
+}
@@ -0,0 +1,250 @@
+package consumer
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// avroNames maps the full name of every named type (record, enum, fixed)
+// in a schema to its definition, so references by name can be resolved.
+type avroNames map[string]map[string]any
+
+// avroPrimitives are the type names that are not references
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true, "float": true,
+	"double": true, "bytes": true, "string": true,
+}
+
+// newAvroNames collects the named types of a parsed schema
+func newAvroNames(schema any) avroNames {
+	names := make(avroNames)
+	names.collect(schema, "")
+	return names
+}
+
+func (n avroNames) collect(node any, namespace string) {
+	switch v := node.(type) {
+	case []any:
+		for _, branch := range v {
+			n.collect(branch, namespace)
+		}
+	case map[string]any:
+		switch v["type"] {
+		case "record", "error", "enum", "fixed":
+			var full string
+			full, namespace = avroFullName(v, namespace)
+			n[full] = v
+		}
+		if fields, ok := v["fields"].([]any); ok {
+			for _, f := range fields {
+				if field, ok := f.(map[string]any); ok {
+					n.collect(field["type"], namespace)
+				}
+			}
+		}
+		for _, key := range []string{"type", "items", "values"} {
+			if child, ok := v[key].(map[string]any); ok {
+				n.collect(child, namespace)
+			} else if child, ok := v[key].([]any); ok {
+				n.collect(child, namespace)
+			}
+		}
+	}
+}
+
+// resolve returns the definition behind a type reference and the namespace
+// names inside it are relative to. Anything else is returned as is.
+func (n avroNames) resolve(node any, namespace string) (any, string) {
+	switch v := node.(type) {
+	case string:
+		if avroPrimitives[v] {
+			return v, namespace
+		}
+		def, ok := n[v]
+		if !ok && namespace != "" {
+			def, ok = n[namespace+"."+v]
+		}
+		if !ok {
+			return v, namespace
+		}
+		_, ns := avroFullName(def, namespace)
+		return def, ns
+	case map[string]any:
+		switch v["type"] {
+		case "record", "error", "enum", "fixed":
+			_, ns := avroFullName(v, namespace)
+			return v, ns
+		}
+	}
+	return node, namespace
+}
+
+// avroFullName returns the full name of a named type and its namespace
+func avroFullName(def map[string]any, namespace string) (string, string) {
+	name, _ := def["name"].(string)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name, name[:i]
+	}
+	if ns, ok := def["namespace"].(string); ok {
+		namespace = ns
+	}
+	if namespace == "" {
+		return name, ""
+	}
+	return namespace + "." + name, namespace
+}
+
+// nonNullBranch returns the only non-null branch of a union. ok is false for
+// unions with several value branches, which Debezium does not produce.
+func nonNullBranch(union []any) (any, bool) {
+	var branch any
+	for _, b := range union {
+		if b == "null" {
+			continue
+		}
+		if branch != nil {
+			return nil, false
+		}
+		branch = b
+	}
+	return branch, branch != nil
+}
+
+// typeName returns the Avro type of a resolved schema node
+func typeName(node any) string {
+	switch v := node.(type) {
+	case string:
+		return v
+	case map[string]any:
+		if t, ok := v["type"].(string); ok {
+			return t
+		}
+	}
+	return ""
+}
+
+// encodeAvroBytes rewrites bytes and fixed values, which goavro renders in
+// JSON as one code point per byte, as base64 like the JsonConverter does.
+func (n avroNames) encodeAvroBytes(schema any, value any, namespace string) any {
+	if value == nil {
+		return nil
+	}
+	schema, namespace = n.resolve(schema, namespace)
+	if union, ok := schema.([]any); ok {
+		branch, ok := nonNullBranch(union)
+		if !ok {
+			return value
+		}
+		return n.encodeAvroBytes(branch, value, namespace)
+	}
+
+	switch typeName(schema) {
+	case "bytes", "fixed":
+		s, ok := value.(string)
+		if !ok {
+			return value
+		}
+		raw := make([]byte, 0, len(s))
+		for _, r := range s {
+			raw = append(raw, byte(r))
+		}
+		return base64.StdEncoding.EncodeToString(raw)
+	case "record", "error":
+		record, ok := value.(map[string]any)
+		if !ok {
+			return value
+		}
+		fields, _ := schema.(map[string]any)["fields"].([]any)
+		for _, f := range fields {
+			field, ok := f.(map[string]any)
+			if !ok {
+				continue
+			}
+			name, _ := field["name"].(string)
+			if v, ok := record[name]; ok {
+				record[name] = n.encodeAvroBytes(field["type"], v, namespace)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return value
+		}
+		for i, item := range items {
+			items[i] = n.encodeAvroBytes(schema.(map[string]any)["items"], item, namespace)
+		}
+	case "map":
+		values, ok := value.(map[string]any)
+		if !ok {
+			return value
+		}
+		for k, v := range values {
+			values[k] = n.encodeAvroBytes(schema.(map[string]any)["values"], v, namespace)
+		}
+	}
+	return value
+}
+
+// avroConnectTypes maps Avro types to Kafka Connect schema types
+var avroConnectTypes = map[string]string{
+	"boolean": "boolean", "int": "int32", "long": "int64", "float": "float",
+	"double": "double", "bytes": "bytes", "fixed": "bytes", "string": "string",
+	"enum": "string", "record": "struct", "error": "struct", "array": "array", "map": "map",
+}
+
+// connectSchema converts an Avro schema to the Kafka Connect schema the
+// JsonConverter would embed, keeping the connect.name and
+// connect.parameters the AvroConverter records, so logical types such as
+// Decimal are known downstream.
+func (n avroNames) connectSchema(schema any, namespace string) map[string]any {
+	schema, namespace = n.resolve(schema, namespace)
+	if union, ok := schema.([]any); ok {
+		branch, ok := nonNullBranch(union)
+		if !ok {
+			return map[string]any{"optional": true}
+		}
+		result := n.connectSchema(branch, namespace)
+		result["optional"] = true
+		return result
+	}
+
+	result := map[string]any{"type": avroConnectTypes[typeName(schema)]}
+	def, ok := schema.(map[string]any)
+	if !ok {
+		return result
+	}
+	if t, ok := def["connect.type"].(string); ok {
+		result["type"] = t
+	}
+	if name, ok := def["connect.name"].(string); ok {
+		result["name"] = name
+	}
+	if params, ok := def["connect.parameters"].(map[string]any); ok {
+		result["parameters"] = params
+	}
+
+	switch typeName(def) {
+	case "record", "error":
+		if _, ok := result["name"]; !ok {
+			result["name"], _ = avroFullName(def, namespace)
+		}
+		var fields []any
+		defFields, _ := def["fields"].([]any)
+		for _, f := range defFields {
+			field, ok := f.(map[string]any)
+			if !ok {
+				continue
+			}
+			fs := n.connectSchema(field["type"], namespace)
+			fs["field"] = field["name"]
+			fields = append(fields, fs)
+		}
+		result["fields"] = fields
+	case "array":
+		result["items"] = n.connectSchema(def["items"], namespace)
+	case "map":
+		result["keys"] = map[string]any{"type": "string"}
+		result["values"] = n.connectSchema(def["values"], namespace)
+	}
+	return result
+}
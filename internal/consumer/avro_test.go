@@ -0,0 +1,285 @@
+package consumer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/linkedin/goavro/v2"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/internal/schema"
+)
+
+// ordersValueSchema mirrors what the Confluent AvroConverter registers for
+// an unwrapped Debezium orders topic
+const ordersValueSchema = `{
+	"type": "record",
+	"name": "Value",
+	"namespace": "pos_mysql.pos.orders",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "status", "type": ["null", "string"], "default": null},
+		{"name": "total", "type": ["null", {"type": "bytes", "scale": 2, "precision": 10, "connect.version": 1, "connect.parameters": {"scale": "2", "connect.decimal.precision": "10"}, "connect.name": "org.apache.kafka.connect.data.Decimal", "logicalType": "decimal"}], "default": null},
+		{"name": "created_at", "type": ["null", {"type": "long", "connect.name": "org.apache.kafka.connect.data.Timestamp", "logicalType": "timestamp-millis"}], "default": null},
+		{"name": "__op", "type": ["null", "string"], "default": null},
+		{"name": "__ts_ms", "type": ["null", "long"], "default": null}
+	]
+}`
+
+const ordersKeySchema = `{"type": "record", "name": "Key", "namespace": "pos_mysql.pos.orders", "fields": [{"name": "id", "type": "long"}]}`
+
+// testRegistry is a local stand-in for a Confluent schema registry
+type testRegistry struct {
+	server   *httptest.Server
+	schemas  map[uint32]string
+	requests atomic.Int32
+}
+
+func newTestRegistry(t *testing.T, schemas map[uint32]string) *testRegistry {
+	t.Helper()
+	r := &testRegistry{schemas: schemas}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.requests.Add(1)
+		var id uint32
+		if _, err := fmt.Sscanf(req.URL.Path, "/schemas/ids/%d", &id); err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		schema, ok := r.schemas[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code": 40403, "message": "Schema not found"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"schema": schema})
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// encodeAvro serializes native data in the Confluent wire format
+func encodeAvro(t *testing.T, schemaID uint32, schema string, native map[string]any) []byte {
+	t.Helper()
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		t.Fatalf("NewCodec() error = %v", err)
+	}
+	header := make([]byte, 5)
+	header[0] = avroMagicByte
+	binary.BigEndian.PutUint32(header[1:], schemaID)
+	data, err := codec.BinaryFromNative(header, native)
+	if err != nil {
+		t.Fatalf("BinaryFromNative() error = %v", err)
+	}
+	return data
+}
+
+func TestAvroDecoder_Decode(t *testing.T) {
+	registry := newTestRegistry(t, map[uint32]string{7: ordersValueSchema})
+	decoder := NewAvroDecoder(NewRegistryClient(registry.server.URL, "", ""))
+
+	data := encodeAvro(t, 7, ordersValueSchema, map[string]any{
+		"id":         int64(42),
+		"status":     goavro.Union("string", "paid"),
+		"total":      goavro.Union("bytes.decimal", big.NewRat(1234, 100)),
+		"created_at": goavro.Union("long.timestamp-millis", time.UnixMilli(1735689600000)),
+		"__op":       goavro.Union("string", "c"),
+		"__ts_ms":    nil,
+	})
+
+	decoded, err := decoder.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	value, connect := splitSchema(decoded)
+	if connect == nil {
+		t.Fatal("Decode() should wrap the value with its Connect schema")
+	}
+
+	// Same shape as the JsonConverter: unions unwrapped, numbers as json.Number
+	if value["id"] != json.Number("42") {
+		t.Errorf("id = %#v, want 42", value["id"])
+	}
+	if value["status"] != "paid" {
+		t.Errorf("status = %#v, want paid", value["status"])
+	}
+	if value["created_at"] != json.Number("1735689600000") {
+		t.Errorf("created_at = %#v, want epoch millis", value["created_at"])
+	}
+	// Bytes are base64 like the JsonConverter's, not one code point per byte
+	if value["total"] != "BNI=" {
+		t.Errorf("total = %#v, want base64 BNI=", value["total"])
+	}
+	if value["__op"] != "c" {
+		t.Errorf("__op = %#v, want c", value["__op"])
+	}
+	if value["__ts_ms"] != nil {
+		t.Errorf("__ts_ms = %#v, want nil", value["__ts_ms"])
+	}
+}
+
+func TestAvroDecoder_CachesSchemas(t *testing.T) {
+	registry := newTestRegistry(t, map[uint32]string{1: ordersKeySchema})
+	decoder := NewAvroDecoder(NewRegistryClient(registry.server.URL, "", ""))
+
+	for i := range 5 {
+		data := encodeAvro(t, 1, ordersKeySchema, map[string]any{"id": int64(i)})
+		if _, err := decoder.Decode(data); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+	}
+
+	if n := registry.requests.Load(); n != 1 {
+		t.Errorf("registry requests = %d, want 1", n)
+	}
+}
+
+func TestAvroDecoder_Errors(t *testing.T) {
+	registry := newTestRegistry(t, map[uint32]string{1: ordersKeySchema})
+	decoder := NewAvroDecoder(NewRegistryClient(registry.server.URL, "", ""))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too short", []byte{0, 0, 0}},
+		{"wrong magic byte", []byte(`{"id": 1}`)},
+		{"truncated body", encodeAvro(t, 1, ordersKeySchema, map[string]any{"id": int64(1)})[:5]},
+		{"unregistered schema id", append([]byte{0, 0, 0, 0, 99}, 0x02)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decoder.Decode(tt.data); err == nil {
+				t.Error("Decode() should return error")
+			}
+		})
+	}
+}
+
+func TestRegistryClient_BasicAuth(t *testing.T) {
+	var gotUser, gotPass string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, gotPass, _ = r.BasicAuth()
+		_ = json.NewEncoder(w).Encode(map[string]string{"schema": `"string"`})
+	}))
+	defer server.Close()
+
+	client := NewRegistryClient(server.URL+"/", "cdc", "secret")
+	schema, err := client.Schema(3)
+	if err != nil {
+		t.Fatalf("Schema() error = %v", err)
+	}
+	if schema != `"string"` {
+		t.Errorf("Schema() = %q", schema)
+	}
+	if gotUser != "cdc" || gotPass != "secret" {
+		t.Errorf("basic auth = %q/%q, want cdc/secret", gotUser, gotPass)
+	}
+}
+
+func TestStripLogicalTypes(t *testing.T) {
+	stripped, err := stripLogicalTypes(ordersValueSchema)
+	if err != nil {
+		t.Fatalf("stripLogicalTypes() error = %v", err)
+	}
+	if strings.Contains(stripped, "logicalType") {
+		t.Errorf("stripped schema still contains logicalType: %s", stripped)
+	}
+	if !strings.Contains(stripped, "connect.name") {
+		t.Error("stripped schema should keep other attributes")
+	}
+}
+
+func TestParseEvent_Avro(t *testing.T) {
+	registry := newTestRegistry(t, map[uint32]string{1: ordersKeySchema, 2: ordersValueSchema})
+	h := &consumerGroupHandler{consumer: &Consumer{
+		config:  &config.Config{MessageEncoding: config.EncodingAvro},
+		decoder: NewAvroDecoder(NewRegistryClient(registry.server.URL, "", "")),
+	}}
+
+	event, err := h.parseEvent(&sarama.ConsumerMessage{
+		Key: encodeAvro(t, 1, ordersKeySchema, map[string]any{"id": int64(5)}),
+		Value: encodeAvro(t, 2, ordersValueSchema, map[string]any{
+			"id":         int64(5),
+			"status":     nil,
+			"total":      goavro.Union("bytes.decimal", big.NewRat(-1999, 100)),
+			"created_at": nil,
+			"__op":       goavro.Union("string", "u"),
+			"__ts_ms":    goavro.Union("long", int64(1735689600000)),
+		}),
+	})
+	if err != nil {
+		t.Fatalf("parseEvent() error = %v", err)
+	}
+
+	if event.GetOperation() != models.OperationUpdate {
+		t.Errorf("GetOperation() = %v, want UPDATE", event.GetOperation())
+	}
+	if event.Timestamp != 1735689600000 {
+		t.Errorf("Timestamp = %d, want 1735689600000", event.Timestamp)
+	}
+	if event.Key["id"] != json.Number("5") {
+		t.Errorf("Key[id] = %v, want 5", event.Key["id"])
+	}
+
+	// The Connect schema names the logical type, so decimals are decoded
+	total := event.Fields["total"]
+	if total.Name != "org.apache.kafka.connect.data.Decimal" || total.Parameters["scale"] != "2" {
+		t.Fatalf("Fields[total] = %+v, want Decimal with scale 2", total)
+	}
+	converter := schema.NewConverter(time.UTC, time.UTC, config.TargetPostgres)
+	if got, ok := converter.ConvertLogical(total, nil, event.Payload["total"]); !ok || got != "-19.99" {
+		t.Errorf("ConvertLogical(total) = %v, %v, want -19.99", got, ok)
+	}
+}
+
+func TestAvroNames_EnvelopeReferences(t *testing.T) {
+	// Debezium defines the row record in "before" and refers to it by name
+	// in "after"; VariableScaleDecimal is a record holding bytes
+	const envelope = `{
+		"type": "record", "name": "Envelope", "namespace": "pos_mysql.pos.orders",
+		"fields": [
+			{"name": "before", "type": ["null", {"type": "record", "name": "Value", "fields": [
+				{"name": "id", "type": "long"},
+				{"name": "price", "type": ["null", {"type": "record", "name": "VariableScaleDecimal", "namespace": "io.debezium.data",
+					"fields": [{"name": "scale", "type": "int"}, {"name": "value", "type": "bytes"}],
+					"connect.name": "io.debezium.data.VariableScaleDecimal"}], "default": null}
+			]}], "default": null},
+			{"name": "after", "type": ["null", "Value"], "default": null},
+			{"name": "op", "type": "string"}
+		]
+	}`
+	var parsed any
+	if err := json.Unmarshal([]byte(envelope), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	names := newAvroNames(parsed)
+
+	connect := names.connectSchema(parsed, "")
+	fields := fieldSchemas(connect, config.FormatEnvelope)
+	if fields["price"].Name != "io.debezium.data.VariableScaleDecimal" {
+		t.Errorf("Fields[price] = %+v, want VariableScaleDecimal", fields["price"])
+	}
+
+	value := map[string]any{
+		"before": nil,
+		"after":  map[string]any{"id": float64(1), "price": map[string]any{"scale": float64(2), "value": "\u0004Ò"}},
+		"op":     "c",
+	}
+	names.encodeAvroBytes(parsed, value, "")
+	price := value["after"].(map[string]any)["price"].(map[string]any)
+	if price["value"] != "BNI=" {
+		t.Errorf("price value = %#v, want base64 BNI=", price["value"])
+	}
+}
@@ -0,0 +1,51 @@
+package consumer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+)
+
+// Decoder turns a raw Kafka message key or value into a generic map with
+// the same shape the Debezium JsonConverter would produce. A
+// {"schema", "payload"} wrapper, if any, is left for the caller to split.
+type Decoder interface {
+	Decode(data []byte) (map[string]any, error)
+}
+
+// newDecoder selects the decoder for the configured message encoding.
+func newDecoder(cfg *config.Config) Decoder {
+	if cfg.MessageEncoding == config.EncodingAvro {
+		registry := NewRegistryClient(cfg.SchemaRegistryURL, cfg.SchemaRegistryUser, cfg.SchemaRegistryPassword)
+		return NewAvroDecoder(registry)
+	}
+	return JSONDecoder{}
+}
+
+// JSONDecoder decodes JsonConverter output, with or without schemas.enable.
+type JSONDecoder struct{}
+
+// Decode implements Decoder. Numbers are kept as json.Number: nanosecond
+// timestamps and large BIGINTs do not fit in a float64.
+func (JSONDecoder) Decode(data []byte) (map[string]any, error) {
+	var value map[string]any
+	if err := unmarshalNumbers(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return value, nil
+}
+
+// unmarshalNumbers is json.Unmarshal with numbers decoded as json.Number.
+func unmarshalNumbers(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after top-level value")
+	}
+	return nil
+}
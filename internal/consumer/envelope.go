@@ -0,0 +1,199 @@
+package consumer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/models"
+)
+
+// splitSchema separates the {"schema": ..., "payload": ...} wrapper that
+// the JsonConverter adds when schemas.enable=true. Values without the
+// wrapper are returned unchanged with a nil schema.
+func splitSchema(value map[string]any) (map[string]any, map[string]any) {
+	if len(value) != 2 {
+		return value, nil
+	}
+	schema, ok := value["schema"].(map[string]any)
+	if !ok {
+		return value, nil
+	}
+	if inner, ok := value["payload"].(map[string]any); ok {
+		return inner, schema
+	}
+	return value, nil
+}
+
+// fieldSchemas returns the Connect schema of each row column. For the
+// envelope format the columns are described by the "after" (or "before")
+// struct; for unwrapped messages they are the top-level fields.
+func fieldSchemas(schema map[string]any, format config.MessageFormat) map[string]models.FieldSchema {
+	if schema == nil {
+		return nil
+	}
+
+	row := schema
+	if format == config.FormatEnvelope {
+		row = nil
+		for _, name := range []string{"after", "before"} {
+			if s := structField(schema, name); s != nil {
+				row = s
+				break
+			}
+		}
+		if row == nil {
+			return nil
+		}
+	}
+
+	fields, _ := row["fields"].([]any)
+	result := make(map[string]models.FieldSchema, len(fields))
+	for _, f := range fields {
+		field, ok := f.(map[string]any)
+		if !ok {
+			continue
+		}
+		column, _ := field["field"].(string)
+		if column == "" {
+			continue
+		}
+		fs := models.FieldSchema{}
+		fs.Type, _ = field["type"].(string)
+		fs.Name, _ = field["name"].(string)
+		if params, ok := field["parameters"].(map[string]any); ok {
+			fs.Parameters = make(map[string]string, len(params))
+			for k, v := range params {
+				if str, ok := v.(string); ok {
+					fs.Parameters[k] = str
+				}
+			}
+		}
+		result[column] = fs
+	}
+	return result
+}
+
+// structField returns the schema of the named struct field, if present.
+func structField(schema map[string]any, name string) map[string]any {
+	fields, _ := schema["fields"].([]any)
+	for _, f := range fields {
+		field, ok := f.(map[string]any)
+		if !ok {
+			continue
+		}
+		if field["field"] == name && field["type"] == "struct" {
+			return field
+		}
+	}
+	return nil
+}
+
+// parseUnwrapped fills the event from ExtractNewRecordState output, where
+// the row columns sit at the top level next to __-prefixed metadata fields.
+func parseUnwrapped(value map[string]any, event *models.CDCEvent) {
+	event.Payload = value
+
+	// Extract metadata fields (added by Debezium transform)
+	if op, ok := value["__op"].(string); ok {
+		event.Operation = op
+	}
+	if ts, ok := int64Field(value, "__ts_ms"); ok {
+		event.Timestamp = ts
+	}
+	if db, ok := value["__source_db"].(string); ok {
+		event.SourceDB = db
+	}
+	if table, ok := value["__source_table"].(string); ok {
+		event.SourceTable = table
+	}
+	if deleted, ok := value["__deleted"].(string); ok {
+		event.Deleted = deleted
+	}
+}
+
+// parseEnvelope fills the event from a raw Debezium change event
+// (before, after, source, op, ts_ms, transaction). The row image applied to
+// the target is "after", or "before" for deletes.
+func parseEnvelope(value map[string]any, event *models.CDCEvent) error {
+	op, ok := value["op"].(string)
+	if !ok {
+		return fmt.Errorf("missing op in Debezium envelope")
+	}
+	event.Operation = op
+
+	before, _ := value["before"].(map[string]any)
+	after, _ := value["after"].(map[string]any)
+	event.Before = before
+
+	if source, ok := value["source"].(map[string]any); ok {
+		event.SourceDB, _ = source["db"].(string)
+		event.SourceTable, _ = source["table"].(string)
+		event.Source = parseSource(source)
+	}
+
+	// source.ts_ms is when the change was made in the source database; the
+	// top-level ts_ms is when Debezium processed it, which lags behind
+	// during snapshots and catch-up.
+	if event.Source != nil && event.Source.TsMs > 0 {
+		event.Timestamp = event.Source.TsMs
+	} else if ts, ok := int64Field(value, "ts_ms"); ok {
+		event.Timestamp = ts
+	}
+
+	if tx, ok := value["transaction"].(map[string]any); ok {
+		event.TransactionID, _ = tx["id"].(string)
+	}
+
+	if event.GetOperation() == models.OperationDelete {
+		event.Payload = before
+		event.Deleted = "true"
+	} else {
+		event.Payload = after
+	}
+	if event.Payload == nil {
+		event.Payload = make(map[string]any)
+	}
+	return nil
+}
+
+// parseSource extracts the binlog position from the envelope's source block.
+func parseSource(source map[string]any) *models.SourceInfo {
+	info := &models.SourceInfo{}
+	info.File, _ = source["file"].(string)
+	info.GTID, _ = source["gtid"].(string)
+	if id, ok := int64Field(source, "server_id"); ok {
+		info.ServerID = id
+	}
+	if pos, ok := int64Field(source, "pos"); ok {
+		info.Pos = pos
+	}
+	if row, ok := int64Field(source, "row"); ok {
+		info.Row = int(row)
+	}
+	if ts, ok := int64Field(source, "ts_ms"); ok {
+		info.TsMs = ts
+	}
+	// snapshot is a string enum ("true", "last", "false") in recent
+	// Debezium versions and a boolean in older ones
+	switch v := source["snapshot"].(type) {
+	case string:
+		info.Snapshot = v
+	case bool:
+		info.Snapshot = fmt.Sprintf("%t", v)
+	}
+	return info
+}
+
+// int64Field reads an integer metadata field decoded as json.Number, or as
+// float64 by callers that build the map by hand.
+func int64Field(m map[string]any, key string) (int64, bool) {
+	switch v := m[key].(type) {
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
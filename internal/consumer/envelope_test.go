@@ -0,0 +1,227 @@
+package consumer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/internal/schema"
+)
+
+func newEnvelopeHandler() *consumerGroupHandler {
+	return newTestHandler(&config.Config{MessageFormat: config.FormatEnvelope})
+}
+
+func TestParseEvent_EnvelopeUpdate(t *testing.T) {
+	h := newEnvelopeHandler()
+
+	event, err := h.parseEvent(&sarama.ConsumerMessage{
+		Value: []byte(`{
+			"before": {"id": 1, "status": "pending"},
+			"after": {"id": 1, "status": "paid"},
+			"source": {"db": "pos", "table": "orders", "server_id": 118331, "file": "mysql-bin.000042", "pos": 1234, "row": 0, "snapshot": "false", "ts_ms": 1735689600000},
+			"op": "u",
+			"ts_ms": 1735689600123,
+			"transaction": {"id": "file=mysql-bin.000042,pos=1000", "total_order": 1}
+		}`),
+	})
+	if err != nil {
+		t.Fatalf("parseEvent() error = %v", err)
+	}
+
+	if event.GetOperation() != models.OperationUpdate {
+		t.Errorf("GetOperation() = %v, want UPDATE", event.GetOperation())
+	}
+	if event.Timestamp != 1735689600000 {
+		t.Errorf("Timestamp = %d, want source.ts_ms 1735689600000", event.Timestamp)
+	}
+	if event.SourceDB != "pos" || event.SourceTable != "orders" {
+		t.Errorf("source = %s.%s, want pos.orders", event.SourceDB, event.SourceTable)
+	}
+	if event.Payload["status"] != "paid" {
+		t.Errorf("Payload[status] = %v, want paid (after image)", event.Payload["status"])
+	}
+	if event.Before["status"] != "pending" {
+		t.Errorf("Before[status] = %v, want pending", event.Before["status"])
+	}
+	if event.Source == nil {
+		t.Fatal("Source should be set")
+	}
+	if event.Source.File != "mysql-bin.000042" || event.Source.Pos != 1234 {
+		t.Errorf("Source position = %s:%d, want mysql-bin.000042:1234", event.Source.File, event.Source.Pos)
+	}
+	if event.Source.ServerID != 118331 {
+		t.Errorf("Source.ServerID = %d, want 118331", event.Source.ServerID)
+	}
+	if event.TransactionID != "file=mysql-bin.000042,pos=1000" {
+		t.Errorf("TransactionID = %q", event.TransactionID)
+	}
+}
+
+func TestParseEvent_EnvelopeDeleteUsesBefore(t *testing.T) {
+	h := newEnvelopeHandler()
+
+	event, err := h.parseEvent(&sarama.ConsumerMessage{
+		Value: []byte(`{"before": {"id": 9, "status": "void"}, "after": null, "source": {"db": "pos", "table": "orders"}, "op": "d", "ts_ms": 1}`),
+	})
+	if err != nil {
+		t.Fatalf("parseEvent() error = %v", err)
+	}
+
+	if event.Deleted != "true" {
+		t.Errorf("Deleted = %q, want true", event.Deleted)
+	}
+	if event.Payload["id"] != json.Number("9") {
+		t.Errorf("Payload[id] = %v, want 9 (before image)", event.Payload["id"])
+	}
+	// Without source.ts_ms the top-level ts_ms is used
+	if event.Timestamp != 1 {
+		t.Errorf("Timestamp = %d, want top-level ts_ms 1", event.Timestamp)
+	}
+}
+
+func TestParseEvent_EnvelopeWithSchemas(t *testing.T) {
+	h := newEnvelopeHandler()
+
+	event, err := h.parseEvent(&sarama.ConsumerMessage{
+		Key:   []byte(`{"schema": {"type": "struct"}, "payload": {"id": 3}}`),
+		Value: []byte(`{"schema": {"type": "struct"}, "payload": {"before": null, "after": {"id": 3, "name": "Widget"}, "source": {"db": "pos", "table": "products", "snapshot": true}, "op": "r", "ts_ms": 5}}`),
+	})
+	if err != nil {
+		t.Fatalf("parseEvent() error = %v", err)
+	}
+
+	if event.GetOperation() != models.OperationInsert {
+		t.Errorf("GetOperation() = %v, want INSERT", event.GetOperation())
+	}
+	if event.Payload["name"] != "Widget" {
+		t.Errorf("Payload[name] = %v, want Widget", event.Payload["name"])
+	}
+	if event.Key["id"] != json.Number("3") {
+		t.Errorf("Key[id] = %v, want 3", event.Key["id"])
+	}
+	if event.Source.Snapshot != "true" {
+		t.Errorf("Source.Snapshot = %q, want true", event.Source.Snapshot)
+	}
+}
+
+func TestParseEvent_EnvelopeMissingOp(t *testing.T) {
+	h := newEnvelopeHandler()
+
+	if _, err := h.parseEvent(&sarama.ConsumerMessage{Value: []byte(`{"after": {"id": 1}}`)}); err == nil {
+		t.Error("parseEvent() should fail when op is missing")
+	}
+}
+
+func TestParseEvent_UnwrappedWithSchemas(t *testing.T) {
+	h := newTestHandler(&config.Config{})
+
+	event, err := h.parseEvent(&sarama.ConsumerMessage{
+		Value: []byte(`{"schema": {"type": "struct"}, "payload": {"id": 1, "__op": "c", "__source_table": "orders"}}`),
+	})
+	if err != nil {
+		t.Fatalf("parseEvent() error = %v", err)
+	}
+	if event.Operation != "c" || event.SourceTable != "orders" {
+		t.Errorf("event = %+v, want op c on orders", event)
+	}
+	if event.Payload["id"] != json.Number("1") {
+		t.Errorf("Payload[id] = %v, want 1", event.Payload["id"])
+	}
+}
+
+func TestParseEvent_ExactNumbers(t *testing.T) {
+	h := newTestHandler(&config.Config{})
+
+	event, err := h.parseEvent(&sarama.ConsumerMessage{
+		Value: []byte(`{"schema": {"type": "struct", "fields": [
+				{"field": "id", "type": "int64"},
+				{"field": "created_at", "type": "int64", "name": "io.debezium.time.NanoTimestamp"}
+			]},
+			"payload": {"id": 9007199254740993, "created_at": 1735734645123456789, "__op": "c", "__source_table": "orders"}}`),
+	})
+	if err != nil {
+		t.Fatalf("parseEvent() error = %v", err)
+	}
+	if event.Payload["id"] != json.Number("9007199254740993") {
+		t.Errorf("Payload[id] = %v, want 9007199254740993", event.Payload["id"])
+	}
+
+	// A float64 would round the nanoseconds to ...123456768
+	converter := schema.NewConverter(time.UTC, time.UTC, config.TargetPostgres)
+	got, ok := converter.ConvertLogical(event.Fields["created_at"], nil, event.Payload["created_at"])
+	if !ok {
+		t.Fatal("ConvertLogical() ok = false")
+	}
+	if want := time.Date(2025, 1, 1, 12, 30, 45, 123456789, time.UTC); got != want {
+		t.Errorf("created_at = %v, want %v", got, want)
+	}
+}
+
+func TestParseEvent_FieldSchemas(t *testing.T) {
+	tests := []struct {
+		name   string
+		format config.MessageFormat
+		value  string
+	}{
+		{
+			name:   "unwrapped",
+			format: config.FormatUnwrapped,
+			value: `{"schema": {"type": "struct", "fields": [
+				{"field": "id", "type": "int64"},
+				{"field": "created_at", "type": "int64", "name": "io.debezium.time.Timestamp"},
+				{"field": "total", "type": "bytes", "name": "org.apache.kafka.connect.data.Decimal", "parameters": {"scale": "2"}}
+			]}, "payload": {"id": 1, "created_at": 0, "total": "AQ==", "__op": "c"}}`,
+		},
+		{
+			name:   "envelope",
+			format: config.FormatEnvelope,
+			value: `{"schema": {"type": "struct", "fields": [
+				{"field": "before", "type": "struct", "fields": [{"field": "id", "type": "int64"}]},
+				{"field": "after", "type": "struct", "fields": [
+					{"field": "id", "type": "int64"},
+					{"field": "created_at", "type": "int64", "name": "io.debezium.time.Timestamp"},
+					{"field": "total", "type": "bytes", "name": "org.apache.kafka.connect.data.Decimal", "parameters": {"scale": "2"}}
+				]},
+				{"field": "op", "type": "string"}
+			]}, "payload": {"before": null, "after": {"id": 1, "created_at": 0, "total": "AQ=="}, "op": "c"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(&config.Config{MessageFormat: tt.format})
+
+			event, err := h.parseEvent(&sarama.ConsumerMessage{Value: []byte(tt.value)})
+			if err != nil {
+				t.Fatalf("parseEvent() error = %v", err)
+			}
+
+			if got := event.Fields["id"]; got.Type != "int64" || got.Name != "" {
+				t.Errorf("Fields[id] = %+v, want plain int64", got)
+			}
+			if got := event.Fields["created_at"].Name; got != "io.debezium.time.Timestamp" {
+				t.Errorf("Fields[created_at].Name = %q", got)
+			}
+			if got := event.Fields["total"].Parameters["scale"]; got != "2" {
+				t.Errorf("Fields[total] scale = %q, want 2", got)
+			}
+		})
+	}
+}
+
+func TestParseEvent_NoSchemaLeavesFieldsNil(t *testing.T) {
+	h := newTestHandler(&config.Config{})
+
+	event, err := h.parseEvent(&sarama.ConsumerMessage{Value: []byte(`{"id": 1, "__op": "c"}`)})
+	if err != nil {
+		t.Fatalf("parseEvent() error = %v", err)
+	}
+	if event.Fields != nil {
+		t.Errorf("Fields = %v, want nil", event.Fields)
+	}
+}
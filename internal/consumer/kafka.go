@@ -2,18 +2,17 @@ package consumer
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
-
-	//"maps"
-	//"slices"
-	"strings"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/IBM/sarama"
 	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/metrics"
 	"github.com/sparkiss/pos-cdc/internal/models"
 	"github.com/sparkiss/pos-cdc/pkg/logger"
 )
@@ -25,10 +24,26 @@ type Consumer struct {
 	config       *config.Config
 	client       sarama.ConsumerGroup
 	eventHandler func(*models.CDCEvent) error
+	deadLetter   DeadLetterFunc
+	decoder      Decoder
+	offsetLoader OffsetLoader
 	connected    bool
 	mu           sync.RWMutex
 }
 
+// ReasonParseError marks messages that could not be decoded into an event
+const ReasonParseError = "parse_error"
+
+// DeadLetterFunc persists an event that failed before it reached the pool.
+// An error means it was not kept and must not be acknowledged.
+type DeadLetterFunc func(event *models.CDCEvent, reason string, err error) error
+
+// OffsetLoader returns the last applied offset by topic and partition.
+// Implemented by writers that store offsets in the target database.
+type OffsetLoader interface {
+	LoadOffsets() (map[string]map[int32]int64, error)
+}
+
 func New(cfg *config.Config, handler func(*models.CDCEvent) error) (*Consumer, error) {
 	saramaCfg := sarama.NewConfig()
 	saramaCfg.Version = sarama.V2_8_0_0
@@ -49,9 +64,22 @@ func New(cfg *config.Config, handler func(*models.CDCEvent) error) (*Consumer, e
 		config:       cfg,
 		client:       client,
 		eventHandler: handler,
+		decoder:      newDecoder(cfg),
 	}, nil
 }
 
+// UseStoredOffsets makes every new session resume from the offsets stored in
+// the target database instead of the consumer group's committed offsets.
+func (c *Consumer) UseStoredOffsets(loader OffsetLoader) {
+	c.offsetLoader = loader
+}
+
+// UseDeadLetter sends messages that cannot be decoded to fn. Without it
+// such a message stops its claim and is consumed again.
+func (c *Consumer) UseDeadLetter(fn DeadLetterFunc) {
+	c.deadLetter = fn
+}
+
 func (c *Consumer) Start(ctx context.Context) error {
 	// Get list of CDC topics dynamically
 	topics, err := c.getTopics()
@@ -67,10 +95,20 @@ func (c *Consumer) Start(ctx context.Context) error {
 
 	handler := &consumerGroupHandler{
 		consumer: c,
+		stopping: ctx.Done(),
 	}
 
 	for {
-		err := c.client.Consume(ctx, topics, handler)
+		// Each Consume call runs with its own context so the topic watcher
+		// can end it and resubscribe when the topic set changes.
+		sessionCtx, cancelSession := context.WithCancel(ctx)
+		updates := make(chan []string, 1)
+		if c.config.TopicRefreshSec > 0 {
+			go c.watchTopics(sessionCtx, topics, updates, cancelSession)
+		}
+
+		err := c.client.Consume(sessionCtx, topics, handler)
+		cancelSession()
 		if err != nil {
 			logger.Log.Error("Consumer error", zap.Error(err))
 			return err
@@ -81,8 +119,67 @@ func (c *Consumer) Start(ctx context.Context) error {
 			return ctx.Err()
 		}
 
-		logger.Log.Info("Rabalance occurred, restarting consumer...")
+		select {
+		case newTopics := <-updates:
+			topics = newTopics
+			logger.Log.Info("Topic set changed, resubscribing",
+				zap.Int("topics", len(topics)))
+		default:
+			logger.Log.Info("Rabalance occurred, restarting consumer...")
+		}
+	}
+}
+
+// watchTopics periodically re-lists topics and, when the selected set
+// changes, publishes the new set and cancels the running session.
+func (c *Consumer) watchTopics(ctx context.Context, current []string, updates chan<- []string, cancelSession context.CancelFunc) {
+	ticker := time.NewTicker(time.Duration(c.config.TopicRefreshSec) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			topics, err := c.getTopics()
+			if err != nil {
+				logger.Log.Warn("Failed to refresh topics", zap.Error(err))
+				continue
+			}
+
+			added, removed := diffTopics(current, topics)
+			if len(added) == 0 && len(removed) == 0 {
+				continue
+			}
+
+			for _, t := range added {
+				logger.Log.Info("Discovered topic", zap.String("topic", t))
+				metrics.TopicsDiscovered.WithLabelValues(t).Inc()
+			}
+			for _, t := range removed {
+				logger.Log.Info("Topic removed", zap.String("topic", t))
+			}
+
+			updates <- topics
+			cancelSession()
+			return
+		}
+	}
+}
+
+// diffTopics returns topics present only in next (added) and only in prev (removed).
+func diffTopics(prev, next []string) (added, removed []string) {
+	for _, t := range next {
+		if !slices.Contains(prev, t) {
+			added = append(added, t)
+		}
+	}
+	for _, t := range prev {
+		if !slices.Contains(next, t) {
+			removed = append(removed, t)
+		}
 	}
+	return added, removed
 }
 
 func (c *Consumer) getTopics() ([]string, error) {
@@ -99,10 +196,11 @@ func (c *Consumer) getTopics() ([]string, error) {
 
 	var cdcTopics []string
 	for topic := range allTopics {
-		if strings.HasPrefix(topic, "pos_mysql.pos.") {
+		if c.config.IsTopicSelected(topic) {
 			cdcTopics = append(cdcTopics, topic)
 		}
 	}
+	slices.Sort(cdcTopics)
 
 	return cdcTopics, nil
 
@@ -132,16 +230,50 @@ func (c *Consumer) Close() error {
 // ======================================================
 type consumerGroupHandler struct {
 	consumer *Consumer
+	stopping <-chan struct{} // closed when the consumer is shutting down
 }
 
 // Setup is called a t the beginniong of a new session
 
 func (h *consumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	if h.consumer.offsetLoader != nil {
+		if err := h.seekStoredOffsets(session); err != nil {
+			return err
+		}
+	}
+
 	h.consumer.setConnected(true)
 	logger.Log.Info("Consumer session started", zap.Int32s("partitions", getPartitions(session.Claims())))
 	return nil
 }
 
+// seekStoredOffsets positions every claimed partition right after the last
+// offset applied to the target. Both calls are needed because sarama's
+// ResetOffset only moves backwards and MarkOffset only moves forwards.
+func (h *consumerGroupHandler) seekStoredOffsets(session sarama.ConsumerGroupSession) error {
+	stored, err := h.consumer.offsetLoader.LoadOffsets()
+	if err != nil {
+		return fmt.Errorf("failed to load stored offsets: %w", err)
+	}
+
+	for topic, partitions := range session.Claims() {
+		for _, partition := range partitions {
+			offset, ok := stored[topic][partition]
+			if !ok {
+				continue
+			}
+			next := offset + 1
+			session.ResetOffset(topic, partition, next, "")
+			session.MarkOffset(topic, partition, next, "")
+			logger.Log.Info("Resuming from stored offset",
+				zap.String("topic", topic),
+				zap.Int32("partition", partition),
+				zap.Int64("offset", next))
+		}
+	}
+	return nil
+}
+
 func getPartitions(claims map[string][]int32) []int32 {
 	var partitions []int32
 	for _, parts := range claims {
@@ -158,6 +290,17 @@ func (h *consumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) erro
 }
 
 func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	// Offsets are marked only after the pool acknowledges the event, so a
+	// crash before the target commit replays the message (at-least-once).
+	lag := newPartitionLag(claim.Topic(), claim.Partition(), claim.InitialOffset())
+	defer lag.Stop()
+
+	tracker := newOffsetTracker(func(offset int64) {
+		session.MarkOffset(claim.Topic(), claim.Partition(), offset+1, "")
+		lag.Committed(offset, claim.HighWaterMarkOffset())
+	})
+	defer h.awaitAcks(claim, tracker)
+
 	for {
 		select {
 		case <-session.Context().Done():
@@ -167,67 +310,175 @@ func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 				return nil
 			}
 
+			tracker.Add(message.Offset)
+			lag.Consumed(message.Offset, claim.HighWaterMarkOffset())
+
 			event, err := h.parseEvent(message)
 			if err != nil {
 				logger.Log.Error("Failed to parse event", zap.Error(err),
 					zap.String("topic", message.Topic),
 					zap.Int64("offset", message.Offset))
+				if dlqErr := h.deadLetterMessage(message, err); dlqErr != nil {
+					// Not kept anywhere: stop before its offset can be committed
+					tracker.Remove(message.Offset)
+					return dlqErr
+				}
+				tracker.Done(message.Offset)
 				continue
 			}
 
-			parts := strings.Split(message.Topic, ".")
-			if len(parts) >= 3 {
-				event.SourceTable = parts[2]
+			if event.Tombstone && h.consumer.config.TombstoneMode == config.TombstoneIgnore {
+				logger.Log.Debug("Ignoring tombstone",
+					zap.String("topic", message.Topic),
+					zap.Int64("offset", message.Offset))
+				tracker.Done(message.Offset)
+				continue
 			}
 
+			event.SourceTable = h.consumer.config.TableForTopic(message.Topic)
+			event.Topic = message.Topic
+			event.Partition = message.Partition
+			event.Offset = message.Offset
+			event.RawKey = message.Key
+			event.RawValue = message.Value
+			event.Headers = convertHeaders(message.Headers)
+
+			offset := message.Offset
+			event.SetAck(func() { tracker.Done(offset) })
+
 			logger.Log.Info("Event",
 				zap.String("table", event.SourceTable),
 				zap.String("op", event.GetOperation().String()))
 
 			if err := h.consumer.eventHandler(event); err != nil {
-				logger.Log.Error("Handler failed: %v", zap.Error(err))
-				continue
+				// The event was not applied: stop the claim so it is
+				// consumed again instead of committed
+				logger.Log.Error("Handler failed", zap.Error(err),
+					zap.String("topic", message.Topic),
+					zap.Int64("offset", message.Offset))
+				tracker.Remove(offset)
+				return fmt.Errorf("handler failed at %s/%d:%d: %w", message.Topic, message.Partition, offset, err)
 			}
-			session.MarkMessage(message, "")
 		}
 	}
 }
 
+// deadLetterMessage sends a message that could not be decoded to the DLQ
+// with its original key, value and headers
+func (h *consumerGroupHandler) deadLetterMessage(message *sarama.ConsumerMessage, parseErr error) error {
+	if h.consumer.deadLetter == nil {
+		return fmt.Errorf("no DLQ for undecodable message at %s/%d:%d: %w",
+			message.Topic, message.Partition, message.Offset, parseErr)
+	}
+	event := &models.CDCEvent{
+		SourceTable: h.consumer.config.TableForTopic(message.Topic),
+		Topic:       message.Topic,
+		Partition:   message.Partition,
+		Offset:      message.Offset,
+		RawKey:      message.Key,
+		RawValue:    message.Value,
+		Headers:     convertHeaders(message.Headers),
+	}
+	return h.consumer.deadLetter(event, ReasonParseError, parseErr)
+}
+
+// awaitAcks holds the end of a claim until the pool has acknowledged every
+// event handed to it. With stored offsets the next session seeks to the
+// offsets in the target, so an event still queued from this session would be
+// applied late and then consumed and applied again. It gives up when the
+// consumer is shutting down, since no session follows.
+func (h *consumerGroupHandler) awaitAcks(claim sarama.ConsumerGroupClaim, tracker *offsetTracker) {
+	if h.consumer.offsetLoader == nil || tracker.Pending() == 0 {
+		return
+	}
+
+	logger.Log.Info("Waiting for in-flight events before releasing partition",
+		zap.String("topic", claim.Topic()),
+		zap.Int32("partition", claim.Partition()),
+		zap.Int("pending", tracker.Pending()))
+	if !tracker.Wait(h.stopping) {
+		logger.Log.Warn("Released partition with events in flight",
+			zap.String("topic", claim.Topic()),
+			zap.Int32("partition", claim.Partition()),
+			zap.Int("pending", tracker.Pending()))
+	}
+}
+
+// convertHeaders copies sarama record headers into the event model.
+func convertHeaders(headers []*sarama.RecordHeader) []models.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	result := make([]models.Header, 0, len(headers))
+	for _, h := range headers {
+		if h == nil {
+			continue
+		}
+		result = append(result, models.Header{Key: string(h.Key), Value: h.Value})
+	}
+	return result
+}
+
 // parseEvent converts a Kafka message to a CDCEvent
 func (h *consumerGroupHandler) parseEvent(msg *sarama.ConsumerMessage) (*models.CDCEvent, error) {
+	key, err := h.parseKey(msg.Key)
+	if err != nil {
+		return nil, err
+	}
+
 	if msg.Value == nil {
+		// Tombstone: the key is all we have, so it doubles as the payload
 		return &models.CDCEvent{
 			Operation: "d",
 			Deleted:   "true",
+			Key:       key,
+			Tombstone: true,
+			Payload:   maps.Clone(key),
 		}, nil
 	}
 
-	// Parse JSON payload
-	var payload map[string]any
-	if err := json.Unmarshal(msg.Value, &payload); err != nil {
-		return nil, fmt.Errorf("failed to unmarsharl: %w", err)
+	decoded, err := h.consumer.decoder.Decode(msg.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode value: %w", err)
 	}
+	value, schema := splitSchema(decoded)
 
 	event := &models.CDCEvent{
-		Payload: payload,
+		Key: key,
 	}
 
-	// Extract metadata fields (added by Debezium transform)
-	if op, ok := payload["__op"].(string); ok {
-		event.Operation = op
-	}
-	if ts, ok := payload["__ts_ms"].(float64); ok {
-		event.Timestamp = int64(ts)
-	}
-	if db, ok := payload["__source_db"].(string); ok {
-		event.SourceDB = db
-	}
-	if table, ok := payload["__source_table"].(string); ok {
-		event.SourceTable = table
+	switch h.consumer.config.MessageFormat {
+	case config.FormatEnvelope:
+		if err := parseEnvelope(value, event); err != nil {
+			return nil, err
+		}
+	default:
+		parseUnwrapped(value, event)
 	}
-	if deleted, ok := payload["__deleted"].(string); ok {
-		event.Deleted = deleted
+	event.Fields = fieldSchemas(schema, h.consumer.config.MessageFormat)
+
+	// Key-only deletes carry the primary key in the message key only
+	if event.GetOperation() == models.OperationDelete {
+		for col, v := range key {
+			if _, ok := event.Payload[col]; !ok {
+				event.Payload[col] = v
+			}
+		}
 	}
 	return event, nil
 
 }
+
+// parseKey decodes the Debezium message key into its column values.
+func (h *consumerGroupHandler) parseKey(raw []byte) (map[string]any, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	decoded, err := h.consumer.decoder.Decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key: %w", err)
+	}
+	key, _ := splitSchema(decoded)
+	return key, nil
+}
@@ -0,0 +1,409 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/pkg/logger"
+)
+
+// TestMain sets up the logger for all tests in this package
+func TestMain(m *testing.M) {
+	// Initialize logger to avoid nil pointer
+	_ = logger.Init("error", "text")
+	os.Exit(m.Run())
+}
+
+// newTestHandler creates a handler decoding JSON messages
+func newTestHandler(cfg *config.Config) *consumerGroupHandler {
+	return &consumerGroupHandler{consumer: &Consumer{config: cfg, decoder: JSONDecoder{}}}
+}
+
+// fakeSession records offset calls made on a consumer group session
+type fakeSession struct {
+	claims map[string][]int32
+	reset  map[string]map[int32]int64
+	marked map[string]map[int32]int64
+}
+
+func newFakeSession(claims map[string][]int32) *fakeSession {
+	return &fakeSession{
+		claims: claims,
+		reset:  make(map[string]map[int32]int64),
+		marked: make(map[string]map[int32]int64),
+	}
+}
+
+func (s *fakeSession) Claims() map[string][]int32 { return s.claims }
+func (s *fakeSession) MemberID() string           { return "test-member" }
+func (s *fakeSession) GenerationID() int32        { return 1 }
+func (s *fakeSession) Commit()                    {}
+func (s *fakeSession) Context() context.Context   { return context.Background() }
+
+func (s *fakeSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	if s.marked[topic] == nil {
+		s.marked[topic] = make(map[int32]int64)
+	}
+	s.marked[topic][partition] = offset
+}
+
+func (s *fakeSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+	if s.reset[topic] == nil {
+		s.reset[topic] = make(map[int32]int64)
+	}
+	s.reset[topic][partition] = offset
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+
+// fakeLoader returns fixed stored offsets
+type fakeLoader struct {
+	offsets map[string]map[int32]int64
+	err     error
+}
+
+func (l *fakeLoader) LoadOffsets() (map[string]map[int32]int64, error) {
+	return l.offsets, l.err
+}
+
+func TestSetup_SeeksToStoredOffsets(t *testing.T) {
+	c := &Consumer{}
+	c.UseStoredOffsets(&fakeLoader{offsets: map[string]map[int32]int64{
+		"pos_mysql.pos.orders": {0: 41},
+		"pos_mysql.pos.other":  {0: 99}, // not claimed by this session
+	}})
+	h := &consumerGroupHandler{consumer: c}
+
+	session := newFakeSession(map[string][]int32{
+		"pos_mysql.pos.orders": {0},
+		"pos_mysql.pos.items":  {0}, // nothing stored yet
+	})
+
+	if err := h.Setup(session); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	// Next offset to consume is one past the last applied offset
+	if got := session.reset["pos_mysql.pos.orders"][0]; got != 42 {
+		t.Errorf("ResetOffset(orders/0) = %d, want 42", got)
+	}
+	if got := session.marked["pos_mysql.pos.orders"][0]; got != 42 {
+		t.Errorf("MarkOffset(orders/0) = %d, want 42", got)
+	}
+	if _, ok := session.marked["pos_mysql.pos.items"]; ok {
+		t.Error("partition without stored offset should keep the group offset")
+	}
+	if _, ok := session.marked["pos_mysql.pos.other"]; ok {
+		t.Error("unclaimed partition should not be touched")
+	}
+	if !c.IsConnected() {
+		t.Error("consumer should be connected after Setup")
+	}
+}
+
+func TestSetup_LoadOffsetsError(t *testing.T) {
+	c := &Consumer{}
+	c.UseStoredOffsets(&fakeLoader{err: errors.New("connection refused")})
+	h := &consumerGroupHandler{consumer: c}
+
+	if err := h.Setup(newFakeSession(map[string][]int32{"t": {0}})); err == nil {
+		t.Error("Setup() should fail when stored offsets cannot be loaded")
+	}
+	if c.IsConnected() {
+		t.Error("consumer should not be connected after failed Setup")
+	}
+}
+
+func TestSetup_WithoutStoredOffsets(t *testing.T) {
+	c := &Consumer{}
+	h := &consumerGroupHandler{consumer: c}
+	session := newFakeSession(map[string][]int32{"pos_mysql.pos.orders": {0}})
+
+	if err := h.Setup(session); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if len(session.marked) != 0 || len(session.reset) != 0 {
+		t.Error("Setup() should not move offsets when no loader is configured")
+	}
+}
+
+// fakeClaim delivers messages from a channel
+type fakeClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Topic() string                            { return "pos_mysql.pos.orders" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 2 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestConsumeClaim_WaitsForAcksWithStoredOffsets(t *testing.T) {
+	cfg := &config.Config{TombstoneMode: config.TombstoneIgnore}
+	var mu sync.Mutex
+	var queued []*models.CDCEvent
+	c := &Consumer{config: cfg, decoder: JSONDecoder{}, eventHandler: func(e *models.CDCEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		queued = append(queued, e)
+		return nil
+	}}
+	c.UseStoredOffsets(&fakeLoader{})
+	h := &consumerGroupHandler{consumer: c, stopping: make(chan struct{})}
+
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+	for offset := range int64(2) {
+		claim.messages <- &sarama.ConsumerMessage{
+			Topic:  claim.Topic(),
+			Offset: offset,
+			Value:  []byte(`{"id": 1, "__op": "u"}`),
+		}
+	}
+	close(claim.messages)
+
+	done := make(chan struct{})
+	go func() {
+		_ = h.ConsumeClaim(newFakeSession(nil), claim)
+		close(done)
+	}()
+
+	// The claim is held while the pool still has its events, so the next
+	// session cannot seek past them
+	select {
+	case <-done:
+		t.Fatal("ConsumeClaim() returned with events in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mu.Lock()
+	for _, e := range queued {
+		e.Ack()
+	}
+	mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ConsumeClaim() did not return after every event was acknowledged")
+	}
+}
+
+func TestConsumeClaim_ParseFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		dlqErr     error
+		wantErr    bool
+		wantMarked bool
+	}{
+		{"dead-lettered then committed", nil, false, true},
+		{"not committed when the DLQ fails", errors.New("disk full"), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dead []*models.CDCEvent
+			c := &Consumer{config: &config.Config{}, decoder: JSONDecoder{}}
+			c.UseDeadLetter(func(e *models.CDCEvent, reason string, err error) error {
+				if reason != ReasonParseError {
+					t.Errorf("reason = %q, want %q", reason, ReasonParseError)
+				}
+				dead = append(dead, e)
+				return tt.dlqErr
+			})
+			h := &consumerGroupHandler{consumer: c, stopping: make(chan struct{})}
+
+			claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+			claim.messages <- &sarama.ConsumerMessage{Topic: claim.Topic(), Offset: 0, Value: []byte(`not json`)}
+			close(claim.messages)
+
+			session := newFakeSession(nil)
+			err := h.ConsumeClaim(session, claim)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConsumeClaim() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(dead) != 1 || string(dead[0].RawValue) != "not json" || dead[0].Topic != claim.Topic() {
+				t.Fatalf("dead-lettered = %+v, want the original message", dead)
+			}
+			_, marked := session.marked[claim.Topic()][0]
+			if marked != tt.wantMarked {
+				t.Errorf("offset marked = %v, want %v", marked, tt.wantMarked)
+			}
+		})
+	}
+}
+
+func TestConsumeClaim_HandlerErrorNotAcked(t *testing.T) {
+	c := &Consumer{config: &config.Config{}, decoder: JSONDecoder{}, eventHandler: func(*models.CDCEvent) error {
+		return errors.New("queue closed")
+	}}
+	h := &consumerGroupHandler{consumer: c, stopping: make(chan struct{})}
+
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: claim.Topic(), Offset: 0, Value: []byte(`{"id": 1, "__op": "u"}`)}
+	close(claim.messages)
+
+	session := newFakeSession(nil)
+	if err := h.ConsumeClaim(session, claim); err == nil {
+		t.Error("ConsumeClaim() error = nil, want the handler's error")
+	}
+	if offsets := session.marked[claim.Topic()]; len(offsets) != 0 {
+		t.Errorf("marked = %v, want nothing committed", offsets)
+	}
+}
+
+func TestDiffTopics(t *testing.T) {
+	prev := []string{"pos_mysql.pos.items", "pos_mysql.pos.orders"}
+	next := []string{"pos_mysql.pos.customers", "pos_mysql.pos.orders"}
+
+	added, removed := diffTopics(prev, next)
+
+	if want := []string{"pos_mysql.pos.customers"}; !slices.Equal(added, want) {
+		t.Errorf("added = %v, want %v", added, want)
+	}
+	if want := []string{"pos_mysql.pos.items"}; !slices.Equal(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+
+	added, removed = diffTopics(prev, prev)
+	if len(added) != 0 || len(removed) != 0 {
+		t.Errorf("diffTopics() on identical sets = %v, %v, want none", added, removed)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	h := newTestHandler(&config.Config{})
+
+	tests := []struct {
+		name string
+		raw  string
+		want map[string]any
+	}{
+		{"plain key", `{"id": 5}`, map[string]any{"id": json.Number("5")}},
+		{"schema envelope", `{"schema": {"type": "struct"}, "payload": {"id": 5, "store_id": 2}}`, map[string]any{"id": json.Number("5"), "store_id": json.Number("2")}},
+		{"empty key", ``, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.parseKey([]byte(tt.raw))
+			if err != nil {
+				t.Fatalf("parseKey() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseKey() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("parseKey()[%s] = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+
+	// A key column that happens to be called "payload" is not an envelope
+	got, err := h.parseKey([]byte(`{"payload": {"x": 1}}`))
+	if err != nil {
+		t.Fatalf("parseKey() error = %v", err)
+	}
+	if _, ok := got["payload"]; !ok {
+		t.Errorf("parseKey() = %v, want the payload column kept", got)
+	}
+
+	if _, err := h.parseKey([]byte(`not json`)); err == nil {
+		t.Error("parseKey() should return error for invalid JSON")
+	}
+}
+
+func TestParseEvent_Tombstone(t *testing.T) {
+	h := newTestHandler(&config.Config{})
+
+	event, err := h.parseEvent(&sarama.ConsumerMessage{
+		Topic: "pos_mysql.pos.orders",
+		Key:   []byte(`{"id": 42}`),
+		Value: nil,
+	})
+	if err != nil {
+		t.Fatalf("parseEvent() error = %v", err)
+	}
+
+	if !event.Tombstone {
+		t.Error("Tombstone should be true for a null value")
+	}
+	if event.GetOperation() != models.OperationDelete {
+		t.Errorf("GetOperation() = %v, want DELETE", event.GetOperation())
+	}
+	if event.Payload["id"] != json.Number("42") {
+		t.Errorf("Payload[id] = %v, want 42 (from key)", event.Payload["id"])
+	}
+}
+
+func TestParseEvent_KeyOnlyDelete(t *testing.T) {
+	h := newTestHandler(&config.Config{})
+
+	event, err := h.parseEvent(&sarama.ConsumerMessage{
+		Topic: "pos_mysql.pos.orders",
+		Key:   []byte(`{"id": 7}`),
+		Value: []byte(`{"__op": "d", "__deleted": "true"}`),
+	})
+	if err != nil {
+		t.Fatalf("parseEvent() error = %v", err)
+	}
+
+	if event.Tombstone {
+		t.Error("Tombstone should be false when a value is present")
+	}
+	if event.Payload["id"] != json.Number("7") {
+		t.Errorf("Payload[id] = %v, want 7 (from key)", event.Payload["id"])
+	}
+	if event.Key["id"] != json.Number("7") {
+		t.Errorf("Key[id] = %v, want 7", event.Key["id"])
+	}
+}
+
+func TestParseEvent_KeyDoesNotOverridePayload(t *testing.T) {
+	h := newTestHandler(&config.Config{})
+
+	event, err := h.parseEvent(&sarama.ConsumerMessage{
+		Key:   []byte(`{"id": 7}`),
+		Value: []byte(`{"__op": "u", "id": 8, "status": "paid"}`),
+	})
+	if err != nil {
+		t.Fatalf("parseEvent() error = %v", err)
+	}
+	if event.Payload["id"] != json.Number("8") {
+		t.Errorf("Payload[id] = %v, want 8", event.Payload["id"])
+	}
+}
+
+func TestConvertHeaders(t *testing.T) {
+	if got := convertHeaders(nil); got != nil {
+		t.Errorf("convertHeaders(nil) = %v, want nil", got)
+	}
+
+	got := convertHeaders([]*sarama.RecordHeader{
+		{Key: []byte("trace-id"), Value: []byte("abc")},
+		nil,
+		{Key: []byte("empty")},
+	})
+	if len(got) != 2 {
+		t.Fatalf("convertHeaders() returned %d headers, want 2", len(got))
+	}
+	if got[0].Key != "trace-id" || string(got[0].Value) != "abc" {
+		t.Errorf("header[0] = %+v, want trace-id=abc", got[0])
+	}
+	if got[1].Key != "empty" || got[1].Value != nil {
+		t.Errorf("header[1] = %+v, want empty with nil value", got[1])
+	}
+}
@@ -0,0 +1,82 @@
+package consumer
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+)
+
+// partitionLag reports how far a claim is behind the partition's high-water
+// mark, both for the last consumed offset and the last committed one. The
+// committed lag is what matters for replication: it only shrinks once the
+// target database has applied the events.
+type partitionLag struct {
+	mu        sync.Mutex
+	topic     string
+	partition string
+	next      int64 // next offset to commit, negative until known
+	stopped   bool
+}
+
+// newPartitionLag creates the lag gauges for a claim. initialOffset is the
+// claim's starting offset, which may be a sarama sentinel (OffsetOldest or
+// OffsetNewest) when the group has not committed yet.
+func newPartitionLag(topic string, partition int32, initialOffset int64) *partitionLag {
+	return &partitionLag{
+		topic:     topic,
+		partition: strconv.Itoa(int(partition)),
+		next:      initialOffset,
+	}
+}
+
+// Consumed records that offset was read from the claim.
+func (l *partitionLag) Consumed(offset, highWaterMark int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return
+	}
+	l.gauge(metrics.ConsumerLag).Set(lagBehind(highWaterMark, offset+1))
+	if l.next >= 0 {
+		l.gauge(metrics.ConsumerCommittedLag).Set(lagBehind(highWaterMark, l.next))
+	}
+}
+
+// Committed records that offset was marked for commit.
+func (l *partitionLag) Committed(offset, highWaterMark int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return
+	}
+	l.next = offset + 1
+	l.gauge(metrics.ConsumerCommittedLag).Set(lagBehind(highWaterMark, l.next))
+}
+
+// Stop removes the gauges once the claim ends, so a partition that moved to
+// another consumer does not keep reporting a stale value. Acknowledgements
+// that arrive afterwards are ignored.
+func (l *partitionLag) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stopped = true
+	metrics.ConsumerLag.DeleteLabelValues(l.topic, l.partition)
+	metrics.ConsumerCommittedLag.DeleteLabelValues(l.topic, l.partition)
+}
+
+// gauge returns this claim's series, created on first use so that an
+// unknown committed position is absent rather than reported as zero.
+func (l *partitionLag) gauge(vec *prometheus.GaugeVec) prometheus.Gauge {
+	return vec.WithLabelValues(l.topic, l.partition)
+}
+
+// lagBehind returns the number of messages from next up to the high-water mark.
+func lagBehind(highWaterMark, next int64) float64 {
+	if highWaterMark <= next {
+		return 0
+	}
+	return float64(highWaterMark - next)
+}
@@ -0,0 +1,74 @@
+package consumer
+
+import (
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+)
+
+func TestPartitionLag(t *testing.T) {
+	lag := newPartitionLag("lag.orders", 3, 100)
+	defer lag.Stop()
+
+	consumed := metrics.ConsumerLag.WithLabelValues("lag.orders", "3")
+	committed := metrics.ConsumerCommittedLag.WithLabelValues("lag.orders", "3")
+
+	// Read offset 104 of a partition whose next offset is 110
+	lag.Consumed(104, 110)
+	if got := testutil.ToFloat64(consumed); got != 5 {
+		t.Errorf("consumed lag = %v, want 5", got)
+	}
+	// Nothing committed yet, so the committed lag counts from the initial offset
+	if got := testutil.ToFloat64(committed); got != 10 {
+		t.Errorf("committed lag = %v, want 10", got)
+	}
+
+	lag.Committed(102, 112)
+	if got := testutil.ToFloat64(committed); got != 9 {
+		t.Errorf("committed lag = %v, want 9", got)
+	}
+
+	// Caught up
+	lag.Consumed(111, 112)
+	lag.Committed(111, 112)
+	if got := testutil.ToFloat64(consumed); got != 0 {
+		t.Errorf("consumed lag = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(committed); got != 0 {
+		t.Errorf("committed lag = %v, want 0", got)
+	}
+}
+
+func TestPartitionLag_UnknownInitialOffset(t *testing.T) {
+	lag := newPartitionLag("lag.unknown", 0, sarama.OffsetOldest)
+	defer lag.Stop()
+
+	lag.Consumed(0, 50)
+	if got := testutil.ToFloat64(metrics.ConsumerLag.WithLabelValues("lag.unknown", "0")); got != 49 {
+		t.Errorf("consumed lag = %v, want 49", got)
+	}
+	// The committed gauge stays unset until the first commit
+	if n := testutil.CollectAndCount(metrics.ConsumerCommittedLag, "cdc_consumer_committed_lag"); n != 0 {
+		t.Errorf("committed lag series = %d, want 0", n)
+	}
+}
+
+func TestPartitionLag_StopRemovesGauges(t *testing.T) {
+	lag := newPartitionLag("lag.stopped", 1, 0)
+	lag.Consumed(5, 10)
+	lag.Committed(5, 10)
+	lag.Stop()
+
+	// A late acknowledgement after the claim ended must not recreate the series
+	lag.Committed(6, 10)
+
+	if n := testutil.CollectAndCount(metrics.ConsumerLag, "cdc_consumer_lag"); n != 0 {
+		t.Errorf("consumer lag series = %d, want 0", n)
+	}
+	if n := testutil.CollectAndCount(metrics.ConsumerCommittedLag, "cdc_consumer_committed_lag"); n != 0 {
+		t.Errorf("committed lag series = %d, want 0", n)
+	}
+}
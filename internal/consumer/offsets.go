@@ -0,0 +1,89 @@
+package consumer
+
+import (
+	"sync"
+	"time"
+)
+
+// drainPollInterval is how often Wait checks for outstanding offsets
+const drainPollInterval = 20 * time.Millisecond
+
+// offsetTracker tracks in-flight offsets for a single topic/partition claim.
+// Events can finish out of order (e.g. a DLQ write racing a batch commit), so
+// an offset is only reported as committable once every offset before it has
+// also been acknowledged.
+type offsetTracker struct {
+	mu      sync.Mutex
+	pending []int64        // offsets handed to the pool, in arrival order
+	done    map[int64]bool // acknowledged offsets not yet at the head of pending
+	commit  func(offset int64)
+}
+
+// newOffsetTracker creates a tracker that calls commit with the highest
+// offset whose predecessors have all been acknowledged.
+func newOffsetTracker(commit func(offset int64)) *offsetTracker {
+	return &offsetTracker{
+		done:   make(map[int64]bool),
+		commit: commit,
+	}
+}
+
+// Add registers an offset as in flight. Offsets must be added in increasing order.
+func (t *offsetTracker) Add(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, offset)
+}
+
+// Done acknowledges an offset and commits the contiguous prefix of
+// acknowledged offsets, if any.
+func (t *offsetTracker) Done(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done[offset] = true
+
+	committable := int64(-1)
+	for len(t.pending) > 0 && t.done[t.pending[0]] {
+		committable = t.pending[0]
+		delete(t.done, committable)
+		t.pending = t.pending[1:]
+	}
+
+	if committable >= 0 {
+		t.commit(committable)
+	}
+}
+
+// Remove forgets the last added offset, which will never be acknowledged.
+// Nothing from it onwards is committed.
+func (t *offsetTracker) Remove(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n := len(t.pending); n > 0 && t.pending[n-1] == offset {
+		t.pending = t.pending[:n-1]
+	}
+}
+
+// Pending returns the number of offsets not yet committable.
+func (t *offsetTracker) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// Wait blocks until every added offset has been acknowledged, or until stop
+// is closed. It reports whether the tracker drained.
+func (t *offsetTracker) Wait(stop <-chan struct{}) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for t.Pending() > 0 {
+		select {
+		case <-stop:
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package consumer
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOffsetTracker_InOrder(t *testing.T) {
+	var committed []int64
+	tracker := newOffsetTracker(func(offset int64) {
+		committed = append(committed, offset)
+	})
+
+	for _, o := range []int64{10, 11, 12} {
+		tracker.Add(o)
+	}
+	for _, o := range []int64{10, 11, 12} {
+		tracker.Done(o)
+	}
+
+	if want := []int64{10, 11, 12}; !slices.Equal(committed, want) {
+		t.Errorf("committed = %v, want %v", committed, want)
+	}
+	if n := tracker.Pending(); n != 0 {
+		t.Errorf("Pending() = %d, want 0", n)
+	}
+}
+
+func TestOffsetTracker_OutOfOrder(t *testing.T) {
+	var committed []int64
+	tracker := newOffsetTracker(func(offset int64) {
+		committed = append(committed, offset)
+	})
+
+	for _, o := range []int64{5, 6, 7, 8} {
+		tracker.Add(o)
+	}
+
+	// Later offsets finishing first must not be committed
+	tracker.Done(7)
+	tracker.Done(6)
+	if len(committed) != 0 {
+		t.Fatalf("committed = %v before head was acknowledged, want none", committed)
+	}
+
+	// Acknowledging the head releases the contiguous prefix in one commit
+	tracker.Done(5)
+	if want := []int64{7}; !slices.Equal(committed, want) {
+		t.Errorf("committed = %v, want %v", committed, want)
+	}
+	if n := tracker.Pending(); n != 1 {
+		t.Errorf("Pending() = %d, want 1", n)
+	}
+
+	tracker.Done(8)
+	if want := []int64{7, 8}; !slices.Equal(committed, want) {
+		t.Errorf("committed = %v, want %v", committed, want)
+	}
+}
+
+func TestOffsetTracker_GapsInOffsets(t *testing.T) {
+	// Compacted topics and transaction markers leave gaps between offsets
+	var committed []int64
+	tracker := newOffsetTracker(func(offset int64) {
+		committed = append(committed, offset)
+	})
+
+	tracker.Add(100)
+	tracker.Add(105)
+	tracker.Done(105)
+	tracker.Done(100)
+
+	if want := []int64{105}; !slices.Equal(committed, want) {
+		t.Errorf("committed = %v, want %v", committed, want)
+	}
+}
+
+func TestOffsetTracker_Wait(t *testing.T) {
+	tracker := newOffsetTracker(func(int64) {})
+	tracker.Add(1)
+	tracker.Add(2)
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		tracker.Done(2)
+		tracker.Done(1)
+	}()
+	if !tracker.Wait(make(chan struct{})) {
+		t.Fatal("Wait() = false, want true once every offset is acknowledged")
+	}
+	if n := tracker.Pending(); n != 0 {
+		t.Errorf("Pending() = %d after Wait, want 0", n)
+	}
+
+	// Stopping gives up on offsets that are never acknowledged
+	tracker.Add(3)
+	stop := make(chan struct{})
+	close(stop)
+	if tracker.Wait(stop) {
+		t.Error("Wait() = true with an offset in flight, want false after stop")
+	}
+}
@@ -0,0 +1,64 @@
+package consumer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SchemaRegistry resolves schema IDs to Avro schema definitions.
+type SchemaRegistry interface {
+	Schema(id uint32) (string, error)
+}
+
+// RegistryClient talks to a Confluent-compatible schema registry
+// (Confluent, Redpanda, Apicurio in ccompat mode).
+type RegistryClient struct {
+	baseURL  string
+	user     string
+	password string
+	http     *http.Client
+}
+
+// NewRegistryClient creates a registry client. User and password are
+// optional and sent as basic auth when set.
+func NewRegistryClient(baseURL, user, password string) *RegistryClient {
+	return &RegistryClient{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		user:     user,
+		password: password,
+		http:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Schema fetches the schema registered under id.
+func (c *RegistryClient) Schema(id uint32) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/schemas/ids/%d", c.baseURL, id), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("schema registry returned %s", resp.Status)
+	}
+
+	var body struct {
+		Schema string `json:"schema"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode registry response: %w", err)
+	}
+	return body.Schema, nil
+}
@@ -0,0 +1,181 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/pool"
+)
+
+const (
+	// defaultDLQLimit caps /dlq/entries when no limit is given
+	defaultDLQLimit = 100
+	// maxDLQLimit bounds the entries /dlq/entries holds in memory
+	maxDLQLimit = 1000
+)
+
+// dlqStats is the /dlq response
+type dlqStats struct {
+	pool.DLQStats
+	// Fallback is set when the file is the fallback of another DLQ sink,
+	// so it only holds the entries that sink failed to take
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// handleDLQStats summarizes DLQ entries by table, operation, reason and
+// error. Without filters it answers from the DLQ's counters; filters are
+// applied by scanning the file.
+func (s *Server) handleDLQStats(w http.ResponseWriter, r *http.Request) {
+	dlq, filter, ok := s.dlqRequest(w, r)
+	if !ok {
+		return
+	}
+	fallback := dlq.FileIsFallback()
+
+	if filter == (pool.DLQFilter{}) {
+		writeJSON(w, http.StatusOK, dlqStats{DLQStats: dlq.Stats(), Fallback: fallback})
+		return
+	}
+
+	path, ok := dlqFile(w, dlq)
+	if !ok {
+		return
+	}
+	stats, err := pool.SummarizeDLQFiles(path, filter)
+	if errors.Is(err, fs.ErrNotExist) {
+		stats, err = pool.NewDLQStats(), nil
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, dlqStats{DLQStats: stats, Fallback: fallback})
+}
+
+// handleDLQEntries lists the most recent matching entries.
+func (s *Server) handleDLQEntries(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDLQLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+	if limit == 0 || limit > maxDLQLimit {
+		limit = maxDLQLimit
+	}
+
+	dlq, filter, ok := s.dlqRequest(w, r)
+	if !ok {
+		return
+	}
+	path, ok := dlqFile(w, dlq)
+	if !ok {
+		return
+	}
+
+	entries, err := pool.FilterDLQFiles(path, filter, limit)
+	if errors.Is(err, fs.ErrNotExist) {
+		entries, err = nil, nil
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// handleDLQEntry returns a single entry by its 1-based index.
+func (s *Server) handleDLQEntry(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid index"})
+		return
+	}
+
+	dlq, _, ok := s.dlqRequest(w, r)
+	if !ok {
+		return
+	}
+	path, ok := dlqFile(w, dlq)
+	if !ok {
+		return
+	}
+
+	entry, found, err := pool.DLQEntryAt(path, index)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such entry"})
+		return
+	}
+	writeJSON(w, http.StatusOK, pool.IndexedEntry{Index: index, DLQEntry: entry})
+}
+
+// dlqRequest returns the DLQ and the filter from the query string. It writes
+// the error response itself and returns false when the request cannot proceed.
+func (s *Server) dlqRequest(w http.ResponseWriter, r *http.Request) (*pool.DLQ, pool.DLQFilter, bool) {
+	filter, err := parseDLQFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return nil, filter, false
+	}
+
+	s.mu.RLock()
+	dlq := s.dlq
+	s.mu.RUnlock()
+	if dlq == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "DLQ not configured"})
+		return nil, filter, false
+	}
+	return dlq, filter, true
+}
+
+// dlqFile returns the DLQ's file, whose rotated files are read along with
+// it, or writes a 404 when entries are not written to a file.
+func dlqFile(w http.ResponseWriter, dlq *pool.DLQ) (string, bool) {
+	path := dlq.Path()
+	if path == "" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "DLQ has no file"})
+		return "", false
+	}
+	return path, true
+}
+
+// parseDLQFilter reads table, op, error, since and until query parameters.
+func parseDLQFilter(r *http.Request) (pool.DLQFilter, error) {
+	q := r.URL.Query()
+	filter := pool.DLQFilter{
+		Table:         q.Get("table"),
+		Op:            q.Get("op"),
+		ErrorContains: q.Get("error"),
+	}
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	return filter, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
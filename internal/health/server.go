@@ -11,6 +11,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
+	"github.com/sparkiss/pos-cdc/internal/pool"
 	"github.com/sparkiss/pos-cdc/pkg/logger"
 )
 
@@ -20,6 +21,7 @@ type Server struct {
 	mu         sync.RWMutex
 	ready      bool
 	lastChecks map[string]CheckResult
+	dlq        *pool.DLQ
 }
 
 // CheckResult holds health check result
@@ -46,6 +48,11 @@ func New(port int) *Server {
 	// Metrics endpoint - for Prometheus
 	mux.Handle("/metrics", promhttp.Handler())
 
+	// DLQ inspection - summary, entry list and single entries
+	mux.HandleFunc("GET /dlq", s.handleDLQStats)
+	mux.HandleFunc("GET /dlq/entries", s.handleDLQEntries)
+	mux.HandleFunc("GET /dlq/entries/{index}", s.handleDLQEntry)
+
 	s.httpServer = &http.Server{
 		Addr:              fmt.Sprintf(":%d", port),
 		Handler:           mux,
@@ -84,6 +91,14 @@ func (s *Server) UpdateCheck(name string, result CheckResult) {
 	s.lastChecks[name] = result
 }
 
+// SetDLQ sets the DLQ served by the /dlq endpoints. Its counters answer
+// /dlq; its file, if any, is read for filters and entries.
+func (s *Server) SetDLQ(dlq *pool.DLQ) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dlq = dlq
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	// Liveness: just return OK if process is running
 	w.Header().Set("Content-Type", "application/json")
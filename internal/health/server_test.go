@@ -2,12 +2,25 @@ package health
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/internal/pool"
+	"github.com/sparkiss/pos-cdc/pkg/logger"
 )
 
+func TestMain(m *testing.M) {
+	// Initialize logger to avoid nil pointer
+	_ = logger.Init("error", "text")
+	os.Exit(m.Run())
+}
+
 func TestNew(t *testing.T) {
 	s := New(8081)
 	if s == nil {
@@ -311,3 +324,154 @@ func TestServer_HTTPMux(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_DLQEndpoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	err := pool.WriteDLQ(path, []pool.DLQEntry{
+		{Event: &models.CDCEvent{Operation: "c", SourceTable: "orders"}, Error: "duplicate key", Timestamp: at},
+		{Event: &models.CDCEvent{Operation: "u", SourceTable: "items"}, Error: "deadlock", Timestamp: at.Add(time.Hour)},
+		{Event: &models.CDCEvent{Operation: "d", SourceTable: "orders"}, Error: "duplicate key", Timestamp: at.Add(2 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("WriteDLQ() error = %v", err)
+	}
+
+	sink, err := pool.NewFileSink(path, pool.RotationPolicy{})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	dlq := pool.NewDLQWithSink(sink)
+	defer dlq.Close()
+	if err := dlq.LoadStats(); err != nil {
+		t.Fatalf("LoadStats() error = %v", err)
+	}
+
+	s := New(8081)
+	s.SetDLQ(dlq)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("stats", func(t *testing.T) {
+		w := get("/dlq")
+		if w.Code != http.StatusOK {
+			t.Fatalf("StatusCode = %d, want 200", w.Code)
+		}
+		var stats pool.DLQStats
+		if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if stats.Total != 3 || stats.ByTable["orders"] != 2 || stats.ByError["duplicate key"] != 2 {
+			t.Errorf("stats = %+v", stats)
+		}
+	})
+
+	t.Run("filtered stats", func(t *testing.T) {
+		var stats pool.DLQStats
+		_ = json.NewDecoder(get("/dlq?table=items").Body).Decode(&stats)
+		if stats.Total != 1 {
+			t.Errorf("Total = %d, want 1", stats.Total)
+		}
+	})
+
+	t.Run("entries", func(t *testing.T) {
+		var entries []pool.IndexedEntry
+		_ = json.NewDecoder(get("/dlq/entries?table=orders&limit=1").Body).Decode(&entries)
+		if len(entries) != 1 || entries[0].Index != 3 {
+			t.Errorf("entries = %+v, want only index 3", entries)
+		}
+	})
+
+	t.Run("single entry", func(t *testing.T) {
+		var entry pool.IndexedEntry
+		w := get("/dlq/entries/2")
+		_ = json.NewDecoder(w.Body).Decode(&entry)
+		if w.Code != http.StatusOK || entry.Event.SourceTable != "items" {
+			t.Errorf("GET /dlq/entries/2 = %d %+v", w.Code, entry)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for target, want := range map[string]int{
+			"/dlq/entries/9":      http.StatusNotFound,
+			"/dlq/entries/x":      http.StatusBadRequest,
+			"/dlq?since=tomorrow": http.StatusBadRequest,
+			"/dlq/entries?limit=": http.StatusOK,
+		} {
+			if w := get(target); w.Code != want {
+				t.Errorf("GET %s: StatusCode = %d, want %d", target, w.Code, want)
+			}
+		}
+	})
+
+	t.Run("counters", func(t *testing.T) {
+		if err := dlq.Send(&models.CDCEvent{Operation: "c", SourceTable: "items"}, errors.New("deadlock")); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+
+		// Unfiltered stats add new entries to the ones counted at startup
+		var stats pool.DLQStats
+		_ = json.NewDecoder(get("/dlq").Body).Decode(&stats)
+		if stats.Total != 4 || stats.ByTable["items"] != 2 {
+			t.Errorf("stats = %+v, want 4 entries, 2 for items", stats)
+		}
+	})
+}
+
+// failingSink rejects every entry, like an unreachable Kafka DLQ topic
+type failingSink struct{}
+
+func (failingSink) Write(pool.DLQEntry) error { return errors.New("broker unavailable") }
+func (failingSink) Close() error              { return nil }
+
+func TestServer_DLQFallback(t *testing.T) {
+	sink, err := pool.NewFileSink(filepath.Join(t.TempDir(), "dlq.jsonl"), pool.RotationPolicy{})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	dlq := pool.NewDLQWithFallback(failingSink{}, sink)
+	defer dlq.Close()
+	if err := dlq.Send(&models.CDCEvent{Operation: "c", SourceTable: "orders"}, errors.New("boom")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	s := New(8081)
+	s.SetDLQ(dlq)
+
+	req := httptest.NewRequest(http.MethodGet, "/dlq", nil)
+	w := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(w, req)
+
+	var body map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body["fallback"] != true || body["total"] != float64(1) {
+		t.Errorf("/dlq = %v, want the fallback file flagged, 1 entry", body)
+	}
+
+	// Entries are read from the fallback file
+	req = httptest.NewRequest(http.MethodGet, "/dlq/entries/1", nil)
+	w = httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /dlq/entries/1: StatusCode = %d, want 200", w.Code)
+	}
+}
+
+func TestServer_DLQNotConfigured(t *testing.T) {
+	s := New(8081)
+
+	req := httptest.NewRequest(http.MethodGet, "/dlq", nil)
+	w := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("StatusCode = %d, want 404", w.Code)
+	}
+}
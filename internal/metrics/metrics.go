@@ -24,6 +24,108 @@ var (
 		[]string{"table", "operation", "error_type"},
 	)
 
+	// BatchIsolations counts failed batches that were split to find poison events
+	BatchIsolations = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdc_batch_isolations_total",
+			Help: "Total number of failed batches bisected to isolate poison events",
+		},
+	)
+
+	// PoisonEvents counts events that failed on their own and were dead-lettered
+	PoisonEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cdc_poison_events_total",
+			Help: "Total number of events isolated from a failed batch and sent to the DLQ",
+		},
+		[]string{"table", "operation"},
+	)
+
+	// EventsCoalesced counts events merged into a later change to the same row
+	EventsCoalesced = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cdc_events_coalesced_total",
+			Help: "Total number of events merged into a later change to the same row within a batch",
+		},
+		[]string{"table"},
+	)
+
+	// BulkLoadedRows counts snapshot rows loaded into PostgreSQL with COPY
+	BulkLoadedRows = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cdc_bulk_loaded_rows_total",
+			Help: "Total number of snapshot rows loaded with COPY and merged into the target",
+		},
+		[]string{"table"},
+	)
+
+	// UnknownColumns counts payload columns missing from the target table
+	UnknownColumns = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cdc_unknown_columns_total",
+			Help: "Total number of payload columns not found in the target table, dropped or failing their event",
+		},
+		[]string{"table"},
+	)
+
+	// StmtCacheHits counts statements found in a writer's prepared statement cache
+	StmtCacheHits = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdc_stmt_cache_hits_total",
+			Help: "Total number of statements executed from the prepared statement cache",
+		},
+	)
+
+	// StmtCacheMisses counts statements prepared because they were not cached
+	StmtCacheMisses = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdc_stmt_cache_misses_total",
+			Help: "Total number of statements prepared on a prepared statement cache miss",
+		},
+	)
+
+	// DLQEntries tracks events dead-lettered since the process started
+	DLQEntries = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cdc_dlq_entries",
+			Help: "Number of events sent to the DLQ since startup",
+		},
+	)
+
+	// DLQWriteErrors counts entries the DLQ sink failed to persist
+	DLQWriteErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdc_dlq_write_errors_total",
+			Help: "Total number of DLQ entries that could not be written to the DLQ sink",
+		},
+	)
+
+	// DLQRotations counts DLQ file rotations
+	DLQRotations = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cdc_dlq_rotations_total",
+			Help: "Total number of times the DLQ file was rotated",
+		},
+	)
+
+	// RetryQueueEvents tracks events waiting for a delayed retry
+	RetryQueueEvents = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cdc_retry_queue_events",
+			Help: "Number of failed events waiting for a delayed retry",
+		},
+	)
+
+	// RetryAttempts counts delayed retries by outcome: applied, failed
+	// (rescheduled) or dead_lettered (no attempts left)
+	RetryAttempts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cdc_retry_attempts_total",
+			Help: "Total number of delayed retries by table and result",
+		},
+		[]string{"table", "result"},
+	)
+
 	// QueryDuration measures database query time
 	QueryDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -34,15 +136,53 @@ var (
 		[]string{"table", "operation"},
 	)
 
+	// ReplicationLatency measures source commit to target commit time
+	ReplicationLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cdc_replication_latency_seconds",
+			Help:    "Time from the source event (__ts_ms) to its commit in the target",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 16), // 50ms to ~27m
+		},
+		[]string{"table"},
+	)
+
+	// LastAppliedTimestamp tracks the newest source event applied per table
+	LastAppliedTimestamp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cdc_last_applied_source_timestamp_seconds",
+			Help: "Source timestamp (__ts_ms) of the newest event applied to the target, in Unix seconds",
+		},
+		[]string{"table"},
+	)
+
 	// ConsumerLag tracks Kafka consumer lag
 	ConsumerLag = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "cdc_consumer_lag",
-			Help: "Current consumer lag (messages behind)",
+			Help: "Current consumer lag (messages behind the last consumed offset)",
+		},
+		[]string{"topic", "partition"},
+	)
+
+	// ConsumerCommittedLag tracks lag of the committed offset, i.e. events
+	// consumed but not yet applied to the target count as lag
+	ConsumerCommittedLag = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cdc_consumer_committed_lag",
+			Help: "Messages between the last committed offset and the high-water mark",
 		},
 		[]string{"topic", "partition"},
 	)
 
+	// TopicsDiscovered counts topics picked up by the periodic topic refresh
+	TopicsDiscovered = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cdc_topics_discovered_total",
+			Help: "Total number of CDC topics discovered after startup",
+		},
+		[]string{"topic"},
+	)
+
 	// ConnectionStatus tracks connection health
 	ConnectionStatus = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -12,8 +12,56 @@ type CDCEvent struct {
 
 	Topic     string `json:"-"`
 	Partition int32  `json:"-"`
+	Offset    int64  `json:"-"`
 
 	Payload map[string]any `json:"-"`
+
+	// Original Kafka message, kept so a DLQ sink can republish it unchanged
+	RawKey   []byte   `json:"-"`
+	RawValue []byte   `json:"-"`
+	Headers  []Header `json:"-"`
+
+	// Key is the decoded Kafka message key (the row's primary key columns)
+	Key map[string]any `json:"key,omitempty"`
+
+	// Tombstone is set for Kafka tombstones (messages with a null value)
+	Tombstone bool `json:"tombstone,omitempty"`
+
+	// Connect schema of each payload column, present when the converter
+	// runs with schemas.enable=true
+	Fields map[string]FieldSchema `json:"fields,omitempty"`
+
+	// Populated only when consuming the full Debezium envelope
+	Before        map[string]any `json:"before,omitempty"`
+	Source        *SourceInfo    `json:"source,omitempty"`
+	TransactionID string         `json:"transaction_id,omitempty"`
+
+	// ack is called once the event is committed to the target or dead-lettered
+	ack func()
+}
+
+// Header is a Kafka record header
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// FieldSchema is the Kafka Connect schema of a single column
+type FieldSchema struct {
+	Type       string            `json:"type"`                 // int32, int64, string, bytes, struct, ...
+	Name       string            `json:"name,omitempty"`       // logical type, e.g. io.debezium.time.Timestamp
+	Parameters map[string]string `json:"parameters,omitempty"` // e.g. scale for decimals
+}
+
+// SourceInfo is the binlog position block of a Debezium envelope
+type SourceInfo struct {
+	ServerID int64  `json:"server_id,omitempty"`
+	File     string `json:"file,omitempty"`
+	Pos      int64  `json:"pos,omitempty"`
+	Row      int    `json:"row,omitempty"`
+	GTID     string `json:"gtid,omitempty"`
+	Snapshot string `json:"snapshot,omitempty"`
+	TsMs     int64  `json:"ts_ms,omitempty"`
 }
 
 type Operation int
@@ -42,6 +90,22 @@ func (e *CDCEvent) GetTime() time.Time {
 	return time.UnixMilli(e.Timestamp)
 }
 
+// SetAck registers the callback invoked by Ack.
+func (e *CDCEvent) SetAck(fn func()) {
+	e.ack = fn
+}
+
+// Ack reports that the event has been fully handled (written to the target
+// or sent to the DLQ). Only the first call has any effect.
+func (e *CDCEvent) Ack() {
+	if e.ack == nil {
+		return
+	}
+	fn := e.ack
+	e.ack = nil
+	fn()
+}
+
 func (o Operation) String() string {
 	switch o {
 	case OperationInsert:
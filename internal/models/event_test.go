@@ -135,3 +135,22 @@ func TestCDCEvent_Fields(t *testing.T) {
 		t.Errorf("Payload length = %v, want %v", len(event.Payload), 2)
 	}
 }
+
+func TestCDCEvent_Ack(t *testing.T) {
+	calls := 0
+	event := &CDCEvent{}
+	event.SetAck(func() { calls++ })
+
+	event.Ack()
+	event.Ack() // second call must be a no-op
+
+	if calls != 1 {
+		t.Errorf("ack callback called %d times, want 1", calls)
+	}
+}
+
+func TestCDCEvent_Ack_NoCallback(t *testing.T) {
+	// Ack without a registered callback should not panic
+	event := &CDCEvent{}
+	event.Ack()
+}
@@ -0,0 +1,64 @@
+package pool
+
+import (
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+	"github.com/sparkiss/pos-cdc/internal/models"
+)
+
+// coalesce collapses each run of consecutive events for the same row (table
+// and message key) into its final state: the last row image as an upsert,
+// followed by the last delete if the run ends in one. The upsert before a
+// delete keeps a row inserted within the run present, soft-deleted, as it
+// would be without coalescing. Only consecutive events are merged, so the
+// order between different rows is unchanged. Events without a key pass
+// through. Merged events are copies; the originals are left for acking.
+func coalesce(events []*models.CDCEvent) []*models.CDCEvent {
+	out := make([]*models.CDCEvent, 0, len(events))
+
+	for start := 0; start < len(events); {
+		end := start + 1
+		if len(events[start].Key) > 0 {
+			key := rowKey(events[start])
+			for end < len(events) && len(events[end].Key) > 0 && rowKey(events[end]) == key {
+				end++
+			}
+		}
+
+		run := events[start:end]
+		start = end
+		if len(run) == 1 {
+			out = append(out, run[0])
+			continue
+		}
+
+		merged := mergeRun(run)
+		metrics.EventsCoalesced.WithLabelValues(run[0].SourceTable).Add(float64(len(run) - len(merged)))
+		out = append(out, merged...)
+	}
+	return out
+}
+
+// mergeRun reduces a run of events for one row to at most an upsert and a delete
+func mergeRun(run []*models.CDCEvent) []*models.CDCEvent {
+	last := run[len(run)-1]
+
+	// Latest row image before the final delete, if any
+	var image *models.CDCEvent
+	for i := len(run) - 1; i >= 0; i-- {
+		if run[i].GetOperation() != models.OperationDelete {
+			image = run[i]
+			break
+		}
+	}
+
+	var merged []*models.CDCEvent
+	if image != nil {
+		upsert := *image
+		upsert.Operation = "c"
+		merged = append(merged, &upsert)
+	}
+	if last.GetOperation() == models.OperationDelete {
+		merged = append(merged, last)
+	}
+	return merged
+}
@@ -0,0 +1,89 @@
+package pool
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+	"github.com/sparkiss/pos-cdc/internal/models"
+)
+
+func rowEvent(table, op string, id int, status string) *models.CDCEvent {
+	return &models.CDCEvent{
+		Operation:   op,
+		SourceTable: table,
+		Key:         map[string]any{"id": float64(id)},
+		Payload:     map[string]any{"id": float64(id), "status": status},
+	}
+}
+
+func ops(events []*models.CDCEvent) string {
+	var s string
+	for _, e := range events {
+		s += e.Operation
+	}
+	return s
+}
+
+func TestCoalesce_UpdatesBecomeOneUpsert(t *testing.T) {
+	coalesced := metrics.EventsCoalesced.WithLabelValues("coalesce_orders")
+	before := testutil.ToFloat64(coalesced)
+
+	events := []*models.CDCEvent{
+		rowEvent("coalesce_orders", "u", 1, "open"),
+		rowEvent("coalesce_orders", "u", 1, "paid"),
+		rowEvent("coalesce_orders", "u", 1, "shipped"),
+	}
+	got := coalesce(events)
+
+	if len(got) != 1 || got[0].Operation != "c" || got[0].Payload["status"] != "shipped" {
+		t.Fatalf("coalesce() = %s %v, want one upsert of the final image", ops(got), got[0].Payload)
+	}
+	if events[2].Operation != "u" {
+		t.Error("coalesce() modified the original event")
+	}
+	if n := testutil.ToFloat64(coalesced) - before; n != 2 {
+		t.Errorf("coalesced events counted = %v, want 2", n)
+	}
+}
+
+func TestCoalesce_EndsInDelete(t *testing.T) {
+	got := coalesce([]*models.CDCEvent{
+		rowEvent("orders", "c", 1, "open"),
+		rowEvent("orders", "u", 1, "void"),
+		rowEvent("orders", "d", 1, "void"),
+	})
+	// The row image is applied before the soft delete
+	if ops(got) != "cd" || got[0].Payload["status"] != "void" {
+		t.Errorf("coalesce() = %s, want upsert of the last image then delete", ops(got))
+	}
+
+	got = coalesce([]*models.CDCEvent{
+		rowEvent("orders", "d", 1, ""),
+		rowEvent("orders", "d", 1, ""),
+	})
+	if ops(got) != "d" {
+		t.Errorf("coalesce() = %s, want a single delete", ops(got))
+	}
+}
+
+func TestCoalesce_KeepsOrderBetweenRows(t *testing.T) {
+	events := []*models.CDCEvent{
+		rowEvent("orders", "u", 1, "a"),
+		rowEvent("orders", "u", 2, "b"),
+		rowEvent("orders", "u", 1, "c"),
+		rowEvent("items", "u", 1, "d"), // same key, other table
+		{Operation: "u", SourceTable: "orders"},
+		{Operation: "u", SourceTable: "orders"},
+	}
+	got := coalesce(events)
+	if len(got) != len(events) {
+		t.Fatalf("coalesce() returned %d events, want %d unchanged", len(got), len(events))
+	}
+	for i := range events {
+		if got[i] != events[i] {
+			t.Errorf("event %d was replaced", i)
+		}
+	}
+}
@@ -1,97 +1,244 @@
 package pool
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
+	"errors"
+	"fmt"
+	"io/fs"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/metrics"
 	"github.com/sparkiss/pos-cdc/internal/models"
 	"github.com/sparkiss/pos-cdc/pkg/logger"
 )
 
+// DefaultDLQPath is where NewDLQ writes, relative to the working directory
+var DefaultDLQPath = config.DefaultDLQPath
+
+// ReasonExecutionError marks events that failed when applied to the target
+const ReasonExecutionError = "execution_error"
+
+// ReasonRetryPending marks events held behind a pending retry of their row
+const ReasonRetryPending = "retry_pending"
+
+var errRetryPending = errors.New("held behind a pending retry of the same row")
+
 // DLQEntry represents a failed event
 type DLQEntry struct {
 	Event     *models.CDCEvent `json:"event"`
 	Error     string           `json:"error"`
+	Reason    string           `json:"reason,omitempty"`
 	Timestamp time.Time        `json:"timestamp"`
 	Retries   int              `json:"retries"`
+
+	// Row data and Kafka position, which CDCEvent does not serialize.
+	// Needed to replay the entry.
+	Payload   map[string]any `json:"payload,omitempty"`
+	Topic     string         `json:"topic,omitempty"`
+	Partition int32          `json:"partition"`
+	Offset    int64          `json:"offset"`
+
+	// Original message, kept only for events that could not be decoded
+	RawKey   []byte `json:"raw_key,omitempty"`
+	RawValue []byte `json:"raw_value,omitempty"`
 }
 
-// DLQ manages failed events
+// DLQSink persists dead-lettered entries
+type DLQSink interface {
+	Write(entry DLQEntry) error
+	Close() error
+}
+
+// DLQ manages failed events. Entries are handed to the sink and only
+// counted in memory. When the sink fails, the entry goes to the fallback
+// sink if there is one.
 type DLQ struct {
-	entries []DLQEntry
-	mu      sync.Mutex
-	file    *os.File
+	count    int
+	stats    DLQStats
+	mu       sync.Mutex
+	sink     DLQSink
+	fallback DLQSink
 }
 
-// NewDLQ creates a new DLQ
+// NewDLQ creates a DLQ that appends to the JSONL file at DefaultDLQPath
+// with DefaultRotation
 func NewDLQ() *DLQ {
-	// DLQ file path in var/dlq/ directory (hardcoded, not user input)
-	dlqPath := filepath.Join("var", "dlq", "dlq.jsonl")
-
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(dlqPath), 0750); err != nil {
-		logger.Log.Warn("Failed to create DLQ directory",
-			zap.String("path", filepath.Dir(dlqPath)),
-			zap.Error(err))
-	}
-
-	// Open DLQ file for appending
-	file, err := os.OpenFile(dlqPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // #nosec G304 - path is hardcoded
+	sink, err := NewFileSink(DefaultDLQPath, DefaultRotation)
 	if err != nil {
-		logger.Log.Warn("Failed to open DLQ file, using memory only",
-			zap.String("path", dlqPath),
+		logger.Log.Warn("Failed to open DLQ file, entries will only be logged",
+			zap.String("path", DefaultDLQPath),
 			zap.Error(err))
+		return NewDLQWithSink(nil)
 	}
+	return NewDLQWithSink(sink)
+}
 
-	return &DLQ{
-		entries: make([]DLQEntry, 0),
-		file:    file,
-	}
+// NewDLQWithSink creates a DLQ that persists entries to sink.
+// A nil sink only logs and counts them.
+func NewDLQWithSink(sink DLQSink) *DLQ {
+	return &DLQ{sink: sink, stats: NewDLQStats()}
+}
+
+// NewDLQWithFallback creates a DLQ that persists entries to sink, and to
+// fallback when sink fails
+func NewDLQWithFallback(sink, fallback DLQSink) *DLQ {
+	return &DLQ{sink: sink, fallback: fallback, stats: NewDLQStats()}
+}
+
+// Send adds an event that failed to execute against the target to the DLQ.
+// It returns an error when the entry could not be persisted, in which case
+// the event must not be acknowledged.
+func (d *DLQ) Send(event *models.CDCEvent, err error) error {
+	return d.SendWithReason(event, ReasonExecutionError, err)
+}
+
+// SendWithReason adds a failed event to the DLQ with a reason code, such as
+// one returned by processor.Reason. Errors are as for Send.
+func (d *DLQ) SendWithReason(event *models.CDCEvent, reason string, err error) error {
+	return d.sendEntry(newDLQEntry(event, reason, err))
 }
 
-// Send adds a failed event to the DLQ
-func (d *DLQ) Send(event *models.CDCEvent, err error) {
+// newDLQEntry records a failed event with its row data and Kafka position
+func newDLQEntry(event *models.CDCEvent, reason string, err error) DLQEntry {
 	entry := DLQEntry{
 		Event:     event,
 		Error:     err.Error(),
+		Reason:    reason,
 		Timestamp: time.Now(),
 		Retries:   0,
+		Payload:   event.Payload,
+		Topic:     event.Topic,
+		Partition: event.Partition,
+		Offset:    event.Offset,
+	}
+	if event.Payload == nil {
+		entry.RawKey = event.RawKey
+		entry.RawValue = event.RawValue
+	}
+	return entry
+}
+
+// sendEntry persists an entry. The sinks are written outside the lock, since
+// a Kafka sink waits for the brokers.
+func (d *DLQ) sendEntry(entry DLQEntry) error {
+	if err := d.write(entry); err != nil {
+		return err
 	}
 
 	d.mu.Lock()
-	defer d.mu.Unlock()
+	d.count++
+	d.stats.Add(entry)
+	metrics.DLQEntries.Set(float64(d.count))
+	d.mu.Unlock()
+
+	logger.Log.Error("Event sent to DLQ",
+		zap.String("table", entry.Event.SourceTable),
+		zap.String("op", entry.Event.Operation),
+		zap.String("reason", entry.Reason),
+		zap.Int("retries", entry.Retries),
+		zap.String("error", entry.Error))
+	return nil
+}
 
-	d.entries = append(d.entries, entry)
+// write hands the entry to the sink, then to the fallback if the sink fails
+func (d *DLQ) write(entry DLQEntry) error {
+	if d.sink == nil {
+		return nil
+	}
+	err := d.sink.Write(entry)
+	if err == nil {
+		return nil
+	}
+	metrics.DLQWriteErrors.Inc()
 
-	// Persist to file
-	if d.file != nil {
-		if jsonBytes, err := json.Marshal(entry); err == nil {
-			_, _ = d.file.Write(jsonBytes)
-			_, _ = d.file.WriteString("\n")
+	if d.fallback != nil {
+		fallbackErr := d.fallback.Write(entry)
+		if fallbackErr == nil {
+			logger.Log.Warn("DLQ sink failed, entry written to fallback", zap.Error(err))
+			return nil
 		}
+		metrics.DLQWriteErrors.Inc()
+		err = errors.Join(err, fallbackErr)
 	}
 
-	logger.Log.Error("Event sent to DLQ",
-		zap.String("table", event.SourceTable),
-		zap.String("op", event.Operation),
+	logger.Log.Error("Failed to persist DLQ entry",
+		zap.String("table", entry.Event.SourceTable),
+		zap.String("topic", entry.Topic),
+		zap.Int32("partition", entry.Partition),
+		zap.Int64("offset", entry.Offset),
 		zap.Error(err))
+	return fmt.Errorf("failed to persist DLQ entry: %w", err)
 }
 
-// Count returns the number of entries in the DLQ
+// Count returns the number of events sent to the DLQ since it was created
 func (d *DLQ) Count() int {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	return len(d.entries)
+	return d.count
+}
+
+// Stats summarizes the entries in the DLQ file when the DLQ was created,
+// as counted by LoadStats, and every entry persisted since, wherever it was
+// written.
+func (d *DLQ) Stats() DLQStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats.Clone()
+}
+
+// LoadStats counts the entries already in the DLQ file (see Path) and its
+// rotated files into Stats, reading one entry at a time. Call it once,
+// before the DLQ is used.
+func (d *DLQ) LoadStats() error {
+	path := d.Path()
+	if path == "" {
+		return nil
+	}
+	stats, err := SummarizeDLQFiles(path, DLQFilter{})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stats = stats
+	return nil
 }
 
-// Close closes the DLQ file
+// Path returns the DLQ file path: the sink's, or the fallback's when the
+// sink is not file-backed. It is "" when entries are not written to a file.
+func (d *DLQ) Path() string {
+	if fs, ok := d.sink.(*FileSink); ok {
+		return fs.Path()
+	}
+	if fs, ok := d.fallback.(*FileSink); ok {
+		return fs.Path()
+	}
+	return ""
+}
+
+// FileIsFallback reports whether Path is the fallback file, which only
+// holds the entries the primary sink failed to take.
+func (d *DLQ) FileIsFallback() bool {
+	if _, ok := d.sink.(*FileSink); ok {
+		return false
+	}
+	_, ok := d.fallback.(*FileSink)
+	return ok
+}
+
+// Close closes the DLQ sink
 func (d *DLQ) Close() {
-	if d.file != nil {
-		_ = d.file.Close()
+	if d.sink != nil {
+		_ = d.sink.Close()
+	}
+	if d.fallback != nil {
+		_ = d.fallback.Close()
 	}
 }
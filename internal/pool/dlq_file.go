@@ -0,0 +1,365 @@
+package pool
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+	"github.com/sparkiss/pos-cdc/pkg/logger"
+)
+
+// rotatedTimeFormat stamps rotated file names; it sorts chronologically
+const rotatedTimeFormat = "20060102T150405.000"
+
+// maintenanceInterval is how often an idle FileSink checks its age and
+// retention limits
+const maintenanceInterval = time.Minute
+
+// RotationPolicy controls when a FileSink starts a new file and how long
+// rotated files are kept. Zero values disable the corresponding limit.
+type RotationPolicy struct {
+	MaxBytes   int64         // rotate before the file would grow past this size
+	MaxAge     time.Duration // rotate once the file's oldest entry is this old
+	Retention  time.Duration // delete rotated files last written this long ago
+	MaxBackups int           // keep only this many rotated files, newest first
+}
+
+// DefaultRotation matches the configuration defaults
+var DefaultRotation = RotationPolicy{
+	MaxBytes:  100 << 20,
+	MaxAge:    24 * time.Hour,
+	Retention: 30 * 24 * time.Hour,
+}
+
+// FileSink appends entries to a JSONL file. When the rotation policy says
+// so, the file is renamed to <name>-<timestamp><ext>, compressed with gzip
+// in the background and a new file is started. Rotation is checked on write
+// and, for the age limit, periodically, so an idle file is rotated and pruned
+// on time too.
+type FileSink struct {
+	mu     sync.Mutex
+	file   *os.File
+	path   string
+	policy RotationPolicy
+	size   int64
+	oldest time.Time // timestamp of the first entry in the current file
+	now    func() time.Time
+
+	lock     *dlqLock   // held until Close, so replay cannot rewrite the file
+	maintain sync.Mutex // serializes compression and pruning
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFileSink opens path for appending, creating it and its directory if
+// needed. It fails with ErrDLQLocked while another sink or a replay has the
+// file. Rotated files left uncompressed or past retention by a previous run
+// are cleaned up in the background.
+func NewFileSink(path string, policy RotationPolicy) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	lock, err := lockDLQ(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &FileSink{path: path, policy: policy, now: time.Now, lock: lock, stop: make(chan struct{})}
+	if err := f.open(); err != nil {
+		_ = lock.Unlock()
+		return nil, err
+	}
+	f.startMaintenance()
+	if policy.MaxAge > 0 || policy.Retention > 0 {
+		f.wg.Go(f.maintainPeriodically)
+	}
+	return f, nil
+}
+
+// Path returns the path of the active file
+func (f *FileSink) Path() string {
+	return f.path
+}
+
+// Write appends the entry as a single JSON line, rotating first if needed
+func (f *FileSink) Write(entry DLQEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return errors.New("DLQ file is closed")
+	}
+	if f.shouldRotate(len(line)) {
+		if err := f.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate DLQ file: %w", err)
+		}
+	}
+
+	n, err := f.file.Write(line)
+	if f.size == 0 && n > 0 {
+		f.oldest = entry.Timestamp
+	}
+	f.size += int64(n)
+	return err
+}
+
+// Close closes the file and waits for background compression to finish
+func (f *FileSink) Close() error {
+	f.stopOnce.Do(func() { close(f.stop) })
+
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.wg.Wait()
+
+	// Released last, once nothing touches the files any more
+	f.mu.Lock()
+	if f.lock != nil {
+		_ = f.lock.Unlock()
+		f.lock = nil
+	}
+	f.mu.Unlock()
+	return err
+}
+
+func (f *FileSink) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // #nosec G304 - path is from configuration
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.oldest = time.Time{}
+	if f.size > 0 {
+		f.oldest = firstEntryTime(f.path, info.ModTime())
+	}
+	return nil
+}
+
+func (f *FileSink) shouldRotate(next int) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.policy.MaxBytes > 0 && f.size+int64(next) > f.policy.MaxBytes {
+		return true
+	}
+	return f.policy.MaxAge > 0 && f.now().Sub(f.oldest) >= f.policy.MaxAge
+}
+
+// rotate renames the active file aside and opens a new one. Called with mu held.
+func (f *FileSink) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	ext := filepath.Ext(f.path)
+	rotated := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(f.path, ext), f.now().UTC().Format(rotatedTimeFormat), ext)
+	renameErr := os.Rename(f.path, rotated)
+
+	// Reopen even if the rename failed so entries keep being written
+	if err := f.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	metrics.DLQRotations.Inc()
+	logger.Log.Info("Rotated DLQ file", zap.String("file", rotated))
+	f.startMaintenance()
+	return nil
+}
+
+// maintainPeriodically applies the age and retention limits until Close
+func (f *FileSink) maintainPeriodically() {
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.maintainIdle()
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// maintainIdle rotates the active file once it is too old, even if nothing
+// is being written, and prunes rotated files past retention
+func (f *FileSink) maintainIdle() {
+	f.mu.Lock()
+	rotated := false
+	if f.file != nil && f.policy.MaxAge > 0 && f.shouldRotate(0) {
+		// rotate compresses and prunes in the background itself
+		if err := f.rotate(); err != nil {
+			logger.Log.Warn("Failed to rotate DLQ file", zap.String("path", f.path), zap.Error(err))
+		}
+		rotated = true
+	}
+	f.mu.Unlock()
+
+	if !rotated {
+		f.compressAndPrune()
+	}
+}
+
+func (f *FileSink) startMaintenance() {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.compressAndPrune()
+	}()
+}
+
+// compressAndPrune gzips rotated files and deletes the ones past retention
+func (f *FileSink) compressAndPrune() {
+	f.maintain.Lock()
+	defer f.maintain.Unlock()
+
+	files, err := RotatedDLQFiles(f.path)
+	if err != nil {
+		logger.Log.Warn("Failed to list rotated DLQ files", zap.Error(err))
+		return
+	}
+
+	for i, name := range files {
+		if strings.HasSuffix(name, ".gz") {
+			continue
+		}
+		if err := gzipFile(name); err != nil {
+			logger.Log.Warn("Failed to compress rotated DLQ file", zap.String("file", name), zap.Error(err))
+			continue
+		}
+		files[i] = name + ".gz"
+	}
+
+	// Newest first, so MaxBackups keeps the most recent files
+	slices.Reverse(files)
+	for i, name := range files {
+		expired := f.policy.MaxBackups > 0 && i >= f.policy.MaxBackups
+		if !expired && f.policy.Retention > 0 {
+			info, err := os.Stat(name)
+			expired = err == nil && time.Since(info.ModTime()) > f.policy.Retention
+		}
+		if !expired {
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			logger.Log.Warn("Failed to delete expired DLQ file", zap.String("file", name), zap.Error(err))
+			continue
+		}
+		logger.Log.Info("Deleted expired DLQ file", zap.String("file", name))
+	}
+}
+
+// RotatedDLQFiles returns the rotated files of the DLQ file at path, oldest
+// first. Compressed files end in .gz.
+func RotatedDLQFiles(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	pattern := strings.TrimSuffix(path, ext) + "-*" + ext
+	plain, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := filepath.Glob(pattern + ".gz")
+	if err != nil {
+		return nil, err
+	}
+	files := append(plain, compressed...)
+	// Order by timestamp; a file and its compressed copy never coexist for long
+	slices.SortFunc(files, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, ".gz"), strings.TrimSuffix(b, ".gz"))
+	})
+	return files, nil
+}
+
+// gzipFile compresses name to name.gz, keeping its modification time, and
+// removes the original
+func gzipFile(name string) error {
+	src, err := os.Open(name) // #nosec G304 - rotated DLQ file
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	tmp := name + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600) // #nosec G304 - rotated DLQ file
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp) }()
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name+".gz"); err != nil {
+		return err
+	}
+	// Keep the last write time, which retention is measured from
+	if info, err := src.Stat(); err == nil {
+		_ = os.Chtimes(name+".gz", info.ModTime(), info.ModTime())
+	}
+	return os.Remove(name)
+}
+
+// firstEntryTime returns the timestamp of the first entry in the file, or
+// fallback if it cannot be read
+func firstEntryTime(path string, fallback time.Time) time.Time {
+	file, err := os.Open(path) // #nosec G304 - path is from configuration
+	if err != nil {
+		return fallback
+	}
+	defer func() { _ = file.Close() }()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return fallback
+	}
+	var entry struct {
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if json.Unmarshal(line, &entry) != nil || entry.Timestamp.IsZero() {
+		return fallback
+	}
+	return entry.Timestamp
+}
@@ -0,0 +1,191 @@
+package pool
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/models"
+)
+
+func fileEntry(table string, at time.Time) DLQEntry {
+	return DLQEntry{
+		Event:     &models.CDCEvent{Operation: "c", SourceTable: table},
+		Error:     "boom",
+		Timestamp: at,
+	}
+}
+
+func TestFileSink_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	sink, err := NewFileSink(path, RotationPolicy{MaxBytes: 200})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+
+	now := time.Now()
+	for i := range 3 {
+		// Each entry is over half the limit, so every write after the first rotates
+		sink.now = func() time.Time { return now.Add(time.Duration(i) * time.Second) }
+		if err := sink.Write(fileEntry(strings.Repeat("t", 60), now)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	rotated, err := RotatedDLQFiles(path)
+	if err != nil {
+		t.Fatalf("RotatedDLQFiles() error = %v", err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("rotated files = %v, want 2", rotated)
+	}
+	for _, name := range rotated {
+		if !strings.HasSuffix(name, ".jsonl.gz") {
+			t.Errorf("rotated file %s was not compressed", name)
+		}
+		entries, err := ReadDLQ(name)
+		if err != nil || len(entries) != 1 {
+			t.Errorf("ReadDLQ(%s) = %d entries, %v, want 1", name, len(entries), err)
+		}
+	}
+
+	entries, err := ReadDLQ(path)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("ReadDLQ(active) = %d entries, %v, want 1", len(entries), err)
+	}
+}
+
+func TestFileSink_RotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	sink, err := NewFileSink(path, RotationPolicy{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	sink.now = func() time.Time { return start.Add(30 * time.Minute) }
+	_ = sink.Write(fileEntry("orders", start))
+	_ = sink.Write(fileEntry("orders", start.Add(30*time.Minute)))
+	_ = sink.Close()
+
+	// Reopening picks up the age of the first entry in the file
+	sink, err = NewFileSink(path, RotationPolicy{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	sink.now = func() time.Time { return start.Add(2 * time.Hour) }
+	_ = sink.Write(fileEntry("orders", start.Add(2*time.Hour)))
+	_ = sink.Close()
+
+	rotated, _ := RotatedDLQFiles(path)
+	if len(rotated) != 1 {
+		t.Fatalf("rotated files = %v, want 1", rotated)
+	}
+	if want := "dlq-20250101T140000.000.jsonl.gz"; filepath.Base(rotated[0]) != want {
+		t.Errorf("rotated file = %s, want %s", filepath.Base(rotated[0]), want)
+	}
+	if entries, _ := ReadDLQ(rotated[0]); len(entries) != 2 {
+		t.Errorf("rotated file has %d entries, want 2", len(entries))
+	}
+	if entries, _ := ReadDLQ(path); len(entries) != 1 {
+		t.Errorf("active file has %d entries, want 1", len(entries))
+	}
+}
+
+func TestFileSink_Retention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dlq.jsonl")
+	now := time.Now()
+
+	// Four rotated files from earlier runs, one of them left uncompressed
+	names := []string{
+		"dlq-20250101T000000.000.jsonl.gz",
+		"dlq-20250102T000000.000.jsonl.gz",
+		"dlq-20250103T000000.000.jsonl",
+		"dlq-20250104T000000.000.jsonl.gz",
+	}
+	ages := []time.Duration{40 * 24 * time.Hour, 3 * time.Hour, 2 * time.Hour, time.Hour}
+	for i, name := range names {
+		full := filepath.Join(dir, name)
+		if strings.HasSuffix(name, ".gz") {
+			if err := WriteDLQ(full, []DLQEntry{fileEntry("orders", now)}); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.WriteFile(full, []byte("{}\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.Chtimes(full, now.Add(-ages[i]), now.Add(-ages[i]))
+	}
+
+	sink, err := NewFileSink(path, RotationPolicy{Retention: 30 * 24 * time.Hour, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	_ = sink.Close()
+
+	rotated, _ := RotatedDLQFiles(path)
+	var got []string
+	for _, name := range rotated {
+		got = append(got, filepath.Base(name))
+	}
+	// The oldest is past retention, the next falls outside MaxBackups, and
+	// the leftover plain file was compressed
+	want := []string{"dlq-20250103T000000.000.jsonl.gz", "dlq-20250104T000000.000.jsonl.gz"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("rotated files = %v, want %v", got, want)
+	}
+}
+
+func TestFileSink_MaintainIdle(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dlq.jsonl")
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	sink, err := NewFileSink(path, RotationPolicy{MaxAge: time.Hour, Retention: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	sink.now = func() time.Time { return start }
+	_ = sink.Write(fileEntry("orders", start))
+
+	// A rotated file that expires while the sink runs
+	expired := filepath.Join(dir, "dlq-20241201T000000.000.jsonl.gz")
+	if err := WriteDLQ(expired, []DLQEntry{fileEntry("orders", start)}); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	_ = os.Chtimes(expired, old, old)
+
+	// Nothing is written, yet the file ages past MaxAge
+	sink.now = func() time.Time { return start.Add(2 * time.Hour) }
+	sink.maintainIdle()
+	_ = sink.Close()
+
+	rotated, _ := RotatedDLQFiles(path)
+	var got []string
+	for _, name := range rotated {
+		got = append(got, filepath.Base(name))
+	}
+	if want := "dlq-20250101T140000.000.jsonl.gz"; len(got) != 1 || got[0] != want {
+		t.Errorf("rotated files = %v, want [%s]", got, want)
+	}
+	if entries, _ := ReadDLQ(path); len(entries) != 0 {
+		t.Errorf("active file has %d entries, want 0", len(entries))
+	}
+}
+
+func TestFileSink_WriteAfterClose(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "dlq.jsonl"), RotationPolicy{})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	_ = sink.Close()
+	if err := sink.Write(fileEntry("orders", time.Now())); err == nil {
+		t.Error("Write() after Close() should fail")
+	}
+}
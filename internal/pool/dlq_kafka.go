@@ -0,0 +1,115 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Headers added to every message published to the DLQ topic, next to the
+// original message's own headers.
+const (
+	HeaderError             = "dlq.error"
+	HeaderReason            = "dlq.reason"
+	HeaderTable             = "dlq.table"
+	HeaderOperation         = "dlq.operation"
+	HeaderTimestamp         = "dlq.timestamp"
+	HeaderRetries           = "dlq.retries"
+	HeaderOriginalTopic     = "dlq.original.topic"
+	HeaderOriginalPartition = "dlq.original.partition"
+	HeaderOriginalOffset    = "dlq.original.offset"
+	// HeaderFormat is "entry" when the original message was unavailable and
+	// the value is the JSON-encoded DLQEntry instead
+	HeaderFormat = "dlq.format"
+)
+
+// KafkaSink publishes dead-lettered events to a Kafka topic. The original
+// key and value are republished unchanged; error details travel in headers.
+type KafkaSink struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+// NewKafkaSink creates a sink that publishes to topic using producer
+func NewKafkaSink(producer sarama.SyncProducer, topic string) *KafkaSink {
+	return &KafkaSink{producer: producer, topic: topic}
+}
+
+// DialKafkaSink connects a producer to the brokers and returns a sink for topic
+func DialKafkaSink(brokers []string, topic string) (*KafkaSink, error) {
+	cfg := sarama.NewConfig()
+	cfg.Version = sarama.V2_8_0_0
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Retry.Max = 5
+	cfg.Producer.Return.Successes = true // required by SyncProducer
+
+	producer, err := sarama.NewSyncProducer(brokers, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DLQ producer: %w", err)
+	}
+	return NewKafkaSink(producer, topic), nil
+}
+
+// Write publishes the entry and waits for the brokers to acknowledge it
+func (k *KafkaSink) Write(entry DLQEntry) error {
+	msg, err := k.message(entry)
+	if err != nil {
+		return err
+	}
+	if _, _, err := k.producer.SendMessage(msg); err != nil {
+		return fmt.Errorf("failed to publish to DLQ topic %s: %w", k.topic, err)
+	}
+	return nil
+}
+
+// Close closes the producer
+func (k *KafkaSink) Close() error {
+	return k.producer.Close()
+}
+
+func (k *KafkaSink) message(entry DLQEntry) (*sarama.ProducerMessage, error) {
+	event := entry.Event
+	msg := &sarama.ProducerMessage{Topic: k.topic}
+
+	for _, h := range event.Headers {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(h.Key), Value: h.Value})
+	}
+
+	if event.RawKey != nil {
+		msg.Key = sarama.ByteEncoder(event.RawKey)
+	}
+	switch {
+	case event.RawValue != nil:
+		msg.Value = sarama.ByteEncoder(event.RawValue)
+	case event.Tombstone:
+		// Republished as a tombstone too
+	default:
+		// Not consumed from Kafka; carry the entry itself
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		msg.Value = sarama.ByteEncoder(value)
+		msg.Headers = append(msg.Headers, header(HeaderFormat, "entry"))
+	}
+
+	msg.Headers = append(msg.Headers,
+		header(HeaderError, entry.Error),
+		header(HeaderReason, entry.Reason),
+		header(HeaderTable, event.SourceTable),
+		header(HeaderOperation, event.Operation),
+		header(HeaderTimestamp, entry.Timestamp.UTC().Format(time.RFC3339Nano)),
+		header(HeaderRetries, strconv.Itoa(entry.Retries)),
+		header(HeaderOriginalTopic, entry.Topic),
+		header(HeaderOriginalPartition, strconv.FormatInt(int64(entry.Partition), 10)),
+		header(HeaderOriginalOffset, strconv.FormatInt(entry.Offset, 10)),
+	)
+	return msg, nil
+}
+
+func header(key, value string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
@@ -0,0 +1,202 @@
+package pool
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+	"github.com/sparkiss/pos-cdc/internal/models"
+)
+
+func headerMap(msg *sarama.ProducerMessage) map[string]string {
+	m := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		m[string(h.Key)] = string(h.Value)
+	}
+	return m
+}
+
+func TestKafkaSink_RepublishesOriginalMessage(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, mocks.NewTestConfig())
+	defer func() { _ = producer.Close() }()
+
+	var sent *sarama.ProducerMessage
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	sink := NewKafkaSink(producer, "cdc.dlq")
+	entry := DLQEntry{
+		Event: &models.CDCEvent{
+			Operation:   "u",
+			SourceTable: "orders",
+			RawKey:      []byte(`{"id":1}`),
+			RawValue:    []byte(`{"id":1,"status":"paid","__op":"u"}`),
+			Headers:     []models.Header{{Key: "trace-id", Value: []byte("abc")}},
+		},
+		Error:     "duplicate key",
+		Reason:    ReasonExecutionError,
+		Timestamp: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+		Topic:     "pos_mysql.pos.orders",
+		Partition: 3,
+		Offset:    1234,
+	}
+
+	if err := sink.Write(entry); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if sent.Topic != "cdc.dlq" {
+		t.Errorf("Topic = %q, want cdc.dlq", sent.Topic)
+	}
+	if key, _ := sent.Key.Encode(); string(key) != `{"id":1}` {
+		t.Errorf("Key = %s, want original key", key)
+	}
+	if value, _ := sent.Value.Encode(); string(value) != `{"id":1,"status":"paid","__op":"u"}` {
+		t.Errorf("Value = %s, want original value", value)
+	}
+
+	headers := headerMap(sent)
+	want := map[string]string{
+		"trace-id":              "abc",
+		HeaderError:             "duplicate key",
+		HeaderReason:            ReasonExecutionError,
+		HeaderTable:             "orders",
+		HeaderOperation:         "u",
+		HeaderTimestamp:         "2025-01-01T12:00:00Z",
+		HeaderRetries:           "0",
+		HeaderOriginalTopic:     "pos_mysql.pos.orders",
+		HeaderOriginalPartition: "3",
+		HeaderOriginalOffset:    "1234",
+	}
+	for k, v := range want {
+		if headers[k] != v {
+			t.Errorf("header %s = %q, want %q", k, headers[k], v)
+		}
+	}
+	if _, ok := headers[HeaderFormat]; ok {
+		t.Error("format header set for an original message")
+	}
+}
+
+func TestKafkaSink_WithoutOriginalMessage(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, mocks.NewTestConfig())
+	defer func() { _ = producer.Close() }()
+
+	var sent *sarama.ProducerMessage
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	sink := NewKafkaSink(producer, "cdc.dlq")
+	err := sink.Write(DLQEntry{
+		Event:   &models.CDCEvent{Operation: "c", SourceTable: "orders"},
+		Error:   "boom",
+		Payload: map[string]any{"id": 1},
+	})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if headerMap(sent)[HeaderFormat] != "entry" {
+		t.Errorf("format header = %q, want entry", headerMap(sent)[HeaderFormat])
+	}
+	if value, _ := sent.Value.Encode(); len(value) == 0 {
+		t.Error("Value is empty, want the JSON entry")
+	}
+}
+
+func TestKafkaSink_Tombstone(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, mocks.NewTestConfig())
+	defer func() { _ = producer.Close() }()
+
+	var sent *sarama.ProducerMessage
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	sink := NewKafkaSink(producer, "cdc.dlq")
+	err := sink.Write(DLQEntry{
+		Event: &models.CDCEvent{Operation: "d", SourceTable: "orders", Tombstone: true, RawKey: []byte(`{"id":1}`)},
+		Error: "boom",
+	})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if sent.Value != nil {
+		t.Errorf("Value = %v, want nil (tombstone)", sent.Value)
+	}
+}
+
+func TestKafkaSink_SendError(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, mocks.NewTestConfig())
+	defer func() { _ = producer.Close() }()
+	producer.ExpectSendMessageAndFail(errors.New("broker unavailable"))
+
+	sink := NewKafkaSink(producer, "cdc.dlq")
+	err := sink.Write(DLQEntry{Event: &models.CDCEvent{Operation: "c"}, Error: "boom"})
+	if err == nil {
+		t.Error("Write() should fail when the producer fails")
+	}
+}
+
+func TestDLQ_SinkErrorIsCounted(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, mocks.NewTestConfig())
+	producer.ExpectSendMessageAndFail(errors.New("broker unavailable"))
+
+	dlq := NewDLQWithSink(NewKafkaSink(producer, "cdc.dlq"))
+	defer dlq.Close()
+
+	writeErrors := testutil.ToFloat64(metrics.DLQWriteErrors)
+
+	// The failure is reported so the event is not acknowledged
+	if err := dlq.Send(&models.CDCEvent{Operation: "c", SourceTable: "orders"}, errors.New("boom")); err == nil {
+		t.Error("Send() error = nil, want the sink's error")
+	}
+	if dlq.Count() != 0 {
+		t.Errorf("Count() = %d, want 0", dlq.Count())
+	}
+	if got := testutil.ToFloat64(metrics.DLQWriteErrors) - writeErrors; got != 1 {
+		t.Errorf("DLQ write errors counted = %v, want 1", got)
+	}
+	if dlq.Path() != "" || dlq.FileIsFallback() {
+		t.Errorf("Path() = %q, want empty for a Kafka sink", dlq.Path())
+	}
+}
+
+func TestDLQ_FallbackSink(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, mocks.NewTestConfig())
+	producer.ExpectSendMessageAndFail(errors.New("broker unavailable"))
+
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	fallback, err := NewFileSink(path, RotationPolicy{})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	dlq := NewDLQWithFallback(NewKafkaSink(producer, "cdc.dlq"), fallback)
+
+	if err := dlq.Send(&models.CDCEvent{Operation: "c", SourceTable: "orders", Offset: 9}, errors.New("boom")); err != nil {
+		t.Fatalf("Send() error = %v, want the entry kept by the fallback", err)
+	}
+	dlq.Close()
+
+	entries, err := ReadDLQ(path)
+	if err != nil || len(entries) != 1 || entries[0].Offset != 9 {
+		t.Errorf("fallback file = %+v, %v, want the entry at offset 9", entries, err)
+	}
+	if dlq.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", dlq.Count())
+	}
+	if dlq.Path() != path || !dlq.FileIsFallback() {
+		t.Errorf("Path() = %q, FileIsFallback() = %t, want the fallback file", dlq.Path(), dlq.FileIsFallback())
+	}
+}
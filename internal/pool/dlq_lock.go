@@ -0,0 +1,42 @@
+//go:build unix
+
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ErrDLQLocked is returned when another FileSink or replay holds the DLQ file
+var ErrDLQLocked = errors.New("DLQ file is in use; stop the consumer first")
+
+// dlqLock is an exclusive lock on a DLQ file, taken on a .lock file next to
+// it. The FileSink writing the file holds it for its lifetime and replay
+// holds it while rewriting, so entries are never appended to a file that is
+// being replaced.
+type dlqLock struct {
+	file *os.File
+}
+
+// lockDLQ takes the lock for the DLQ file at path without waiting
+func lockDLQ(path string) (*dlqLock, error) {
+	file, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600) // #nosec G304 - path is from configuration
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%s: %w", path, ErrDLQLocked)
+		}
+		return nil, err
+	}
+	return &dlqLock{file: file}, nil
+}
+
+// Unlock releases the lock
+func (l *dlqLock) Unlock() error {
+	return l.file.Close()
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package pool
+
+import "errors"
+
+// ErrDLQLocked is returned when another FileSink or replay holds the DLQ file
+var ErrDLQLocked = errors.New("DLQ file is in use; stop the consumer first")
+
+// dlqLock is a no-op where flock is unavailable; replay then relies on the
+// consumer being stopped
+type dlqLock struct{}
+
+func lockDLQ(string) (*dlqLock, error) { return &dlqLock{}, nil }
+
+// Unlock releases the lock
+func (l *dlqLock) Unlock() error { return nil }
@@ -9,6 +9,9 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
 	"github.com/sparkiss/pos-cdc/internal/models"
 	"github.com/sparkiss/pos-cdc/pkg/logger"
 )
@@ -81,12 +84,17 @@ func TestDLQ_Send(t *testing.T) {
 	}
 
 	// Verify entry was stored correctly
-	if len(dlq.entries) != 1 {
-		t.Fatalf("entries length = %d, want 1", len(dlq.entries))
+	dlq.Close()
+	entries, err := ReadDLQ(DefaultDLQPath)
+	if err != nil {
+		t.Fatalf("ReadDLQ() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries length = %d, want 1", len(entries))
 	}
 
-	entry := dlq.entries[0]
-	if entry.Event != event {
+	entry := entries[0]
+	if entry.Event.SourceTable != event.SourceTable {
 		t.Error("entry.Event does not match sent event")
 	}
 	if entry.Error != testErr.Error() {
@@ -119,6 +127,9 @@ func TestDLQ_Send_MultipleEvents(t *testing.T) {
 	if count := dlq.Count(); count != 5 {
 		t.Errorf("Count() = %d, want 5", count)
 	}
+	if got := testutil.ToFloat64(metrics.DLQEntries); got != 5 {
+		t.Errorf("cdc_dlq_entries = %v, want 5", got)
+	}
 }
 
 // TestDLQ_Send_Concurrent tests thread safety
@@ -217,10 +228,7 @@ func TestDLQ_Close(t *testing.T) {
 // TestDLQ_NoFile tests DLQ behavior when file cannot be created
 func TestDLQ_NoFile(t *testing.T) {
 	// Create a DLQ with nil file to simulate file creation failure
-	dlq := &DLQ{
-		entries: make([]DLQEntry, 0),
-		file:    nil, // No file
-	}
+	dlq := NewDLQWithSink(nil) // No file
 
 	event := &models.CDCEvent{
 		Operation:   "c",
@@ -0,0 +1,51 @@
+package pool
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/pkg/logger"
+)
+
+// haltSignal is shared by all workers. Once triggered, workers stop
+// processing and leave their events unacknowledged, so the offsets are not
+// committed and the events are consumed again after a restart.
+type haltSignal struct {
+	once   sync.Once
+	halted atomic.Bool
+	errCh  chan error
+}
+
+func newHaltSignal() *haltSignal {
+	return &haltSignal{errCh: make(chan error, 1)}
+}
+
+// Trigger halts the pool; only the first error is reported.
+func (h *haltSignal) Trigger(err error) {
+	h.once.Do(func() {
+		h.halted.Store(true)
+		h.errCh <- err
+	})
+}
+
+// Halted reports whether the pool has been halted.
+func (h *haltSignal) Halted() bool {
+	return h.halted.Load()
+}
+
+// Unpersisted halts the pool when a failed event could be kept in neither
+// the retry queue nor the DLQ, so it is not acknowledged and lost.
+func (h *haltSignal) Unpersisted(event *models.CDCEvent, err error) {
+	logger.Log.Error("Halting on event that could not be dead-lettered",
+		zap.String("table", event.SourceTable),
+		zap.String("topic", event.Topic),
+		zap.Int32("partition", event.Partition),
+		zap.Int64("offset", event.Offset),
+		zap.Error(err))
+	h.Trigger(fmt.Errorf("failed to dead-letter event on %s at %s/%d:%d: %w",
+		event.SourceTable, event.Topic, event.Partition, event.Offset, err))
+}
@@ -0,0 +1,156 @@
+package pool
+
+import (
+	"fmt"
+	"maps"
+	"time"
+)
+
+// DLQStats summarizes DLQ entries
+type DLQStats struct {
+	Total       int            `json:"total"`
+	ByTable     map[string]int `json:"by_table"`
+	ByOperation map[string]int `json:"by_operation"`
+	ByReason    map[string]int `json:"by_reason"`
+	ByError     map[string]int `json:"by_error"`
+	Oldest      *time.Time     `json:"oldest,omitempty"`
+	Newest      *time.Time     `json:"newest,omitempty"`
+}
+
+// IndexedEntry is a DLQ entry with its 1-based position across the DLQ's
+// files, oldest first, which identifies it for "dlq show" and
+// /dlq/entries/{index}
+type IndexedEntry struct {
+	Index int `json:"index"`
+	DLQEntry
+}
+
+// NewDLQStats returns empty stats, ready for Add
+func NewDLQStats() DLQStats {
+	return DLQStats{
+		ByTable:     make(map[string]int),
+		ByOperation: make(map[string]int),
+		ByReason:    make(map[string]int),
+		ByError:     make(map[string]int),
+	}
+}
+
+// Add counts one entry, which must have an event
+func (s *DLQStats) Add(entry DLQEntry) {
+	s.Total++
+	s.ByTable[entry.Event.SourceTable]++
+	s.ByOperation[entry.Event.GetOperation().String()]++
+	s.ByReason[entryReason(entry)]++
+	s.ByError[entry.Error]++
+
+	ts := entry.Timestamp
+	if s.Oldest == nil || ts.Before(*s.Oldest) {
+		s.Oldest = &ts
+	}
+	if s.Newest == nil || ts.After(*s.Newest) {
+		s.Newest = &ts
+	}
+}
+
+// Clone returns a copy that shares no maps with s
+func (s DLQStats) Clone() DLQStats {
+	s.ByTable = maps.Clone(s.ByTable)
+	s.ByOperation = maps.Clone(s.ByOperation)
+	s.ByReason = maps.Clone(s.ByReason)
+	s.ByError = maps.Clone(s.ByError)
+	return s
+}
+
+// SummarizeDLQ counts the entries that match the filter.
+func SummarizeDLQ(entries []DLQEntry, filter DLQFilter) DLQStats {
+	stats := NewDLQStats()
+	for _, entry := range entries {
+		if filter.Match(entry) {
+			stats.Add(entry)
+		}
+	}
+	return stats
+}
+
+// SummarizeDLQFiles counts the entries of the DLQ file at path and its
+// rotated files that match the filter, reading one entry at a time.
+func SummarizeDLQFiles(path string, filter DLQFilter) (DLQStats, error) {
+	stats := NewDLQStats()
+	err := ScanDLQFiles(path, func(_ int, entry DLQEntry) bool {
+		if filter.Match(entry) {
+			stats.Add(entry)
+		}
+		return true
+	})
+	return stats, err
+}
+
+// FilterDLQ returns the entries that match the filter with their file
+// positions. A positive limit keeps only the most recent matches.
+func FilterDLQ(entries []DLQEntry, filter DLQFilter, limit int) []IndexedEntry {
+	var matched []IndexedEntry
+	for i, entry := range entries {
+		if filter.Match(entry) {
+			matched = append(matched, IndexedEntry{Index: i + 1, DLQEntry: entry})
+		}
+	}
+	if limit > 0 && len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+	return matched
+}
+
+// FilterDLQFiles is FilterDLQ over the DLQ file at path and its rotated
+// files. Entries are read one at a time and at most limit matches are held,
+// so limit must be positive.
+func FilterDLQFiles(path string, filter DLQFilter, limit int) ([]IndexedEntry, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit %d: must be positive", limit)
+	}
+
+	// Ring buffer of the latest matches; next is the oldest once full
+	recent := make([]IndexedEntry, 0, min(limit, 1024))
+	next := 0
+	err := ScanDLQFiles(path, func(index int, entry DLQEntry) bool {
+		if !filter.Match(entry) {
+			return true
+		}
+		if len(recent) < limit {
+			recent = append(recent, IndexedEntry{Index: index, DLQEntry: entry})
+			return true
+		}
+		recent[next] = IndexedEntry{Index: index, DLQEntry: entry}
+		next = (next + 1) % limit
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(recent[next:], recent[:next]...), nil
+}
+
+// DLQEntryAt returns the entry at a 1-based index across the DLQ file at
+// path and its rotated files, reading no further than that entry. ok is
+// false when there is no such entry.
+func DLQEntryAt(path string, index int) (entry DLQEntry, ok bool, err error) {
+	if index < 1 {
+		return DLQEntry{}, false, nil
+	}
+	err = ScanDLQFiles(path, func(i int, e DLQEntry) bool {
+		if i == index {
+			entry, ok = e, true
+			return false
+		}
+		return true
+	})
+	return entry, ok, err
+}
+
+// entryReason returns the entry's reason code. Entries written before
+// reasons were recorded all came from failed batch executions.
+func entryReason(entry DLQEntry) string {
+	if entry.Reason == "" {
+		return ReasonExecutionError
+	}
+	return entry.Reason
+}
@@ -0,0 +1,107 @@
+package pool
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSummarizeDLQ(t *testing.T) {
+	t1 := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	entries := []DLQEntry{
+		dlqEntry("orders", "c", "paid", t2),
+		dlqEntry("orders", "u", "paid", t1),
+		dlqEntry("items", "c", "paid", t1),
+	}
+	entries[2].Reason = "no_primary_key"
+	entries[2].Error = "no primary key for table items"
+
+	stats := SummarizeDLQ(entries, DLQFilter{})
+
+	if stats.Total != 3 {
+		t.Errorf("Total = %d, want 3", stats.Total)
+	}
+	if stats.ByTable["orders"] != 2 || stats.ByTable["items"] != 1 {
+		t.Errorf("ByTable = %v", stats.ByTable)
+	}
+	if stats.ByOperation["INSERT"] != 2 || stats.ByOperation["UPDATE"] != 1 {
+		t.Errorf("ByOperation = %v", stats.ByOperation)
+	}
+	// Entries without a reason predate reason codes and count as execution errors
+	if stats.ByReason[ReasonExecutionError] != 2 || stats.ByReason["no_primary_key"] != 1 {
+		t.Errorf("ByReason = %v", stats.ByReason)
+	}
+	if stats.ByError["constraint violation"] != 2 {
+		t.Errorf("ByError = %v", stats.ByError)
+	}
+	if !stats.Oldest.Equal(t1) || !stats.Newest.Equal(t2) {
+		t.Errorf("range = %v..%v, want %v..%v", stats.Oldest, stats.Newest, t1, t2)
+	}
+
+	if filtered := SummarizeDLQ(entries, DLQFilter{Table: "items"}); filtered.Total != 1 {
+		t.Errorf("filtered Total = %d, want 1", filtered.Total)
+	}
+}
+
+func TestFilterDLQ(t *testing.T) {
+	at := time.Now()
+	entries := []DLQEntry{
+		dlqEntry("orders", "c", "paid", at),
+		dlqEntry("items", "c", "paid", at),
+		dlqEntry("orders", "u", "paid", at),
+		dlqEntry("orders", "d", "paid", at),
+	}
+
+	got := FilterDLQ(entries, DLQFilter{Table: "orders"}, 2)
+	if len(got) != 2 {
+		t.Fatalf("FilterDLQ() returned %d entries, want 2", len(got))
+	}
+	// The limit keeps the most recent matches, indexed by file position
+	if got[0].Index != 3 || got[1].Index != 4 {
+		t.Errorf("indexes = %d, %d, want 3, 4", got[0].Index, got[1].Index)
+	}
+
+	if all := FilterDLQ(entries, DLQFilter{}, 0); len(all) != 4 {
+		t.Errorf("FilterDLQ() without limit returned %d, want 4", len(all))
+	}
+}
+
+func TestFilterDLQFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dlq.jsonl")
+	at := time.Now()
+
+	rotated := filepath.Join(dir, "dlq-20250101T000000.000.jsonl.gz")
+	if err := WriteDLQ(rotated, []DLQEntry{dlqEntry("orders", "c", "paid", at), dlqEntry("items", "c", "paid", at)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteDLQ(path, []DLQEntry{dlqEntry("orders", "u", "paid", at), dlqEntry("orders", "d", "paid", at), dlqEntry("orders", "c", "paid", at)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the latest matches are kept while scanning, oldest first
+	got, err := FilterDLQFiles(path, DLQFilter{Table: "orders"}, 2)
+	if err != nil || len(got) != 2 || got[0].Index != 4 || got[1].Index != 5 {
+		t.Fatalf("FilterDLQFiles() = %+v, %v, want indexes 4, 5", got, err)
+	}
+	if got, _ := FilterDLQFiles(path, DLQFilter{Table: "orders"}, 10); len(got) != 4 || got[0].Index != 1 {
+		t.Errorf("FilterDLQFiles() = %+v, want every orders entry from index 1", got)
+	}
+	if _, err := FilterDLQFiles(path, DLQFilter{}, 0); err == nil {
+		t.Error("FilterDLQFiles() should reject an unbounded limit")
+	}
+
+	entry, ok, err := DLQEntryAt(path, 2)
+	if err != nil || !ok || entry.Event.SourceTable != "items" {
+		t.Errorf("DLQEntryAt(2) = %+v, %t, %v, want the items entry", entry, ok, err)
+	}
+	if _, ok, err := DLQEntryAt(path, 6); ok || err != nil {
+		t.Errorf("DLQEntryAt(6) = %t, %v, want no entry", ok, err)
+	}
+
+	stats, err := SummarizeDLQFiles(path, DLQFilter{Op: "c"})
+	if err != nil || stats.Total != 3 {
+		t.Errorf("SummarizeDLQFiles() = %+v, %v, want 3 inserts", stats, err)
+	}
+}
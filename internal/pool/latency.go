@@ -0,0 +1,54 @@
+package pool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+	"github.com/sparkiss/pos-cdc/internal/writer"
+)
+
+// appliedTracker records replication latency for committed batches and keeps
+// the newest applied source timestamp per table. Several workers can write
+// the same table (one per partition), so the gauge only ever moves forward.
+type appliedTracker struct {
+	mu     sync.Mutex
+	newest map[string]int64 // table -> newest applied __ts_ms
+	now    func() time.Time
+}
+
+func newAppliedTracker() *appliedTracker {
+	return &appliedTracker{
+		newest: make(map[string]int64),
+		now:    time.Now,
+	}
+}
+
+// Record observes the latency of every query in a committed batch.
+// Queries without a source timestamp are ignored.
+func (a *appliedTracker) Record(queries []writer.Query) {
+	now := a.now()
+	batchNewest := make(map[string]int64)
+
+	for _, q := range queries {
+		if q.Timestamp <= 0 {
+			continue
+		}
+		latency := now.Sub(time.UnixMilli(q.Timestamp)).Seconds()
+		metrics.ReplicationLatency.WithLabelValues(q.Table).Observe(max(latency, 0))
+
+		if q.Timestamp > batchNewest[q.Table] {
+			batchNewest[q.Table] = q.Timestamp
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for table, ts := range batchNewest {
+		if ts <= a.newest[table] {
+			continue
+		}
+		a.newest[table] = ts
+		metrics.LastAppliedTimestamp.WithLabelValues(table).Set(float64(ts) / 1000)
+	}
+}
@@ -0,0 +1,43 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+	"github.com/sparkiss/pos-cdc/internal/writer"
+)
+
+func TestAppliedTracker_Record(t *testing.T) {
+	now := time.UnixMilli(1_735_689_610_000)
+	a := newAppliedTracker()
+	a.now = func() time.Time { return now }
+
+	a.Record([]writer.Query{
+		{Table: "latency_orders", Timestamp: 1_735_689_600_000},
+		{Table: "latency_orders", Timestamp: 1_735_689_605_000},
+		{Table: "latency_items", Timestamp: 1_735_689_609_500},
+		{Table: "latency_orders"}, // no source timestamp
+	})
+
+	if n := testutil.CollectAndCount(metrics.ReplicationLatency, "cdc_replication_latency_seconds"); n != 2 {
+		t.Errorf("latency series = %d, want 2 (one per table)", n)
+	}
+
+	orders := metrics.LastAppliedTimestamp.WithLabelValues("latency_orders")
+	if got := testutil.ToFloat64(orders); got != 1_735_689_605 {
+		t.Errorf("last applied orders = %v, want 1735689605", got)
+	}
+	items := metrics.LastAppliedTimestamp.WithLabelValues("latency_items")
+	if got := testutil.ToFloat64(items); got != 1_735_689_609.5 {
+		t.Errorf("last applied items = %v, want 1735689609.5", got)
+	}
+
+	// Another worker committing older events must not move the gauge back
+	a.Record([]writer.Query{{Table: "latency_orders", Timestamp: 1_735_689_601_000}})
+	if got := testutil.ToFloat64(orders); got != 1_735_689_605 {
+		t.Errorf("last applied orders = %v after older batch, want 1735689605", got)
+	}
+}
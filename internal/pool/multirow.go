@@ -0,0 +1,134 @@
+package pool
+
+import (
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/internal/processor"
+	"github.com/sparkiss/pos-cdc/internal/writer"
+	"github.com/sparkiss/pos-cdc/pkg/logger"
+)
+
+// insertRowsBuilder builds one multi-row upsert. Implemented by
+// processor.Processor.BuildInsertRows.
+type insertRowsBuilder func(events []*models.CDCEvent) (string, []any, error)
+
+// combineInserts replaces each run of consecutive inserts into the same table
+// and partition, with the same columns, by multi-row upserts. queries and
+// events are parallel. Runs are split where a row key repeats or the
+// statement would exceed the placeholder limit, so statements run in the
+// original order. Events without a message key are never combined, and a run
+// that fails to build falls back to its single-row queries.
+func combineInserts(queries []writer.Query, events []*models.CDCEvent, build insertRowsBuilder) []writer.Query {
+	out := make([]writer.Query, 0, len(queries))
+
+	var run []int // indexes into queries and events
+	var columns []string
+	keys := make(map[string]bool)
+
+	flush := func() {
+		if len(run) > 1 {
+			if q, ok := insertRowsQuery(queries, events, run, build); ok {
+				out = append(out, q)
+				run, keys = run[:0], make(map[string]bool)
+				return
+			}
+		}
+		for _, i := range run {
+			out = append(out, queries[i])
+		}
+		run, keys = run[:0], make(map[string]bool)
+	}
+
+	for i, event := range events {
+		if event.GetOperation() != models.OperationInsert || len(event.Key) == 0 {
+			flush()
+			out = append(out, queries[i])
+			continue
+		}
+
+		cols := payloadColumns(event)
+		key := rowKey(event)
+		if len(run) > 0 {
+			first := events[run[0]]
+			if first.SourceTable != event.SourceTable ||
+				first.Topic != event.Topic || first.Partition != event.Partition ||
+				!slices.Equal(columns, cols) || keys[key] ||
+				len(run) >= processor.InsertRowLimit(len(cols)) {
+				flush()
+			}
+		}
+
+		if len(run) == 0 {
+			columns = cols
+		}
+		run = append(run, i)
+		keys[key] = true
+	}
+	flush()
+	return out
+}
+
+// insertRowsQuery builds the multi-row query for a run of inserts. Its Kafka
+// position and source time are those of the latest event in the run, and it
+// keeps the rows for bulk loading only if every query in the run had them.
+func insertRowsQuery(queries []writer.Query, events []*models.CDCEvent, run []int, build insertRowsBuilder) (writer.Query, bool) {
+	rows := make([]*models.CDCEvent, len(run))
+	for j, i := range run {
+		rows[j] = events[i]
+	}
+
+	sql, args, err := build(rows)
+	if err != nil {
+		logger.Log.Warn("Failed to build multi-row insert, using single-row inserts",
+			zap.String("table", rows[0].SourceTable),
+			zap.Int("rows", len(rows)),
+			zap.Error(err))
+		return writer.Query{}, false
+	}
+
+	q := queries[run[len(run)-1]]
+	q.SQL = sql
+	q.Args = args
+	q.Rows = len(run)
+	for _, i := range run {
+		q.Timestamp = max(q.Timestamp, queries[i].Timestamp)
+	}
+	q.Copy = mergeCopyRows(queries, run)
+	return q, true
+}
+
+// mergeCopyRows concatenates the bulk-load rows of a run of queries, or
+// returns nil if any query has none
+func mergeCopyRows(queries []writer.Query, run []int) *writer.CopyRows {
+	first := queries[run[0]].Copy
+	if first == nil {
+		return nil
+	}
+
+	merged := *first
+	merged.Rows = make([][]any, 0, len(run))
+	for _, i := range run {
+		c := queries[i].Copy
+		if c == nil {
+			return nil
+		}
+		merged.Rows = append(merged.Rows, c.Rows...)
+	}
+	return &merged
+}
+
+// payloadColumns returns the sorted row columns of an event, without __ fields
+func payloadColumns(event *models.CDCEvent) []string {
+	cols := make([]string, 0, len(event.Payload))
+	for col := range event.Payload {
+		if !strings.HasPrefix(col, "__") {
+			cols = append(cols, col)
+		}
+	}
+	slices.Sort(cols)
+	return cols
+}
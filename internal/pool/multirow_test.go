@@ -0,0 +1,142 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/internal/writer"
+)
+
+// fakeInsertRows builds "ROWS n" for a run of n events and fails for runs
+// containing a row with status "unbuildable"
+func fakeInsertRows(events []*models.CDCEvent) (string, []any, error) {
+	for _, e := range events {
+		if e.Payload["status"] == "unbuildable" {
+			return "", nil, errors.New("cannot combine")
+		}
+	}
+	return fmt.Sprintf("ROWS %d", len(events)), nil, nil
+}
+
+func insertBatch(events ...*models.CDCEvent) ([]writer.Query, []*models.CDCEvent) {
+	queries := make([]writer.Query, len(events))
+	for i, e := range events {
+		e.Offset = int64(i)
+		e.Timestamp = int64(100 + i)
+		queries[i] = writer.Query{
+			SQL:       "ONE " + e.Operation,
+			Table:     e.SourceTable,
+			Op:        e.GetOperation().String(),
+			Offset:    e.Offset,
+			Timestamp: e.Timestamp,
+		}
+	}
+	return queries, events
+}
+
+func sqls(queries []writer.Query) []string {
+	out := make([]string, len(queries))
+	for i, q := range queries {
+		out[i] = q.SQL
+	}
+	return out
+}
+
+func TestCombineInserts(t *testing.T) {
+	queries, events := insertBatch(
+		rowEvent("orders", "r", 1, "open"),
+		rowEvent("orders", "r", 2, "open"),
+		rowEvent("orders", "r", 3, "open"),
+		rowEvent("orders", "u", 1, "paid"), // breaks the run
+		rowEvent("orders", "c", 4, "open"),
+		rowEvent("items", "c", 1, "open"), // other table
+		rowEvent("items", "c", 2, "open"),
+	)
+
+	got := combineInserts(queries, events, fakeInsertRows)
+
+	want := []string{"ROWS 3", "ONE u", "ONE c", "ROWS 2"}
+	if fmt.Sprint(sqls(got)) != fmt.Sprint(want) {
+		t.Fatalf("statements = %v, want %v", sqls(got), want)
+	}
+	if got[0].Rows != 3 || got[0].Offset != 2 || got[0].Timestamp != 102 {
+		t.Errorf("combined query rows/offset/timestamp = %d/%d/%d, want 3/2/102",
+			got[0].Rows, got[0].Offset, got[0].Timestamp)
+	}
+	if got[1].EventCount() != 1 {
+		t.Errorf("single query EventCount() = %d, want 1", got[1].EventCount())
+	}
+}
+
+func TestCombineInserts_SplitsRuns(t *testing.T) {
+	// A repeated key, a different column set and a missing key each end a run
+	withTotal := rowEvent("orders", "c", 4, "open")
+	withTotal.Payload["total"] = "9.99"
+	noKey := rowEvent("orders", "c", 6, "open")
+	noKey.Key = nil
+
+	queries, events := insertBatch(
+		rowEvent("orders", "c", 1, "open"),
+		rowEvent("orders", "c", 2, "open"),
+		rowEvent("orders", "c", 1, "paid"),
+		rowEvent("orders", "c", 3, "paid"),
+		withTotal,
+		noKey,
+	)
+
+	got := combineInserts(queries, events, fakeInsertRows)
+	want := []string{"ROWS 2", "ROWS 2", "ONE c", "ONE c"}
+	if fmt.Sprint(sqls(got)) != fmt.Sprint(want) {
+		t.Errorf("statements = %v, want %v", sqls(got), want)
+	}
+}
+
+func TestCombineInserts_BuildFailureFallsBack(t *testing.T) {
+	queries, events := insertBatch(
+		rowEvent("orders", "c", 1, "open"),
+		rowEvent("orders", "c", 2, "unbuildable"),
+	)
+
+	got := combineInserts(queries, events, fakeInsertRows)
+	if want := []string{"ONE c", "ONE c"}; fmt.Sprint(sqls(got)) != fmt.Sprint(want) {
+		t.Errorf("statements = %v, want single-row fallback %v", sqls(got), want)
+	}
+}
+
+func TestCombineInserts_MergesCopyRows(t *testing.T) {
+	queries, events := insertBatch(
+		rowEvent("orders", "r", 1, "open"),
+		rowEvent("orders", "r", 2, "paid"),
+		rowEvent("orders", "c", 3, "open"),
+		rowEvent("orders", "c", 4, "open"),
+	)
+	for i := range 2 {
+		queries[i].Copy = &writer.CopyRows{
+			Table:       "orders",
+			Columns:     []string{"id", "status"},
+			PrimaryKeys: []string{"id"},
+			Rows:        [][]any{{i + 1, events[i].Payload["status"]}},
+		}
+	}
+	// Only one of the last two queries carries its row
+	queries[2].Copy = queries[0].Copy
+
+	got := combineInserts(queries, events, fakeInsertRows)
+	if len(got) != 1 {
+		t.Fatalf("statements = %v, want one", sqls(got))
+	}
+	if got[0].Copy != nil {
+		t.Fatalf("Copy = %+v, want nil when a query has no rows", got[0].Copy)
+	}
+
+	got = combineInserts(queries[:2], events[:2], fakeInsertRows)
+	c := got[0].Copy
+	if c == nil || fmt.Sprint(c.Rows) != "[[1 open] [2 paid]]" {
+		t.Fatalf("Copy = %+v, want both rows", c)
+	}
+	if len(queries[0].Copy.Rows) != 1 {
+		t.Error("merging modified the original rows")
+	}
+}
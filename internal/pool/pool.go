@@ -4,17 +4,26 @@ import (
 	"context"
 	"fmt"
 	"hash/fnv"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/metrics"
 	"github.com/sparkiss/pos-cdc/internal/models"
 	"github.com/sparkiss/pos-cdc/internal/processor"
 	"github.com/sparkiss/pos-cdc/internal/writer"
 	"github.com/sparkiss/pos-cdc/pkg/logger"
 )
 
+// drainTimeout bounds how long a worker spends writing its last batch after
+// shutdown, in line with the consumer's own shutdown wait
+const drainTimeout = 30 * time.Second
+
 // Worker represents a single worker with its own queue
 type Worker struct {
 	id        int
@@ -23,7 +32,19 @@ type Worker struct {
 	processor *processor.Processor
 	writer    writer.Writer
 	dlq       *DLQ
+	retry     *RetryQueue
+	applied   *appliedTracker
+	halt      *haltSignal
+	onError   func(reason string) config.FailureAction
+	coalesce  bool // merge consecutive changes to a row before building SQL
+	multiRow  bool // combine consecutive inserts into multi-row upserts
+	bulkLoad  bool // attach snapshot rows to their queries for COPY
+	draining  bool // writing the last batch after shutdown
 	wg        *sync.WaitGroup
+
+	// snapshotBatchSize is how far a batch of only snapshot rows grows
+	// with bulk loading
+	snapshotBatchSize int
 }
 
 // WorkerPool manages concurrent event processing
@@ -33,18 +54,25 @@ type WorkerPool struct {
 	processor *processor.Processor
 	writer    writer.Writer
 	dlq       *DLQ
+	retry     *RetryQueue
+	applied   *appliedTracker
+	halt      *haltSignal
+	byKey     bool // route by table and primary key instead of partition
 	wg        sync.WaitGroup
 }
 
 // New creates a new WorkerPool with a database writer.
 // The writer can be MySQL or PostgreSQL (any type implementing writer.Writer).
+// Dead-lettered events are only logged until a DLQ is set with UseDLQ.
 func New(numWorkers, batchSize int, proc *processor.Processor, w writer.Writer) *WorkerPool {
 	wp := &WorkerPool{
 		workers:   make([]*Worker, numWorkers),
 		batchSize: batchSize,
 		processor: proc,
 		writer:    w,
-		dlq:       NewDLQ(),
+		dlq:       NewDLQWithSink(nil),
+		applied:   newAppliedTracker(),
+		halt:      newHaltSignal(),
 	}
 
 	for i := range numWorkers {
@@ -55,30 +83,147 @@ func New(numWorkers, batchSize int, proc *processor.Processor, w writer.Writer)
 			processor: proc,
 			writer:    w,
 			dlq:       wp.dlq,
+			applied:   wp.applied,
+			halt:      wp.halt,
+			onError:   defaultBuildErrorAction,
 			wg:        &wp.wg,
 		}
 	}
 	return wp
 }
 
+// UseBuildErrorPolicy sets the action for events that fail SQL generation,
+// chosen by reason code (see processor.Reason). The default is the DLQ.
+func (wp *WorkerPool) UseBuildErrorPolicy(policy func(reason string) config.FailureAction) {
+	for _, worker := range wp.workers {
+		worker.onError = policy
+	}
+}
+
+// UseDLQ replaces the pool's DLQ, e.g. with one backed by a Kafka topic.
+// Must be called before Start. The previous DLQ is closed.
+func (wp *WorkerPool) UseDLQ(dlq *DLQ) {
+	wp.dlq.Close()
+	wp.dlq = dlq
+	for _, worker := range wp.workers {
+		worker.dlq = dlq
+	}
+}
+
+// DeadLetter sends an event that failed before reaching a worker, such as a
+// message that could not be decoded, to the DLQ. When the entry cannot be
+// persisted the pool halts and the error is returned; the event must then
+// not be acknowledged.
+func (wp *WorkerPool) DeadLetter(event *models.CDCEvent, reason string, err error) error {
+	if sendErr := wp.dlq.SendWithReason(event, reason, err); sendErr != nil {
+		wp.halt.Unpersisted(event, sendErr)
+		return sendErr
+	}
+	return nil
+}
+
+// UseRetryQueue sends events that fail to apply to the retry queue instead
+// of straight to the DLQ. The queue runs while the pool does. Must be called
+// before Start.
+func (wp *WorkerPool) UseRetryQueue(q *RetryQueue) {
+	wp.retry = q
+	for _, worker := range wp.workers {
+		worker.retry = q
+	}
+}
+
+// UseKeyRouting routes events by table and primary key (the Kafka message
+// key) instead of topic and partition, so one table can be applied by every
+// worker while changes to the same row stay in order. Events without a key
+// fall back to partition routing. Ordering between different rows, such as a
+// parent and its children, is not kept. Must be called before Submit.
+func (wp *WorkerPool) UseKeyRouting() {
+	wp.byKey = true
+}
+
+// UseCoalescing merges consecutive events for the same row within a batch
+// into their final state before building SQL (see coalesce). Must be called
+// before Start.
+func (wp *WorkerPool) UseCoalescing() {
+	for _, worker := range wp.workers {
+		worker.coalesce = true
+	}
+}
+
+// UseMultiRowInserts combines consecutive inserts into the same table into
+// multi-row upserts (see combineInserts). If a combined batch fails, the
+// single-row queries are isolated as usual. Must be called before Start.
+func (wp *WorkerPool) UseMultiRowInserts() {
+	for _, worker := range wp.workers {
+		worker.multiRow = true
+	}
+}
+
+// UseBulkLoad attaches the rows of snapshot events to their queries so a
+// PostgreSQL writer can load long runs of them with COPY. A batch of only
+// snapshot rows keeps growing up to batchSize events, or the pool's batch
+// size if that is larger, so a snapshot is not cut into one COPY per batch.
+// Must be called before Start.
+func (wp *WorkerPool) UseBulkLoad(batchSize int) {
+	for _, worker := range wp.workers {
+		worker.bulkLoad = true
+		worker.snapshotBatchSize = max(batchSize, worker.batchSize)
+	}
+}
+
+// DLQ returns the pool's dead letter queue
+func (wp *WorkerPool) DLQ() *DLQ {
+	return wp.dlq
+}
+
+// Halted delivers the error that halted the pool. Once halted, workers stop
+// processing and no further offsets are committed.
+func (wp *WorkerPool) Halted() <-chan error {
+	return wp.halt.errCh
+}
+
+func defaultBuildErrorAction(string) config.FailureAction {
+	return config.FailureDLQ
+}
+
 // Start launches worker goroutines
 func (wp *WorkerPool) Start(ctx context.Context) {
 	for _, worker := range wp.workers {
 		wp.wg.Add(1)
 		go worker.run(ctx)
 	}
+	if wp.retry != nil {
+		wp.retry.Start(ctx)
+	}
 	logger.Log.Info("Worker pool started",
 		zap.Int("workers", len(wp.workers)),
 		zap.Int("batch_size", wp.batchSize))
 }
 
-// Submit routes event to worker based on topic+partition hash
+// Submit routes event to worker based on topic+partition hash, or on
+// table+primary key hash with key routing
 func (wp *WorkerPool) Submit(event *models.CDCEvent) {
+	workerIdx := int(fnv32(wp.routingKey(event))) % len(wp.workers)
+	wp.workers[workerIdx].queue <- event
+}
+
+func (wp *WorkerPool) routingKey(event *models.CDCEvent) string {
+	if wp.byKey && len(event.Key) > 0 {
+		return rowKey(event)
+	}
 	// Topic-aware routing: same topic+partition always goes to same worker
 	// This handles the case where all topics have partition 0
-	key := fmt.Sprintf("%s:%d", event.Topic, event.Partition)
-	workerIdx := int(fnv32(key)) % len(wp.workers)
-	wp.workers[workerIdx].queue <- event
+	return fmt.Sprintf("%s:%d", event.Topic, event.Partition)
+}
+
+// rowKey identifies the row an event changes by its table and key columns
+func rowKey(event *models.CDCEvent) string {
+	var b strings.Builder
+	b.WriteString(event.SourceTable)
+	for _, col := range slices.Sorted(maps.Keys(event.Key)) {
+		fmt.Fprintf(&b, "|%s=%v", col, event.Key[col])
+	}
+	return b.String()
 }
 
 func fnv32(key string) uint32 {
@@ -92,6 +237,9 @@ func (wp *WorkerPool) Stop() {
 		close(worker.queue)
 	}
 	wp.wg.Wait()
+	if wp.retry != nil {
+		wp.retry.Stop()
+	}
 	logger.Log.Info("Worker pool stopped")
 }
 
@@ -99,6 +247,8 @@ func (w *Worker) run(ctx context.Context) {
 	defer w.wg.Done()
 
 	batch := make([]*models.CDCEvent, 0, w.batchSize)
+	snapshotOnly := true // every event in batch is a snapshot read
+	arrived := false     // an event was queued since the last tick
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -108,72 +258,331 @@ func (w *Worker) run(ctx context.Context) {
 			if !ok {
 				// Channel closed, process remaining batch
 				if len(batch) > 0 {
-					w.processBatch(batch)
+					w.drain(ctx, batch)
 				}
 				return
 			}
 
 			batch = append(batch, event)
-			if len(batch) >= w.batchSize {
-				w.processBatch(batch)
+			snapshotOnly = snapshotOnly && event.Operation == "r"
+			arrived = true
+			if w.batchFull(len(batch), snapshotOnly) {
+				w.processBatch(ctx, batch)
 				batch = batch[:0]
+				snapshotOnly = true
 			}
 
 		case <-ticker.C:
-			// Flush incomplete batches after timeout
-			if len(batch) > 0 {
-				w.processBatch(batch)
+			// Flush incomplete batches after timeout. A snapshot batch
+			// is left to grow while its events keep coming.
+			growing := w.bulkLoad && snapshotOnly && arrived
+			arrived = false
+			if len(batch) > 0 && !growing {
+				w.processBatch(ctx, batch)
 				batch = batch[:0]
+				snapshotOnly = true
 			}
 
 		case <-ctx.Done():
 			// Process remaining batch before exit
 			if len(batch) > 0 {
-				w.processBatch(batch)
+				w.drain(ctx, batch)
 			}
 			return
 		}
 	}
 }
 
-func (w *Worker) processBatch(events []*models.CDCEvent) {
+// batchFull reports whether a batch of n events should be written now. With
+// bulk loading, a batch of only snapshot rows grows to snapshotBatchSize.
+func (w *Worker) batchFull(n int, snapshotOnly bool) bool {
+	if w.bulkLoad && snapshotOnly {
+		return n >= w.snapshotBatchSize
+	}
+	return n >= w.batchSize
+}
+
+// drain writes the remaining batch after ctx is cancelled, within
+// drainTimeout. A batch that fails is not isolated and stays unacknowledged.
+func (w *Worker) drain(ctx context.Context, batch []*models.CDCEvent) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), drainTimeout)
+	defer cancel()
+
+	w.draining = true
+	w.processBatch(ctx, batch)
+}
+
+func (w *Worker) processBatch(ctx context.Context, events []*models.CDCEvent) {
+	if w.halt.Halted() {
+		// Leave events unacknowledged so they are replayed after a restart
+		return
+	}
+
 	queries := make([]writer.Query, 0, len(events))
+	built := make([]*models.CDCEvent, 0, len(events)) // event behind each query
+	var dead []buildFailure
 
-	for _, event := range events {
+	pending := w.holdPending(events)
+	if w.halt.Halted() {
+		return
+	}
+	if w.coalesce {
+		pending = coalesce(pending)
+	}
+
+	for _, event := range pending {
 		sql, args, err := w.processor.BuildSQL(event)
 		if err != nil {
-			logger.Log.Debug("Skipping event in batch",
-				zap.String("table", event.SourceTable),
-				zap.Error(err))
+			failure := buildFailure{event: event, reason: processor.Reason(err), err: err}
+			if w.handleBuildError(failure) {
+				dead = append(dead, failure)
+			}
+			if w.halt.Halted() {
+				return
+			}
 			continue
 		}
 
 		queries = append(queries, writer.Query{
-			SQL:   sql,
-			Args:  args,
-			Table: event.SourceTable,
-			Op:    event.GetOperation().String(),
+			SQL:       sql,
+			Args:      args,
+			Table:     event.SourceTable,
+			Op:        event.GetOperation().String(),
+			Topic:     event.Topic,
+			Partition: event.Partition,
+			Offset:    event.Offset,
+			Timestamp: event.Timestamp,
+			Copy:      w.copyRow(event),
 		})
+		built = append(built, event)
+	}
+
+	// Every event is acknowledged once its fate is settled, so the consumer
+	// can commit its offset. Skipped and dead-lettered events are acknowledged too.
+	settled := true
+	defer func() {
+		if settled {
+			ackAll(events)
+		}
+	}()
+
+	for _, f := range dead {
+		if err := w.dlq.SendWithReason(f.event, f.reason, f.err); err != nil {
+			w.halt.Unpersisted(f.event, err)
+			settled = false
+			return
+		}
 	}
 
 	if len(queries) == 0 {
 		return
 	}
 
-	if err := w.writer.ExecuteBatch(queries); err != nil {
-		logger.Log.Error("Batch processing failed",
+	statements := queries
+	if w.multiRow {
+		statements = combineInserts(queries, built, w.processor.BuildInsertRows)
+	}
+
+	settled = w.execute(ctx, statements, queries, built)
+}
+
+// execute applies a batch, isolating the failing events when it fails. It
+// reports whether every event was settled and can be acknowledged.
+func (w *Worker) execute(ctx context.Context, statements, queries []writer.Query, events []*models.CDCEvent) bool {
+	err := w.writer.ExecuteBatch(ctx, statements)
+	if err == nil {
+		w.applied.Record(queries)
+		logger.Log.Debug("Batch processed",
+			zap.Int("worker", w.id),
+			zap.Int("count", len(queries)))
+		return true
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		// Interrupted by shutdown, not by the events: leave them
+		// unacknowledged so they are replayed after a restart
+		logger.Log.Warn("Batch interrupted by shutdown",
 			zap.Int("worker", w.id),
 			zap.Int("batch_size", len(queries)),
 			zap.Error(err))
+		return false
+	case w.draining:
+		// Isolation can take about twice as many round trips as the batch
+		// has events, too many for shutdown
+		logger.Log.Warn("Batch failed while draining, leaving it for replay",
+			zap.Int("worker", w.id),
+			zap.Int("batch_size", len(queries)),
+			zap.Error(err))
+		return false
+	}
+
+	logger.Log.Error("Batch processing failed",
+		zap.Int("worker", w.id),
+		zap.Int("batch_size", len(queries)),
+		zap.Error(err))
+
+	// Commit what can be committed and dead-letter only the events that
+	// fail on their own
+	metrics.BatchIsolations.Inc()
+	w.isolate(ctx, queries, events, err)
+
+	// Unsettled when a poison event could not be kept or shutdown cut
+	// isolation short
+	return !w.halt.Halted() && ctx.Err() == nil
+}
+
+// copyRow returns the row of a snapshot event for bulk loading, or nil when
+// bulk loading is off or the row cannot be built. The query's SQL is used then.
+func (w *Worker) copyRow(event *models.CDCEvent) *writer.CopyRows {
+	if !w.bulkLoad || event.Operation != "r" {
+		return nil
+	}
+	row, err := w.processor.BuildCopyRow(event)
+	if err != nil {
+		logger.Log.Debug("Snapshot row not bulk loadable",
+			zap.String("table", event.SourceTable),
+			zap.Error(err))
+		return nil
+	}
+	return row
+}
+
+// buildFailure is an event that could not be turned into SQL
+type buildFailure struct {
+	event  *models.CDCEvent
+	reason string
+	err    error
+}
+
+// handleBuildError counts the failure and applies the configured action.
+// It reports whether the event should be dead-lettered.
+func (w *Worker) handleBuildError(f buildFailure) bool {
+	op := f.event.GetOperation().String()
+	metrics.EventsFailed.WithLabelValues(f.event.SourceTable, op, f.reason).Inc()
+
+	switch w.onError(f.reason) {
+	case config.FailureSkip:
+		logger.Log.Warn("Skipping event that failed SQL generation",
+			zap.String("table", f.event.SourceTable),
+			zap.String("op", op),
+			zap.String("reason", f.reason),
+			zap.Error(f.err))
+		return false
+	case config.FailureHalt:
+		logger.Log.Error("Halting on event that failed SQL generation",
+			zap.String("table", f.event.SourceTable),
+			zap.String("op", op),
+			zap.String("reason", f.reason),
+			zap.String("topic", f.event.Topic),
+			zap.Int32("partition", f.event.Partition),
+			zap.Int64("offset", f.event.Offset),
+			zap.Error(f.err))
+		w.halt.Trigger(fmt.Errorf("%s on %s at %s/%d:%d: %w",
+			f.reason, f.event.SourceTable, f.event.Topic, f.event.Partition, f.event.Offset, f.err))
+		return false
+	default:
+		return true
+	}
+}
 
-		// Send failed events to DLQ
-		for _, event := range events {
-			w.dlq.Send(event, err)
+// isolate bisects a failed batch until every failing query stands alone.
+// Halves that commit are recorded as applied; single queries that still fail
+// are poison events and go to the retry queue, or the DLQ without one.
+// Halves run in order, so events for the same row keep their relative order.
+// Isolation stops once ctx is cancelled or the pool halts.
+func (w *Worker) isolate(ctx context.Context, queries []writer.Query, events []*models.CDCEvent, err error) {
+	if ctx.Err() != nil || w.halt.Halted() {
+		return
+	}
+	if len(queries) == 1 {
+		// Counted here rather than by the writer, which sees every failed
+		// half of the bisection
+		metrics.EventsFailed.WithLabelValues(queries[0].Table, queries[0].Op, ReasonExecutionError).Inc()
+		metrics.PoisonEvents.WithLabelValues(queries[0].Table, queries[0].Op).Inc()
+		var keepErr error
+		if w.retry != nil {
+			keepErr = w.retry.Add(events[0], ReasonExecutionError, err)
+		} else {
+			keepErr = w.dlq.Send(events[0], err)
+		}
+		if keepErr != nil {
+			w.halt.Unpersisted(events[0], keepErr)
 		}
 		return
 	}
 
-	logger.Log.Debug("Batch processed",
-		zap.Int("worker", w.id),
-		zap.Int("count", len(queries)))
+	mid := len(queries) / 2
+	halves := []struct {
+		queries []writer.Query
+		events  []*models.CDCEvent
+	}{
+		{queries[:mid], events[:mid]},
+		{queries[mid:], events[mid:]},
+	}
+
+	for _, half := range halves {
+		// A poison event from an earlier half may now be waiting for a retry
+		half.queries, half.events = w.holdPendingQueries(half.queries, half.events)
+		if w.halt.Halted() {
+			return
+		}
+		if len(half.queries) == 0 {
+			continue
+		}
+		if err := w.writer.ExecuteBatch(ctx, half.queries); err != nil {
+			w.isolate(ctx, half.queries, half.events, err)
+			continue
+		}
+		w.applied.Record(half.queries)
+	}
+}
+
+// holdPending hands events for rows with a pending retry to the retry queue
+// and returns the ones to apply now. It halts the pool if an event cannot be
+// kept.
+func (w *Worker) holdPending(events []*models.CDCEvent) []*models.CDCEvent {
+	if w.retry == nil {
+		return events
+	}
+	ready := make([]*models.CDCEvent, 0, len(events))
+	for _, event := range events {
+		held, err := w.retry.Hold(event)
+		if err != nil {
+			w.halt.Unpersisted(event, err)
+			return nil
+		}
+		if !held {
+			ready = append(ready, event)
+		}
+	}
+	return ready
+}
+
+// holdPendingQueries is holdPending for built queries and their events
+func (w *Worker) holdPendingQueries(queries []writer.Query, events []*models.CDCEvent) ([]writer.Query, []*models.CDCEvent) {
+	if w.retry == nil {
+		return queries, events
+	}
+	readyQueries := make([]writer.Query, 0, len(queries))
+	readyEvents := make([]*models.CDCEvent, 0, len(events))
+	for i, event := range events {
+		held, err := w.retry.Hold(event)
+		if err != nil {
+			w.halt.Unpersisted(event, err)
+			return nil, nil
+		}
+		if !held {
+			readyQueries = append(readyQueries, queries[i])
+			readyEvents = append(readyEvents, event)
+		}
+	}
+	return readyQueries, readyEvents
+}
+
+// ackAll acknowledges every event in the batch.
+func ackAll(events []*models.CDCEvent) {
+	for _, event := range events {
+		event.Ack()
+	}
 }
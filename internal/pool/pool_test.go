@@ -0,0 +1,364 @@
+package pool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/internal/processor"
+	"github.com/sparkiss/pos-cdc/internal/writer"
+)
+
+// fakeWriter fails any batch that contains a query with SQL "BAD", or any
+// batch once ctx is cancelled, and records the offsets of every committed query
+type fakeWriter struct {
+	calls     int
+	committed []int64
+}
+
+func (f *fakeWriter) ExecuteBatch(ctx context.Context, queries []writer.Query) error {
+	f.calls++
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, q := range queries {
+		if q.SQL == "BAD" {
+			return errors.New("constraint violation")
+		}
+	}
+	for _, q := range queries {
+		f.committed = append(f.committed, q.Offset)
+	}
+	return nil
+}
+
+func (f *fakeWriter) Ping() error  { return nil }
+func (f *fakeWriter) Close() error { return nil }
+func (f *fakeWriter) DB() *sql.DB  { return nil }
+
+func isolationBatch(badOffsets ...int64) ([]writer.Query, []*models.CDCEvent) {
+	var queries []writer.Query
+	var events []*models.CDCEvent
+	for offset := int64(0); offset < 8; offset++ {
+		q := writer.Query{SQL: "OK", Table: "isolate_orders", Op: "INSERT", Offset: offset}
+		if slices.Contains(badOffsets, offset) {
+			q.SQL = "BAD"
+		}
+		queries = append(queries, q)
+		events = append(events, &models.CDCEvent{SourceTable: "isolate_orders", Operation: "c", Offset: offset})
+	}
+	return queries, events
+}
+
+func TestWorker_Isolate(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	fw := &fakeWriter{}
+	dlq := NewDLQ()
+	defer dlq.Close()
+	w := &Worker{writer: fw, dlq: dlq, applied: newAppliedTracker(), halt: newHaltSignal()}
+
+	poisoned := metrics.PoisonEvents.WithLabelValues("isolate_orders", "INSERT")
+	before := testutil.ToFloat64(poisoned)
+	failed := metrics.EventsFailed.WithLabelValues("isolate_orders", "INSERT", ReasonExecutionError)
+	failedBefore := testutil.ToFloat64(failed)
+
+	queries, events := isolationBatch(2, 5)
+	w.isolate(context.Background(), queries, events, errors.New("constraint violation"))
+
+	// Good events are committed in their original order
+	if want := []int64{0, 1, 3, 4, 6, 7}; !slices.Equal(fw.committed, want) {
+		t.Errorf("committed = %v, want %v", fw.committed, want)
+	}
+
+	if n := dlq.Count(); n != 2 {
+		t.Fatalf("DLQ count = %d, want 2", n)
+	}
+	dlq.Close()
+	entries, err := ReadDLQ(DefaultDLQPath)
+	if err != nil {
+		t.Fatalf("ReadDLQ() error = %v", err)
+	}
+	var dead []int64
+	for _, e := range entries {
+		dead = append(dead, e.Offset)
+	}
+	if want := []int64{2, 5}; !slices.Equal(dead, want) {
+		t.Errorf("dead-lettered offsets = %v, want %v", dead, want)
+	}
+
+	if got := testutil.ToFloat64(poisoned) - before; got != 2 {
+		t.Errorf("poison events counted = %v, want 2", got)
+	}
+	// Once per poison event, not once per failed half
+	if got := testutil.ToFloat64(failed) - failedBefore; got != 2 {
+		t.Errorf("execution errors counted = %v, want 2", got)
+	}
+}
+
+func TestWorker_Isolate_SinglePoison(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	fw := &fakeWriter{}
+	dlq := NewDLQ()
+	defer dlq.Close()
+	w := &Worker{writer: fw, dlq: dlq, applied: newAppliedTracker(), halt: newHaltSignal()}
+
+	queries, events := isolationBatch(7)
+	w.isolate(context.Background(), queries, events, errors.New("constraint violation"))
+
+	if len(fw.committed) != 7 {
+		t.Errorf("committed %d events, want 7", len(fw.committed))
+	}
+	if n := dlq.Count(); n != 1 {
+		t.Errorf("DLQ count = %d, want 1", n)
+	}
+	// Bisection needs far fewer round trips than replaying every row
+	if fw.calls > 6 {
+		t.Errorf("ExecuteBatch calls = %d, want at most 6", fw.calls)
+	}
+}
+
+func TestWorker_Isolate_Cancelled(t *testing.T) {
+	fw := &fakeWriter{}
+	dlq := NewDLQWithSink(nil)
+	w := &Worker{writer: fw, dlq: dlq, applied: newAppliedTracker(), halt: newHaltSignal()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	queries, events := isolationBatch(2)
+	w.isolate(ctx, queries, events, context.Canceled)
+
+	// Shutdown is not the events' fault: nothing is bisected or dead-lettered
+	if fw.calls != 0 || dlq.Count() != 0 {
+		t.Errorf("calls = %d, DLQ = %d, want 0, 0", fw.calls, dlq.Count())
+	}
+}
+
+func TestWorker_Execute_Draining(t *testing.T) {
+	fw := &fakeWriter{}
+	dlq := NewDLQWithSink(nil)
+	w := &Worker{writer: fw, dlq: dlq, applied: newAppliedTracker(), halt: newHaltSignal(), draining: true}
+
+	queries, events := isolationBatch(2)
+	if w.execute(context.Background(), queries, queries, events) {
+		t.Error("execute() = true, want a failed batch left unacknowledged while draining")
+	}
+	// No isolation on shutdown: one attempt, nothing dead-lettered
+	if fw.calls != 1 || dlq.Count() != 0 {
+		t.Errorf("calls = %d, DLQ = %d, want 1, 0", fw.calls, dlq.Count())
+	}
+
+	w.draining = false
+	if !w.execute(context.Background(), queries, queries, events) {
+		t.Error("execute() = false, want the batch settled by isolation")
+	}
+	if dlq.Count() != 1 {
+		t.Errorf("DLQ = %d, want the poison event dead-lettered", dlq.Count())
+	}
+}
+
+func TestWorker_BatchFull(t *testing.T) {
+	wp := New(1, 100, nil, &fakeWriter{})
+	wp.UseBulkLoad(300)
+	w := wp.workers[0]
+
+	// A snapshot three batches long is written as one batch, so its rows
+	// form one COPY run
+	if w.batchFull(299, true) || !w.batchFull(300, true) {
+		t.Errorf("batchFull() should hold snapshot rows until 300")
+	}
+	// Any other change keeps the usual batch size
+	if !w.batchFull(100, false) {
+		t.Errorf("batchFull(100, false) = false, want a mixed batch written at 100")
+	}
+
+	// The snapshot batch size never shrinks batches
+	wp = New(1, 100, nil, &fakeWriter{})
+	wp.UseBulkLoad(0)
+	if !wp.workers[0].batchFull(100, true) {
+		t.Errorf("batchFull(100, true) = false, want BATCH_SIZE as the floor")
+	}
+}
+
+func TestWorker_HandleBuildError(t *testing.T) {
+	tests := []struct {
+		action   config.FailureAction
+		wantDLQ  bool
+		wantHalt bool
+	}{
+		{config.FailureDLQ, true, false},
+		{config.FailureSkip, false, false},
+		{config.FailureHalt, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.action), func(t *testing.T) {
+			w := &Worker{
+				halt:    newHaltSignal(),
+				onError: func(string) config.FailureAction { return tt.action },
+			}
+
+			failed := metrics.EventsFailed.WithLabelValues("build_orders", "UPDATE", processor.ReasonNoColumns)
+			before := testutil.ToFloat64(failed)
+
+			event := &models.CDCEvent{SourceTable: "build_orders", Operation: "u", Topic: "pos.orders", Offset: 42}
+			err := fmt.Errorf("%w for table build_orders", processor.ErrNoColumns)
+			got := w.handleBuildError(buildFailure{event: event, reason: processor.Reason(err), err: err})
+
+			if got != tt.wantDLQ {
+				t.Errorf("handleBuildError() = %v, want %v", got, tt.wantDLQ)
+			}
+			if w.halt.Halted() != tt.wantHalt {
+				t.Errorf("Halted() = %v, want %v", w.halt.Halted(), tt.wantHalt)
+			}
+			if tt.wantHalt {
+				if err := <-w.halt.errCh; !errors.Is(err, processor.ErrNoColumns) {
+					t.Errorf("halt error = %v, want wrapped ErrNoColumns", err)
+				}
+			}
+			// Every action counts the failure, including skip
+			if delta := testutil.ToFloat64(failed) - before; delta != 1 {
+				t.Errorf("cdc_events_failed_total delta = %v, want 1", delta)
+			}
+		})
+	}
+}
+
+func TestWorker_ProcessBatch_Halted(t *testing.T) {
+	fw := &fakeWriter{}
+	w := &Worker{writer: fw, halt: newHaltSignal()}
+	w.halt.Trigger(errors.New("stop"))
+
+	acked := false
+	event := &models.CDCEvent{SourceTable: "orders", Operation: "c"}
+	event.SetAck(func() { acked = true })
+
+	w.processBatch(context.Background(), []*models.CDCEvent{event})
+
+	if acked {
+		t.Error("event was acknowledged after halt, want it left for replay")
+	}
+	if fw.calls != 0 {
+		t.Errorf("ExecuteBatch calls = %d, want 0", fw.calls)
+	}
+}
+
+// failingSink rejects every entry
+type failingSink struct{}
+
+func (failingSink) Write(DLQEntry) error { return errors.New("disk full") }
+func (failingSink) Close() error         { return nil }
+
+func TestWorkerPool_DeadLetter(t *testing.T) {
+	wp := New(1, 10, nil, &fakeWriter{})
+	event := &models.CDCEvent{SourceTable: "orders", Topic: "pos.orders", RawValue: []byte("not json")}
+
+	if err := wp.DeadLetter(event, "parse_error", errors.New("invalid character")); err != nil {
+		t.Fatalf("DeadLetter() error = %v", err)
+	}
+	if wp.DLQ().Count() != 1 || wp.halt.Halted() {
+		t.Errorf("DLQ = %d, halted = %v, want 1, false", wp.DLQ().Count(), wp.halt.Halted())
+	}
+
+	wp.UseDLQ(NewDLQWithSink(failingSink{}))
+	if err := wp.DeadLetter(event, "parse_error", errors.New("invalid character")); err == nil {
+		t.Error("DeadLetter() error = nil with a failing sink")
+	}
+	if !wp.halt.Halted() {
+		t.Error("pool not halted after the entry could not be kept")
+	}
+}
+
+func TestWorker_Isolate_HaltsWhenDLQFails(t *testing.T) {
+	fw := &fakeWriter{}
+	w := &Worker{writer: fw, dlq: NewDLQWithSink(failingSink{}), applied: newAppliedTracker(), halt: newHaltSignal()}
+
+	queries, events := isolationBatch(2)
+	w.isolate(context.Background(), queries, events, errors.New("constraint violation"))
+
+	if !w.halt.Halted() {
+		t.Fatal("pool not halted after the poison event could not be dead-lettered")
+	}
+	// Isolation stops at the poison event
+	if slices.Contains(fw.committed, 4) {
+		t.Errorf("committed = %v, want isolation stopped after the halt", fw.committed)
+	}
+}
+
+// queuedWorker returns the index of the worker holding the only queued event
+func queuedWorker(t *testing.T, wp *WorkerPool) int {
+	t.Helper()
+	for i, w := range wp.workers {
+		if len(w.queue) > 0 {
+			<-w.queue
+			return i
+		}
+	}
+	t.Fatal("no worker received the event")
+	return -1
+}
+
+func TestWorkerPool_Submit_KeyRouting(t *testing.T) {
+	wp := New(4, 10, nil, &fakeWriter{})
+	wp.UseKeyRouting()
+
+	event := func(id int) *models.CDCEvent {
+		return &models.CDCEvent{SourceTable: "orders", Topic: "pos.orders", Key: map[string]any{"id": float64(id)}}
+	}
+
+	// Rows of one partition spread across workers
+	used := make(map[int]bool)
+	for id := range 32 {
+		wp.Submit(event(id))
+		used[queuedWorker(t, wp)] = true
+	}
+	if len(used) < 2 {
+		t.Errorf("key routing used %d worker(s), want the table spread across several", len(used))
+	}
+
+	// The same row always goes to the same worker, whatever the key order
+	wp.Submit(&models.CDCEvent{SourceTable: "items", Key: map[string]any{"order_id": 1, "line": 2}})
+	first := queuedWorker(t, wp)
+	for range 5 {
+		wp.Submit(&models.CDCEvent{SourceTable: "items", Key: map[string]any{"line": 2, "order_id": 1}})
+		if got := queuedWorker(t, wp); got != first {
+			t.Fatalf("same row went to worker %d, then %d", first, got)
+		}
+	}
+}
+
+func TestWorkerPool_Submit_PartitionRouting(t *testing.T) {
+	wp := New(4, 10, nil, &fakeWriter{})
+
+	wp.Submit(&models.CDCEvent{SourceTable: "orders", Topic: "pos.orders", Key: map[string]any{"id": 0}})
+	first := queuedWorker(t, wp)
+	for id := 1; id < 16; id++ {
+		wp.Submit(&models.CDCEvent{SourceTable: "orders", Topic: "pos.orders", Key: map[string]any{"id": id}})
+		if got := queuedWorker(t, wp); got != first {
+			t.Fatalf("partition routing sent one partition to workers %d and %d", first, got)
+		}
+	}
+}
+
+func TestRowKey(t *testing.T) {
+	a := rowKey(&models.CDCEvent{SourceTable: "items", Key: map[string]any{"order_id": 1, "line": 2}})
+	if want := "items|line=2|order_id=1"; a != want {
+		t.Errorf("rowKey() = %q, want %q", a, want)
+	}
+	b := rowKey(&models.CDCEvent{SourceTable: "orders", Key: map[string]any{"line": 2, "order_id": 1}})
+	if a == b {
+		t.Error("rowKey() should differ between tables")
+	}
+}
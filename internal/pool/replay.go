@@ -0,0 +1,351 @@
+package pool
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/internal/writer"
+	"github.com/sparkiss/pos-cdc/pkg/logger"
+)
+
+// EventBuilder turns an event into SQL. Implemented by processor.Processor.
+type EventBuilder interface {
+	BuildSQL(event *models.CDCEvent) (string, []any, error)
+}
+
+// DLQFilter selects DLQ entries. Zero-valued fields match everything.
+type DLQFilter struct {
+	Table         string
+	Op            string // c/u/d or INSERT/UPDATE/DELETE
+	ErrorContains string
+	Since         time.Time
+	Until         time.Time
+}
+
+// Match reports whether the entry passes every set condition.
+func (f DLQFilter) Match(entry DLQEntry) bool {
+	if entry.Event == nil {
+		return false
+	}
+	if f.Table != "" && entry.Event.SourceTable != f.Table {
+		return false
+	}
+	if f.Op != "" && !strings.EqualFold(f.Op, entry.Event.Operation) &&
+		!strings.EqualFold(f.Op, entry.Event.GetOperation().String()) {
+		return false
+	}
+	if f.ErrorContains != "" && !strings.Contains(entry.Error, f.ErrorContains) {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Timestamp.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// ToEvent rebuilds the CDC event stored in the entry.
+func (e DLQEntry) ToEvent() *models.CDCEvent {
+	event := *e.Event
+	event.Payload = e.Payload
+	if event.Payload == nil {
+		event.Payload = make(map[string]any)
+	}
+	event.Topic = e.Topic
+	event.Partition = e.Partition
+	event.Offset = e.Offset
+	return &event
+}
+
+// ReadDLQ reads every entry of a DLQ file in order. Rotated files ending
+// in .gz are decompressed.
+func ReadDLQ(path string) ([]DLQEntry, error) {
+	return readJSONL[DLQEntry](path)
+}
+
+// DLQFiles returns the rotated files of the DLQ file at path, oldest first,
+// followed by path itself when it exists. It fails with fs.ErrNotExist when
+// there are neither.
+func DLQFiles(path string) ([]string, error) {
+	files, err := RotatedDLQFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	} else if !errors.Is(err, fs.ErrNotExist) || len(files) == 0 {
+		return nil, err
+	}
+	return files, nil
+}
+
+// ReadDLQFiles reads the entries of the DLQ file at path and of its rotated
+// files, oldest first
+func ReadDLQFiles(path string) ([]DLQEntry, error) {
+	var entries []DLQEntry
+	err := ScanDLQFiles(path, func(_ int, entry DLQEntry) bool {
+		entries = append(entries, entry)
+		return true
+	})
+	return entries, err
+}
+
+// errStopScan ends a scan early without an error
+var errStopScan = errors.New("scan stopped")
+
+// ScanDLQFiles calls fn with each entry of the DLQ file at path and of its
+// rotated files, oldest first, with its 1-based index. Entries are read one
+// at a time, and the scan stops when fn returns false. It fails with
+// fs.ErrNotExist when there are no files.
+func ScanDLQFiles(path string, fn func(index int, entry DLQEntry) bool) error {
+	files, err := DLQFiles(path)
+	if err != nil {
+		return err
+	}
+	index := 0
+	for _, name := range files {
+		err := scanJSONL(name, func(entry DLQEntry) error {
+			index++
+			if !fn(index, entry) {
+				return errStopScan
+			}
+			return nil
+		})
+		if errors.Is(err, errStopScan) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDLQ replaces a DLQ file with the given entries, gzipped if the name
+// ends in .gz. The file is written to a temporary path first and renamed,
+// so a crash never leaves it half written.
+func WriteDLQ(path string, entries []DLQEntry) error {
+	return writeJSONL(path, entries)
+}
+
+func readJSONL[T any](path string) ([]T, error) {
+	var items []T
+	err := scanJSONL(path, func(item T) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// scanJSONL decodes the lines of a JSONL file one at a time, stopping at
+// the first error fn returns
+func scanJSONL[T any](path string, fn func(T) error) error {
+	file, err := os.Open(path) // #nosec G304 - path is chosen by the operator
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defer func() { _ = zr.Close() }()
+		r = zr
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // rows can be large
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var item T
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.UseNumber() // keep payload numbers exact, as the consumer does
+		if err := dec.Decode(&item); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func writeJSONL[T any](path string, items []T) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	w := bufio.NewWriter(tmp)
+	var out io.Writer = w
+	var zw *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		zw = gzip.NewWriter(w)
+		out = zw
+	}
+	enc := json.NewEncoder(out)
+	for _, item := range items {
+		if err := enc.Encode(item); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReplayResult summarizes a DLQ replay.
+type ReplayResult struct {
+	Total    int // entries in the file
+	Matched  int // entries selected by the filter
+	Replayed int // entries applied and removed from the file
+	Failed   int // entries that failed again and were kept
+}
+
+// ReplayDLQ re-applies the entries of a DLQ file and its rotated files that
+// match the filter, one at a time, oldest first. Applied entries are
+// removed; entries that still fail are kept with their error updated and
+// Retries incremented. Each file is rewritten in place, compressed files
+// staying compressed. Cancelling ctx stops the replay; the rest of the
+// entries are kept as they were. It fails with ErrDLQLocked while a
+// consumer's FileSink has the file open.
+func ReplayDLQ(ctx context.Context, path string, filter DLQFilter, builder EventBuilder, w writer.Writer) (ReplayResult, error) {
+	lock, err := lockDLQ(path)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	files, err := DLQFiles(path)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	var result ReplayResult
+	for _, name := range files {
+		if err := replayFile(ctx, name, name != path, filter, builder, w, &result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// replayFile replays the matching entries of one DLQ file, adding to result.
+// A rotated file left empty is removed.
+func replayFile(ctx context.Context, path string, rotated bool, filter DLQFilter, builder EventBuilder, w writer.Writer, result *ReplayResult) error {
+	entries, err := ReadDLQ(path)
+	if err != nil {
+		return err
+	}
+
+	result.Total += len(entries)
+	replayed, failed := result.Replayed, result.Failed
+	remaining := make([]DLQEntry, 0, len(entries))
+
+	for _, entry := range entries {
+		if !filter.Match(entry) {
+			remaining = append(remaining, entry)
+			continue
+		}
+		result.Matched++
+
+		if ctx.Err() != nil {
+			remaining = append(remaining, entry)
+			continue
+		}
+		if err := replayEntry(ctx, entry, builder, w); err != nil {
+			if ctx.Err() != nil {
+				// Interrupted rather than failed: kept as it was
+				remaining = append(remaining, entry)
+				continue
+			}
+			logger.Log.Warn("DLQ entry failed again",
+				zap.String("table", entry.Event.SourceTable),
+				zap.String("op", entry.Event.Operation),
+				zap.Error(err))
+			entry.Error = err.Error()
+			entry.Retries++
+			remaining = append(remaining, entry)
+			result.Failed++
+			continue
+		}
+		result.Replayed++
+	}
+
+	if result.Replayed == replayed && result.Failed == failed {
+		return nil
+	}
+	if rotated && len(remaining) == 0 {
+		return os.Remove(path)
+	}
+	info, statErr := os.Stat(path)
+	if err := WriteDLQ(path, remaining); err != nil {
+		return fmt.Errorf("failed to rewrite DLQ file %s: %w", path, err)
+	}
+	if rotated && statErr == nil {
+		// Retention is measured from the rotation, not from the replay
+		_ = os.Chtimes(path, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+// replayEntry builds and executes a single entry. The Kafka position is left
+// off the query so a replay never moves stored exactly-once offsets back.
+func replayEntry(ctx context.Context, entry DLQEntry, builder EventBuilder, w writer.Writer) error {
+	event := entry.ToEvent()
+
+	sql, args, err := builder.BuildSQL(event)
+	if err != nil {
+		return err
+	}
+
+	return w.ExecuteBatch(ctx, []writer.Query{{
+		SQL:   sql,
+		Args:  args,
+		Table: event.SourceTable,
+		Op:    event.GetOperation().String(),
+	}})
+}
@@ -0,0 +1,270 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/models"
+)
+
+// fakeBuilder fails to build rows with status "invalid" and builds SQL
+// that fakeWriter rejects for rows with status "bad"
+type fakeBuilder struct{}
+
+func (fakeBuilder) BuildSQL(event *models.CDCEvent) (string, []any, error) {
+	if event.Payload["status"] == "invalid" {
+		return "", nil, errors.New("missing primary key values in payload")
+	}
+	if event.Payload["status"] == "bad" {
+		return "BAD", nil, nil
+	}
+	return "OK", []any{event.Payload["id"]}, nil
+}
+
+func writeTestDLQ(t *testing.T, entries []DLQEntry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	if err := WriteDLQ(path, entries); err != nil {
+		t.Fatalf("WriteDLQ() error = %v", err)
+	}
+	return path
+}
+
+func dlqEntry(table, op, status string, at time.Time) DLQEntry {
+	return DLQEntry{
+		Event:     &models.CDCEvent{Operation: op, SourceTable: table},
+		Error:     "constraint violation",
+		Timestamp: at,
+		Payload:   map[string]any{"id": float64(1), "status": status},
+		Topic:     "pos.orders",
+		Offset:    7,
+	}
+}
+
+func TestDLQ_SendPersistsPayload(t *testing.T) {
+	tempDir, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	dlq := NewDLQ()
+	dlq.Send(&models.CDCEvent{
+		Operation:   "u",
+		SourceTable: "orders",
+		Topic:       "pos.orders",
+		Partition:   2,
+		Offset:      99,
+		Payload:     map[string]any{"id": 5, "status": "paid"},
+	}, errors.New("boom"))
+	dlq.Close()
+
+	entries, err := ReadDLQ(filepath.Join(tempDir, "var", "dlq", "dlq.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadDLQ() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("ReadDLQ() returned %d entries, want 1", len(entries))
+	}
+
+	event := entries[0].ToEvent()
+	if event.Payload["status"] != "paid" || event.Payload["id"] != json.Number("5") {
+		t.Errorf("Payload = %v, want id 5 status paid", event.Payload)
+	}
+	if event.Topic != "pos.orders" || event.Partition != 2 || event.Offset != 99 {
+		t.Errorf("position = %s/%d:%d, want pos.orders/2:99", event.Topic, event.Partition, event.Offset)
+	}
+	if entries[0].Reason != ReasonExecutionError {
+		t.Errorf("Reason = %q, want %q", entries[0].Reason, ReasonExecutionError)
+	}
+}
+
+func TestDLQFilter_Match(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	entry := dlqEntry("orders", "u", "paid", at)
+
+	tests := []struct {
+		name   string
+		filter DLQFilter
+		want   bool
+	}{
+		{"empty filter", DLQFilter{}, true},
+		{"table", DLQFilter{Table: "orders"}, true},
+		{"other table", DLQFilter{Table: "items"}, false},
+		{"op code", DLQFilter{Op: "u"}, true},
+		{"op name", DLQFilter{Op: "update"}, true},
+		{"other op", DLQFilter{Op: "d"}, false},
+		{"error substring", DLQFilter{ErrorContains: "constraint"}, true},
+		{"other error", DLQFilter{ErrorContains: "deadlock"}, false},
+		{"inside range", DLQFilter{Since: at.Add(-time.Hour), Until: at.Add(time.Hour)}, true},
+		{"before since", DLQFilter{Since: at.Add(time.Minute)}, false},
+		{"after until", DLQFilter{Until: at.Add(-time.Minute)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(entry); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayDLQ(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	path := writeTestDLQ(t, []DLQEntry{
+		dlqEntry("orders", "c", "paid", at),
+		dlqEntry("orders", "u", "bad", at),
+		dlqEntry("items", "c", "paid", at),
+		dlqEntry("orders", "d", "invalid", at),
+	})
+
+	fw := &fakeWriter{}
+	result, err := ReplayDLQ(context.Background(), path, DLQFilter{Table: "orders"}, fakeBuilder{}, fw)
+	if err != nil {
+		t.Fatalf("ReplayDLQ() error = %v", err)
+	}
+
+	want := ReplayResult{Total: 4, Matched: 3, Replayed: 1, Failed: 2}
+	if result != want {
+		t.Errorf("ReplayDLQ() = %+v, want %+v", result, want)
+	}
+
+	remaining, err := ReadDLQ(path)
+	if err != nil {
+		t.Fatalf("ReadDLQ() error = %v", err)
+	}
+	if len(remaining) != 3 {
+		t.Fatalf("remaining entries = %d, want 3", len(remaining))
+	}
+
+	// Order is preserved; failures are retried, filtered-out entries untouched
+	if remaining[0].Event.Operation != "u" || remaining[0].Retries != 1 {
+		t.Errorf("remaining[0] = %s retries %d, want u retries 1", remaining[0].Event.Operation, remaining[0].Retries)
+	}
+	if remaining[1].Event.SourceTable != "items" || remaining[1].Retries != 0 {
+		t.Errorf("remaining[1] = %s retries %d, want items retries 0", remaining[1].Event.SourceTable, remaining[1].Retries)
+	}
+	if remaining[2].Retries != 1 || remaining[2].Error != "missing primary key values in payload" {
+		t.Errorf("remaining[2] = retries %d error %q, want updated error", remaining[2].Retries, remaining[2].Error)
+	}
+	if remaining[0].Payload["status"] != "bad" {
+		t.Errorf("remaining payload lost: %v", remaining[0].Payload)
+	}
+}
+
+func TestReplayDLQ_Cancelled(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	path := writeTestDLQ(t, []DLQEntry{dlqEntry("orders", "c", "paid", at)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fw := &fakeWriter{}
+	result, err := ReplayDLQ(ctx, path, DLQFilter{}, fakeBuilder{}, fw)
+	if err != nil {
+		t.Fatalf("ReplayDLQ() error = %v", err)
+	}
+	if want := (ReplayResult{Total: 1, Matched: 1}); result != want || fw.calls != 0 {
+		t.Errorf("ReplayDLQ() = %+v with %d calls, want %+v and none", result, fw.calls, want)
+	}
+
+	remaining, err := ReadDLQ(path)
+	if err != nil || len(remaining) != 1 || remaining[0].Retries != 0 {
+		t.Errorf("remaining = %+v, %v, want the entry untouched", remaining, err)
+	}
+}
+
+func TestReplayDLQ_LockedBySink(t *testing.T) {
+	path := writeTestDLQ(t, []DLQEntry{dlqEntry("orders", "c", "paid", time.Now())})
+
+	sink, err := NewFileSink(path, RotationPolicy{})
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	if _, err := NewFileSink(path, RotationPolicy{}); !errors.Is(err, ErrDLQLocked) {
+		t.Errorf("second NewFileSink() error = %v, want ErrDLQLocked", err)
+	}
+
+	fw := &fakeWriter{}
+	if _, err := ReplayDLQ(context.Background(), path, DLQFilter{}, fakeBuilder{}, fw); !errors.Is(err, ErrDLQLocked) {
+		t.Fatalf("ReplayDLQ() error = %v, want ErrDLQLocked", err)
+	}
+	if fw.calls != 0 {
+		t.Errorf("ExecuteBatch calls = %d while locked, want 0", fw.calls)
+	}
+
+	// Once the consumer has stopped, replay goes ahead
+	_ = sink.Close()
+	result, err := ReplayDLQ(context.Background(), path, DLQFilter{}, fakeBuilder{}, fw)
+	if err != nil || result.Replayed != 1 {
+		t.Errorf("ReplayDLQ() after Close = %+v, %v, want 1 replayed", result, err)
+	}
+}
+
+func TestReplayDLQ_RotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dlq.jsonl")
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	older := filepath.Join(dir, "dlq-20250101T000000.000.jsonl.gz")
+	newer := filepath.Join(dir, "dlq-20250102T000000.000.jsonl.gz")
+	for name, entries := range map[string][]DLQEntry{
+		older: {dlqEntry("orders", "c", "paid", at), dlqEntry("orders", "c", "bad", at)},
+		newer: {dlqEntry("orders", "u", "paid", at)},
+		path:  {dlqEntry("items", "c", "paid", at)},
+	} {
+		if err := WriteDLQ(name, entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Inspection sees the rotated entries first
+	entries, err := ReadDLQFiles(path)
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("ReadDLQFiles() = %d entries, %v, want 4", len(entries), err)
+	}
+	if entries[0].Event.SourceTable != "orders" || entries[3].Event.SourceTable != "items" {
+		t.Errorf("ReadDLQFiles() order = %s ... %s, want rotated entries first",
+			entries[0].Event.SourceTable, entries[3].Event.SourceTable)
+	}
+
+	result, err := ReplayDLQ(context.Background(), path, DLQFilter{}, fakeBuilder{}, &fakeWriter{})
+	if err != nil {
+		t.Fatalf("ReplayDLQ() error = %v", err)
+	}
+	if want := (ReplayResult{Total: 4, Matched: 4, Replayed: 3, Failed: 1}); result != want {
+		t.Errorf("ReplayDLQ() = %+v, want %+v", result, want)
+	}
+
+	// The emptied rotated file is gone; the other keeps its failing entry
+	if _, err := os.Stat(newer); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("emptied rotated file still exists: %v", err)
+	}
+	if remaining, err := ReadDLQ(older); err != nil || len(remaining) != 1 || remaining[0].Retries != 1 {
+		t.Errorf("rotated file = %+v, %v, want the failing entry", remaining, err)
+	}
+	if remaining, _ := ReadDLQ(path); len(remaining) != 0 {
+		t.Errorf("active file has %d entries, want 0", len(remaining))
+	}
+}
+
+func TestReplayDLQ_NoMatchesLeavesFile(t *testing.T) {
+	path := writeTestDLQ(t, []DLQEntry{dlqEntry("orders", "c", "paid", time.Now())})
+	before, _ := os.Stat(path)
+
+	result, err := ReplayDLQ(context.Background(), path, DLQFilter{Table: "items"}, fakeBuilder{}, &fakeWriter{})
+	if err != nil {
+		t.Fatalf("ReplayDLQ() error = %v", err)
+	}
+	if result.Matched != 0 {
+		t.Errorf("Matched = %d, want 0", result.Matched)
+	}
+
+	after, _ := os.Stat(path)
+	if !os.SameFile(before, after) {
+		t.Error("file was rewritten although nothing matched")
+	}
+}
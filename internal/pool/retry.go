@@ -0,0 +1,419 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/internal/writer"
+	"github.com/sparkiss/pos-cdc/pkg/logger"
+)
+
+// retryPollInterval is how often the retry queue looks for due entries
+const retryPollInterval = time.Second
+
+// RetryPolicy controls the delayed retry of events that failed to apply
+type RetryPolicy struct {
+	MaxAttempts int           // delayed retries before an event is dead-lettered
+	BaseDelay   time.Duration // delay before the first retry, doubled for each one after
+	MaxDelay    time.Duration // upper bound for the delay
+}
+
+// Delay returns how long to wait before the given retry (1-based)
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// RetryEntry is an event waiting for its next delayed retry
+type RetryEntry struct {
+	DLQEntry
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+// RetryQueue holds events that failed to apply and retries them later with
+// exponential backoff, for failures that may clear up on their own, such as
+// a dropped connection or a row whose parent has not arrived yet. Pending
+// entries are appended to a JSONL file before the event is acknowledged,
+// so they survive restarts, and the file is compacted to the pending
+// entries after each retry pass. Events that run out of attempts go to the
+// DLQ.
+//
+// Retries are applied without their Kafka position, so they never move
+// stored exactly-once offsets. Later events for a row with a pending retry
+// are held in the queue behind it (see Hold), so a retry never overwrites a
+// newer change. Rows are told apart by the Kafka message key; events without
+// one are not held.
+type RetryQueue struct {
+	mu      sync.Mutex
+	entries []*RetryEntry
+	keys    map[string]int // row key -> pending entries for it
+	path    string
+	file    *os.File // append-only log of the queue, rewritten by compact
+	size    int64
+	policy  RetryPolicy
+	builder EventBuilder
+	writer  writer.Writer
+	dlq     *DLQ
+	now     func() time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRetryQueue creates a retry queue persisted at path, loading the entries
+// left by a previous run
+func NewRetryQueue(path string, policy RetryPolicy, builder EventBuilder, w writer.Writer, dlq *DLQ) (*RetryQueue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	entries, err := readJSONL[RetryEntry](path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	q := &RetryQueue{
+		path:    path,
+		policy:  policy,
+		builder: builder,
+		writer:  w,
+		dlq:     dlq,
+		now:     time.Now,
+		keys:    make(map[string]int),
+	}
+	for i := range entries {
+		q.entries = append(q.entries, &entries[i])
+		if key, ok := retryKey(entries[i].Event); ok {
+			q.keys[key]++
+		}
+	}
+	if err := q.openLog(); err != nil {
+		return nil, err
+	}
+	metrics.RetryQueueEvents.Set(float64(len(q.entries)))
+	if len(q.entries) > 0 {
+		logger.Log.Info("Loaded pending retries", zap.String("path", path), zap.Int("events", len(q.entries)))
+	}
+	return q, nil
+}
+
+// Add schedules the first retry of an event that failed to apply. If the
+// entry cannot be persisted the event is dead-lettered instead; an error is
+// returned when that fails too, and the event must not be acknowledged.
+func (q *RetryQueue) Add(event *models.CDCEvent, reason string, err error) error {
+	entry := &RetryEntry{
+		DLQEntry:    newDLQEntry(event, reason, err),
+		NextAttempt: q.now().Add(q.policy.Delay(1)),
+	}
+
+	q.mu.Lock()
+	saveErr := q.push(entry)
+	q.mu.Unlock()
+
+	if saveErr != nil {
+		logger.Log.Error("Failed to persist retry, sending to DLQ", zap.Error(saveErr))
+		return q.dlq.sendEntry(entry.DLQEntry)
+	}
+
+	logger.Log.Warn("Event scheduled for retry",
+		zap.String("table", event.SourceTable),
+		zap.String("op", event.Operation),
+		zap.Time("next_attempt", entry.NextAttempt),
+		zap.Error(err))
+	return nil
+}
+
+// Hold queues an event behind the pending retry of the same row and reports
+// true, or reports false when the row has none and the event can be applied
+// now. A held event is tried as soon as the entries before it are done.
+// Errors are as for Add.
+func (q *RetryQueue) Hold(event *models.CDCEvent) (bool, error) {
+	key, ok := retryKey(event)
+	if !ok {
+		return false, nil
+	}
+
+	q.mu.Lock()
+	if q.keys[key] == 0 {
+		q.mu.Unlock()
+		return false, nil
+	}
+	entry := &RetryEntry{
+		DLQEntry:    newDLQEntry(event, ReasonRetryPending, errRetryPending),
+		NextAttempt: q.now(),
+	}
+	saveErr := q.push(entry)
+	q.mu.Unlock()
+
+	if saveErr != nil {
+		logger.Log.Error("Failed to persist held event, sending to DLQ", zap.Error(saveErr))
+		return true, q.dlq.sendEntry(entry.DLQEntry)
+	}
+
+	logger.Log.Debug("Event held behind a pending retry",
+		zap.String("table", event.SourceTable),
+		zap.String("op", event.Operation),
+		zap.Int64("offset", event.Offset))
+	return true, nil
+}
+
+// push appends an entry to the queue and its file. Nothing is queued if the
+// write fails. Called with mu held.
+func (q *RetryQueue) push(entry *RetryEntry) error {
+	if err := q.appendLog(entry); err != nil {
+		return err
+	}
+	q.entries = append(q.entries, entry)
+	if key, ok := retryKey(entry.Event); ok {
+		q.keys[key]++
+	}
+	metrics.RetryQueueEvents.Set(float64(len(q.entries)))
+	return nil
+}
+
+// appendLog writes one entry to the end of the queue file, reopening it if
+// the last compaction could not. A partial write is cut off again so the
+// file stays readable. Called with mu held.
+func (q *RetryQueue) appendLog(entry *RetryEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if q.file == nil {
+		if err := q.openLog(); err != nil {
+			return err
+		}
+	}
+	if n, err := q.file.Write(line); err != nil {
+		if n > 0 {
+			_ = q.file.Truncate(q.size)
+		}
+		return err
+	}
+	q.size += int64(len(line))
+	return nil
+}
+
+// openLog opens the queue file for appending. Called with mu held, or
+// before the queue is shared.
+func (q *RetryQueue) openLog() error {
+	file, err := os.OpenFile(q.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // #nosec G304 - path is from config
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	q.file = file
+	q.size = info.Size()
+	return nil
+}
+
+// retryKey identifies the row of a queued event. ok is false for events
+// without a message key, which cannot be matched to a row.
+func retryKey(event *models.CDCEvent) (string, bool) {
+	if event == nil || len(event.Key) == 0 {
+		return "", false
+	}
+	return rowKey(event), true
+}
+
+// Len returns the number of events waiting for a retry
+func (q *RetryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Start retries due entries in the background until Stop is called or ctx
+// is cancelled
+func (q *RetryQueue) Start(ctx context.Context) {
+	ctx, q.cancel = context.WithCancel(ctx)
+	q.done = make(chan struct{})
+
+	go func() {
+		defer close(q.done)
+		ticker := time.NewTicker(retryPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				q.retryDue(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops retrying, waits for the current pass to finish and closes the
+// queue file. Pending entries stay in the file for the next run.
+func (q *RetryQueue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+		<-q.done
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file != nil {
+		_ = q.file.Close()
+		q.file = nil
+	}
+}
+
+// retryDue retries every entry whose time has come, in the order they were
+// added. An entry waits while an earlier entry for the same row is not due
+// or fails again, so changes to a row are applied in order. The lock is not
+// held while executing, so workers can keep adding. Entries not reached
+// before ctx is cancelled are left as they were.
+func (q *RetryQueue) retryDue(ctx context.Context) {
+	now := q.now()
+
+	// Rows whose earliest pending entry is still waiting
+	blocked := make(map[string]bool)
+
+	q.mu.Lock()
+	var due []*RetryEntry
+	for _, entry := range q.entries {
+		key, keyed := retryKey(entry.Event)
+		if keyed && blocked[key] {
+			continue
+		}
+		if !entry.NextAttempt.After(now) {
+			due = append(due, entry)
+		} else if keyed {
+			blocked[key] = true
+		}
+	}
+	q.mu.Unlock()
+
+	if len(due) == 0 {
+		return
+	}
+
+	// Outcomes are worked out on copies and applied under the lock, since
+	// compact may be encoding the entries concurrently
+	finished := make(map[*RetryEntry]bool, len(due))
+	rescheduled := make(map[*RetryEntry]RetryEntry, len(due))
+	for _, entry := range due {
+		key, keyed := retryKey(entry.Event)
+		if keyed && blocked[key] {
+			continue
+		}
+		next := *entry
+		table := next.Event.SourceTable
+		err := replayEntry(ctx, next.DLQEntry, q.builder, q.writer)
+		if ctx.Err() != nil {
+			// Interrupted, so this attempt does not count
+			break
+		}
+		next.Retries++
+
+		switch {
+		case err == nil:
+			metrics.RetryAttempts.WithLabelValues(table, "applied").Inc()
+			logger.Log.Info("Retried event applied",
+				zap.String("table", table),
+				zap.String("op", next.Event.Operation),
+				zap.Int("attempt", next.Retries))
+			finished[entry] = true
+		case next.Retries >= q.policy.MaxAttempts:
+			next.Error = err.Error()
+			next.Timestamp = q.now()
+			if dlqErr := q.dlq.sendEntry(next.DLQEntry); dlqErr != nil {
+				// Kept in the queue and dead-lettered again on its next attempt
+				next.NextAttempt = q.now().Add(q.policy.Delay(next.Retries))
+				rescheduled[entry] = next
+				if keyed {
+					blocked[key] = true
+				}
+				continue
+			}
+			metrics.RetryAttempts.WithLabelValues(table, "dead_lettered").Inc()
+			finished[entry] = true
+		default:
+			metrics.RetryAttempts.WithLabelValues(table, "failed").Inc()
+			next.Error = err.Error()
+			next.NextAttempt = q.now().Add(q.policy.Delay(next.Retries + 1))
+			logger.Log.Warn("Retry failed, rescheduled",
+				zap.String("table", table),
+				zap.String("op", next.Event.Operation),
+				zap.Int("attempt", next.Retries),
+				zap.Time("next_attempt", next.NextAttempt),
+				zap.Error(err))
+			rescheduled[entry] = next
+			if keyed {
+				blocked[key] = true
+			}
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	remaining := q.entries[:0]
+	for _, entry := range q.entries {
+		if finished[entry] {
+			if key, ok := retryKey(entry.Event); ok {
+				if q.keys[key]--; q.keys[key] <= 0 {
+					delete(q.keys, key)
+				}
+			}
+			continue
+		}
+		if next, ok := rescheduled[entry]; ok {
+			*entry = next
+		}
+		remaining = append(remaining, entry)
+	}
+	clear(q.entries[len(remaining):])
+	q.entries = remaining
+	metrics.RetryQueueEvents.Set(float64(len(q.entries)))
+
+	if err := q.compact(); err != nil {
+		// Finished entries may be retried again after a restart
+		logger.Log.Error("Failed to persist retry queue", zap.String("path", q.path), zap.Error(err))
+	}
+}
+
+// compact rewrites the queue file with just the pending entries, replacing
+// the log of every entry added since the last compaction. Called with mu
+// held.
+func (q *RetryQueue) compact() error {
+	entries := make([]RetryEntry, len(q.entries))
+	for i, entry := range q.entries {
+		entries[i] = *entry
+	}
+	if err := writeJSONL(q.path, entries); err != nil {
+		// The old file is still complete, so appends carry on there
+		return err
+	}
+
+	// The file was replaced, so the handle points at the old one
+	if q.file != nil {
+		_ = q.file.Close()
+		q.file = nil
+	}
+	return q.openLog()
+}
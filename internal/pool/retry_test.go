@@ -0,0 +1,276 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/models"
+	"github.com/sparkiss/pos-cdc/internal/writer"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		if got := policy.Delay(i + 1); got != w {
+			t.Errorf("Delay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func retryEvent(id int, status string) *models.CDCEvent {
+	return &models.CDCEvent{
+		Operation:   "u",
+		SourceTable: "orders",
+		Topic:       "pos.orders",
+		Offset:      int64(id),
+		Payload:     map[string]any{"id": float64(id), "status": status},
+	}
+}
+
+func newTestRetryQueue(t *testing.T, path string, w writer.Writer, dlq *DLQ, now *time.Time) *RetryQueue {
+	t.Helper()
+	q, err := NewRetryQueue(path, RetryPolicy{MaxAttempts: 2, BaseDelay: time.Minute, MaxDelay: 10 * time.Minute},
+		fakeBuilder{}, w, dlq)
+	if err != nil {
+		t.Fatalf("NewRetryQueue() error = %v", err)
+	}
+	q.now = func() time.Time { return *now }
+	return q
+}
+
+func TestRetryQueue_RetriesThenDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry", "retry.jsonl")
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	fw := &fakeWriter{}
+	dlq := NewDLQWithSink(nil)
+
+	q := newTestRetryQueue(t, path, fw, dlq, &now)
+	q.Add(retryEvent(1, "paid"), ReasonExecutionError, errors.New("connection reset"))
+	q.Add(retryEvent(2, "bad"), ReasonExecutionError, errors.New("foreign key violation"))
+
+	// Nothing is due before the first delay
+	q.retryDue(context.Background())
+	if fw.calls != 0 || q.Len() != 2 {
+		t.Fatalf("retried before due: calls = %d, pending = %d", fw.calls, q.Len())
+	}
+
+	// A restart keeps the pending entries
+	q = newTestRetryQueue(t, path, fw, dlq, &now)
+	if q.Len() != 2 {
+		t.Fatalf("reloaded %d entries, want 2", q.Len())
+	}
+
+	// First retry: one applies, the other is rescheduled with a longer delay
+	now = now.Add(time.Minute)
+	q.retryDue(context.Background())
+	if fw.calls != 2 || q.Len() != 1 {
+		t.Fatalf("after first retry: calls = %d, pending = %d, want 2, 1", fw.calls, q.Len())
+	}
+	entry := q.entries[0]
+	if entry.Retries != 1 || entry.Error != "constraint violation" {
+		t.Errorf("entry retries = %d, error = %q, want 1, constraint violation", entry.Retries, entry.Error)
+	}
+	if want := now.Add(2 * time.Minute); !entry.NextAttempt.Equal(want) {
+		t.Errorf("NextAttempt = %v, want %v", entry.NextAttempt, want)
+	}
+
+	// Second and last retry fails: dead-lettered and removed from the file
+	now = now.Add(2 * time.Minute)
+	q.retryDue(context.Background())
+	if q.Len() != 0 || dlq.Count() != 1 {
+		t.Errorf("after last retry: pending = %d, DLQ = %d, want 0, 1", q.Len(), dlq.Count())
+	}
+	entries, err := readJSONL[RetryEntry](path)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("retry file = %d entries, %v, want empty", len(entries), err)
+	}
+}
+
+func TestRetryQueue_AppendsAndCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.jsonl")
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	q := newTestRetryQueue(t, path, &fakeWriter{}, NewDLQWithSink(nil), &now)
+	defer q.Stop()
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	for id := range 3 {
+		if err := q.Add(retryEvent(id, "paid"), ReasonExecutionError, errors.New("connection reset")); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	// Adding appends to the same file instead of rewriting it
+	after, err := os.Stat(path)
+	if err != nil || !os.SameFile(before, after) {
+		t.Errorf("retry file was replaced by Add (%v)", err)
+	}
+	if entries, err := readJSONL[RetryEntry](path); err != nil || len(entries) != 3 {
+		t.Fatalf("retry file = %d entries, %v, want 3", len(entries), err)
+	}
+
+	// A retry pass compacts the file to the pending entries, and later
+	// entries are appended to the compacted file
+	now = now.Add(time.Minute)
+	q.retryDue(context.Background())
+	if err := q.Add(retryEvent(4, "paid"), ReasonExecutionError, errors.New("connection reset")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	entries, err := readJSONL[RetryEntry](path)
+	if err != nil || len(entries) != 1 || entries[0].Offset != 4 {
+		t.Errorf("retry file = %+v, %v, want only offset 4", entries, err)
+	}
+}
+
+func TestRetryQueue_RetryDue_Cancelled(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	fw := &fakeWriter{}
+	dlq := NewDLQWithSink(nil)
+	q := newTestRetryQueue(t, filepath.Join(t.TempDir(), "retry.jsonl"), fw, dlq, &now)
+	q.Add(retryEvent(1, "paid"), ReasonExecutionError, errors.New("connection reset"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	now = now.Add(time.Minute)
+	q.retryDue(ctx)
+
+	// An interrupted attempt is not counted against the entry
+	if q.Len() != 1 || dlq.Count() != 0 || q.entries[0].Retries != 0 {
+		t.Errorf("pending = %d, DLQ = %d, retries = %d, want 1, 0, 0", q.Len(), dlq.Count(), q.entries[0].Retries)
+	}
+}
+
+// statusWriter records the status each applied query sets, in order
+type statusWriter struct {
+	fakeWriter
+	fail    bool
+	applied []any
+}
+
+func (s *statusWriter) ExecuteBatch(ctx context.Context, queries []writer.Query) error {
+	s.calls++
+	if s.fail {
+		return errors.New("connection reset")
+	}
+	for _, q := range queries {
+		s.applied = append(s.applied, q.Args...)
+	}
+	return nil
+}
+
+// statusBuilder builds a query whose only argument is the row's status
+type statusBuilder struct{}
+
+func (statusBuilder) BuildSQL(event *models.CDCEvent) (string, []any, error) {
+	return "OK", []any{event.Payload["status"]}, nil
+}
+
+func TestRetryQueue_HoldsLaterChangesToRow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sw := &statusWriter{fail: true}
+	dlq := NewDLQWithSink(nil)
+	q := newTestRetryQueue(t, filepath.Join(t.TempDir(), "retry.jsonl"), sw, dlq, &now)
+	q.builder = statusBuilder{}
+
+	keyed := func(id int, status string) *models.CDCEvent {
+		event := retryEvent(id, status)
+		event.Key = map[string]any{"id": float64(7)}
+		return event
+	}
+
+	hold := func(event *models.CDCEvent) bool {
+		held, err := q.Hold(event)
+		if err != nil {
+			t.Fatalf("Hold() error = %v", err)
+		}
+		return held
+	}
+
+	q.Add(keyed(1, "paid"), ReasonExecutionError, errors.New("connection reset"))
+	if !hold(keyed(2, "shipped")) {
+		t.Fatal("Hold() = false for a row with a pending retry")
+	}
+	if hold(retryEvent(3, "paid")) {
+		t.Error("Hold() = true for an event without a key")
+	}
+	other := retryEvent(4, "paid")
+	other.Key = map[string]any{"id": float64(8)}
+	if hold(other) {
+		t.Error("Hold() = true for a row without a pending retry")
+	}
+
+	// The held change waits while the earlier one fails
+	now = now.Add(time.Minute)
+	q.retryDue(context.Background())
+	if sw.calls != 1 || q.Len() != 2 {
+		t.Fatalf("calls = %d, pending = %d, want only the first entry tried", sw.calls, q.Len())
+	}
+
+	// Once the earlier change applies, the held one follows it
+	sw.fail = false
+	now = now.Add(2 * time.Minute)
+	q.retryDue(context.Background())
+	if want := []any{"paid", "shipped"}; !slices.Equal(sw.applied, want) {
+		t.Errorf("applied = %v, want %v", sw.applied, want)
+	}
+	if q.Len() != 0 || hold(keyed(5, "delivered")) {
+		t.Errorf("pending = %d, row still held after its retries applied", q.Len())
+	}
+}
+
+func TestWorker_Isolate_HoldsRowWithPendingRetry(t *testing.T) {
+	now := time.Now()
+	fw := &fakeWriter{}
+	dlq := NewDLQWithSink(nil)
+	q := newTestRetryQueue(t, filepath.Join(t.TempDir(), "retry.jsonl"), fw, dlq, &now)
+	w := &Worker{writer: fw, dlq: dlq, retry: q, applied: newAppliedTracker(), halt: newHaltSignal()}
+
+	// Offsets 2 and 5 change the same row; 2 is poison
+	queries, events := isolationBatch(2)
+	events[2].Key = map[string]any{"id": float64(2)}
+	events[5].Key = map[string]any{"id": float64(2)}
+	w.isolate(context.Background(), queries, events, errors.New("constraint violation"))
+
+	if slices.Contains(fw.committed, 5) {
+		t.Errorf("committed = %v, later change applied ahead of the pending retry", fw.committed)
+	}
+	if q.Len() != 2 || q.entries[0].Offset != 2 || q.entries[1].Offset != 5 {
+		t.Errorf("retry queue = %d entries, want offsets 2 then 5", q.Len())
+	}
+}
+
+func TestWorker_Isolate_UsesRetryQueue(t *testing.T) {
+	now := time.Now()
+	fw := &fakeWriter{}
+	dlq := NewDLQWithSink(nil)
+	q := newTestRetryQueue(t, filepath.Join(t.TempDir(), "retry.jsonl"), fw, dlq, &now)
+	w := &Worker{writer: fw, dlq: dlq, retry: q, applied: newAppliedTracker(), halt: newHaltSignal()}
+
+	queries, events := isolationBatch(3)
+	w.isolate(context.Background(), queries, events, errors.New("constraint violation"))
+
+	if q.Len() != 1 || dlq.Count() != 0 {
+		t.Errorf("pending = %d, DLQ = %d, want the poison event queued for retry", q.Len(), dlq.Count())
+	}
+	if q.entries[0].Offset != 3 {
+		t.Errorf("queued offset = %d, want 3", q.entries[0].Offset)
+	}
+}
+
+func TestRetryQueue_StartStop(t *testing.T) {
+	now := time.Now()
+	q := newTestRetryQueue(t, filepath.Join(t.TempDir(), "retry.jsonl"), &fakeWriter{}, NewDLQWithSink(nil), &now)
+
+	// Stop before Start is a no-op; Stop after Start returns promptly
+	q.Stop()
+	q.Start(t.Context())
+	q.Stop()
+}
@@ -1,9 +1,19 @@
 package processor
 
 import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
 	"github.com/sparkiss/pos-cdc/internal/schema"
 )
 
+// MaxPlaceholders is the most bind parameters one statement may use. MySQL
+// prepared statements and the PostgreSQL wire protocol both cap it at 65535.
+const MaxPlaceholders = 65535
+
 // SQLBuilder generates SQL statements for a specific database dialect.
 // Implementations handle differences in quoting, placeholders, and upsert syntax.
 type SQLBuilder interface {
@@ -12,9 +22,92 @@ type SQLBuilder interface {
 	// For PostgreSQL: INSERT ... ON CONFLICT ... DO UPDATE
 	BuildInsert(table string, payload map[string]any, tableSchema *schema.TableSchema) (string, []any, error)
 
+	// BuildInsertRows creates one multi-row INSERT with the same upsert
+	// behavior as BuildInsert. Every row must have the same columns, the
+	// rows must not repeat a primary key, and the statement must fit in
+	// MaxPlaceholders (see InsertRowLimit).
+	BuildInsertRows(table string, rows []map[string]any, tableSchema *schema.TableSchema) (string, []any, error)
+
 	// BuildUpdate creates an UPDATE statement.
 	BuildUpdate(table string, payload map[string]any, tableSchema *schema.TableSchema) (string, []any, error)
 
 	// BuildDelete creates a soft-delete UPDATE statement (sets deleted_at).
 	BuildDelete(table string, payload map[string]any, tableSchema *schema.TableSchema) (string, []any, error)
 }
+
+// InsertRowLimit returns how many rows with the given number of columns fit
+// in one multi-row insert. Each row also binds deleted_at.
+func InsertRowLimit(columns int) int {
+	return MaxPlaceholders / (columns + 1)
+}
+
+// orderedColumns returns the payload's columns in the table's column order,
+// skipping __ metadata fields, so events with the same columns always build
+// the same SQL text. Columns missing from the schema follow in name order.
+func orderedColumns(payload map[string]any, tableSchema *schema.TableSchema) []string {
+	columns := make([]string, 0, len(payload))
+	for col := range payload {
+		if !strings.HasPrefix(col, "__") {
+			columns = append(columns, col)
+		}
+	}
+
+	slices.SortFunc(columns, func(a, b string) int {
+		oa, ob := columnOrdinal(tableSchema, a), columnOrdinal(tableSchema, b)
+		switch {
+		case oa == ob:
+			return strings.Compare(a, b)
+		case oa == 0:
+			return 1
+		case ob == 0:
+			return -1
+		}
+		return cmp.Compare(oa, ob)
+	})
+	return columns
+}
+
+// columnOrdinal returns the column's position in the table, falling back to
+// the lowercase name, or 0 if unknown
+func columnOrdinal(tableSchema *schema.TableSchema, col string) int {
+	if info, ok := tableSchema.Columns[col]; ok {
+		return info.Ordinal
+	}
+	if info, ok := tableSchema.Columns[strings.ToLower(col)]; ok {
+		return info.Ordinal
+	}
+	return 0
+}
+
+// insertRowColumns returns the columns shared by every row in table order
+// (see orderedColumns). It fails if the rows differ or would not fit in one
+// statement.
+func insertRowColumns(rows []map[string]any, tableSchema *schema.TableSchema) ([]string, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no rows to insert", ErrNoColumns)
+	}
+
+	columns := orderedColumns(rows[0], tableSchema)
+
+	for i, row := range rows[1:] {
+		for col := range maps.Keys(row) {
+			if strings.HasPrefix(col, "__") {
+				continue
+			}
+			if !slices.Contains(columns, col) {
+				return nil, fmt.Errorf("row %d has column %s missing from the first row", i+1, col)
+			}
+		}
+		for _, col := range columns {
+			if _, ok := row[col]; !ok {
+				return nil, fmt.Errorf("row %d is missing column %s", i+1, col)
+			}
+		}
+	}
+
+	if limit := InsertRowLimit(len(columns)); len(rows) > limit {
+		return nil, fmt.Errorf("%d rows of %d columns exceed the %d placeholder limit (at most %d rows)",
+			len(rows), len(columns), MaxPlaceholders, limit)
+	}
+	return columns, nil
+}
@@ -11,6 +11,12 @@ import (
 // MySQLBuilder generates MySQL-specific SQL statements.
 type MySQLBuilder struct{}
 
+// mysqlIdent quotes an identifier with backticks, doubling any backtick in
+// the name, so reserved words and unusual names are safe.
+func mysqlIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
 // NewMySQLBuilder creates a new MySQL SQL builder.
 func NewMySQLBuilder() *MySQLBuilder {
 	return &MySQLBuilder{}
@@ -23,18 +29,17 @@ func (b *MySQLBuilder) BuildInsert(table string, payload map[string]any, tableSc
 	var values []any
 	var updateClauses []string
 
-	for colName, value := range payload {
-		if strings.HasPrefix(colName, "__") {
-			continue
-		}
+	for _, colName := range orderedColumns(payload, tableSchema) {
+		value := payload[colName]
 
-		columns = append(columns, fmt.Sprintf("`%s`", colName))
+		col := mysqlIdent(colName)
+		columns = append(columns, col)
 		placeholders = append(placeholders, "?")
 		values = append(values, value)
 
 		// Skip primary keys in ON DUPLICATE KEY UPDATE
 		if colInfo, ok := tableSchema.Columns[colName]; ok && !colInfo.IsPrimary {
-			updateClauses = append(updateClauses, fmt.Sprintf("`%s` = VALUES(`%s`)", colName, colName))
+			updateClauses = append(updateClauses, fmt.Sprintf("%s = VALUES(%s)", col, col))
 		}
 	}
 
@@ -45,8 +50,8 @@ func (b *MySQLBuilder) BuildInsert(table string, payload map[string]any, tableSc
 	updateClauses = append(updateClauses, "`deleted_at` = NULL")
 
 	sql := fmt.Sprintf(
-		"INSERT INTO `%s` (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
-		table,
+		"INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+		mysqlIdent(table),
 		strings.Join(columns, ", "),
 		strings.Join(placeholders, ", "),
 		strings.Join(updateClauses, ", "),
@@ -55,50 +60,97 @@ func (b *MySQLBuilder) BuildInsert(table string, payload map[string]any, tableSc
 	return sql, values, nil
 }
 
+// BuildInsertRows creates a multi-row INSERT ... ON DUPLICATE KEY UPDATE statement.
+func (b *MySQLBuilder) BuildInsertRows(table string, rows []map[string]any, tableSchema *schema.TableSchema) (string, []any, error) {
+	columns, err := insertRowColumns(rows, tableSchema)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var quoted []string
+	var updateClauses []string
+	for _, colName := range columns {
+		col := mysqlIdent(colName)
+		quoted = append(quoted, col)
+
+		// Skip primary keys in ON DUPLICATE KEY UPDATE
+		if colInfo, ok := tableSchema.Columns[colName]; ok && !colInfo.IsPrimary {
+			updateClauses = append(updateClauses, fmt.Sprintf("%s = VALUES(%s)", col, col))
+		}
+	}
+
+	// Add deleted_at = NULL for upsert (un-delete if re-inserted)
+	quoted = append(quoted, "`deleted_at`")
+	updateClauses = append(updateClauses, "`deleted_at` = NULL")
+
+	rowPlaceholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(quoted)), ", ") + ")"
+	tuples := make([]string, 0, len(rows))
+	values := make([]any, 0, len(rows)*len(quoted))
+	for _, row := range rows {
+		tuples = append(tuples, rowPlaceholders)
+		for _, colName := range columns {
+			values = append(values, row[colName])
+		}
+		values = append(values, nil)
+	}
+
+	sql := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES %s ON DUPLICATE KEY UPDATE %s",
+		mysqlIdent(table),
+		strings.Join(quoted, ", "),
+		strings.Join(tuples, ", "),
+		strings.Join(updateClauses, ", "),
+	)
+
+	return sql, values, nil
+}
+
 // BuildUpdate creates an UPDATE statement with MySQL syntax.
 func (b *MySQLBuilder) BuildUpdate(table string, payload map[string]any, tableSchema *schema.TableSchema) (string, []any, error) {
 	var setClauses []string
 	var values []any
 	var pkValues []any
 
-	for colName, value := range payload {
-		if strings.HasPrefix(colName, "__") {
-			continue
-		}
+	for _, colName := range orderedColumns(payload, tableSchema) {
+		value := payload[colName]
 
-		colInfo, exists := tableSchema.Columns[colName]
-		if exists && colInfo.IsPrimary {
-			pkValues = append(pkValues, value)
+		if colInfo, exists := tableSchema.Columns[colName]; exists && colInfo.IsPrimary {
 			continue
 		}
 
-		setClauses = append(setClauses, fmt.Sprintf("`%s` = ?", colName))
+		setClauses = append(setClauses, mysqlIdent(colName)+" = ?")
 		values = append(values, value)
 	}
 
 	if len(tableSchema.PrimaryKeys) == 0 {
-		return "", nil, fmt.Errorf("no primary key for table %s", table)
+		return "", nil, fmt.Errorf("%w for table %s", ErrNoPrimaryKey, table)
 	}
 
+	// In WHERE clause order
+	for _, pk := range tableSchema.PrimaryKeys {
+		if value, ok := payload[pk]; ok {
+			pkValues = append(pkValues, value)
+		}
+	}
 	if len(pkValues) != len(tableSchema.PrimaryKeys) {
-		return "", nil, fmt.Errorf("missing primary key values in payload")
+		return "", nil, fmt.Errorf("%w in payload", ErrMissingPrimaryKey)
 	}
 
 	// If no non-PK columns to update, skip the update
 	// This can happen if CDC sends an update event with only PK columns
 	if len(setClauses) == 0 {
-		return "", nil, fmt.Errorf("no columns to update for table %s (only primary key columns in payload)", table)
+		return "", nil, fmt.Errorf("%w for table %s (only primary key columns in payload)", ErrNoColumns, table)
 	}
 
 	var whereClauses []string
 	for _, pk := range tableSchema.PrimaryKeys {
-		whereClauses = append(whereClauses, fmt.Sprintf("`%s` = ?", pk))
+		whereClauses = append(whereClauses, mysqlIdent(pk)+" = ?")
 	}
 	values = append(values, pkValues...)
 
 	sql := fmt.Sprintf(
-		"UPDATE `%s` SET %s WHERE %s",
-		table,
+		"UPDATE %s SET %s WHERE %s",
+		mysqlIdent(table),
 		strings.Join(setClauses, ", "),
 		strings.Join(whereClauses, " AND "),
 	)
@@ -109,7 +161,7 @@ func (b *MySQLBuilder) BuildUpdate(table string, payload map[string]any, tableSc
 // BuildDelete creates a soft-delete UPDATE statement.
 func (b *MySQLBuilder) BuildDelete(table string, payload map[string]any, tableSchema *schema.TableSchema) (string, []any, error) {
 	if len(tableSchema.PrimaryKeys) == 0 {
-		return "", nil, fmt.Errorf("no primary key for table %s", table)
+		return "", nil, fmt.Errorf("%w for table %s", ErrNoPrimaryKey, table)
 	}
 
 	var pkValues []any
@@ -120,17 +172,17 @@ func (b *MySQLBuilder) BuildDelete(table string, payload map[string]any, tableSc
 	}
 
 	if len(pkValues) != len(tableSchema.PrimaryKeys) {
-		return "", nil, fmt.Errorf("missing primary key values for soft delete")
+		return "", nil, fmt.Errorf("%w for soft delete", ErrMissingPrimaryKey)
 	}
 
 	var whereClauses []string
 	for _, pk := range tableSchema.PrimaryKeys {
-		whereClauses = append(whereClauses, fmt.Sprintf("`%s` = ?", pk))
+		whereClauses = append(whereClauses, mysqlIdent(pk)+" = ?")
 	}
 
 	sql := fmt.Sprintf(
-		"UPDATE `%s` SET `deleted_at` = ? WHERE %s",
-		table,
+		"UPDATE %s SET `deleted_at` = ? WHERE %s",
+		mysqlIdent(table),
 		strings.Join(whereClauses, " AND "),
 	)
 
@@ -6,19 +6,26 @@ import (
 	"time"
 
 	"github.com/sparkiss/pos-cdc/internal/schema"
+	"github.com/sparkiss/pos-cdc/internal/writer"
 )
 
 // PostgresBuilder generates PostgreSQL-specific SQL statements.
-// Uses lowercase, unquoted identifiers for case-insensitive queries.
+// Uses lowercase, quoted identifiers.
 type PostgresBuilder struct{}
 
-// pgIdent converts an identifier to lowercase for PostgreSQL.
-// PostgreSQL folds unquoted identifiers to lowercase, so using lowercase
-// identifiers allows case-insensitive queries like: SELECT ID FROM ORDERS
-func pgIdent(name string) string {
+// pgName converts an identifier to lowercase for PostgreSQL.
+// PostgreSQL folds unquoted identifiers to lowercase, so target tables
+// created from the source DDL have lowercase names.
+func pgName(name string) string {
 	return strings.ToLower(name)
 }
 
+// pgIdent quotes the lowercase name, doubling any double quote in it, so
+// reserved words such as order and user and unusual names are safe.
+func pgIdent(name string) string {
+	return `"` + strings.ReplaceAll(pgName(name), `"`, `""`) + `"`
+}
+
 // NewPostgresBuilder creates a new PostgreSQL SQL builder.
 func NewPostgresBuilder() *PostgresBuilder {
 	return &PostgresBuilder{}
@@ -32,10 +39,8 @@ func (b *PostgresBuilder) BuildInsert(table string, payload map[string]any, tabl
 	var updateClauses []string
 	paramIdx := 1
 
-	for colName, value := range payload {
-		if strings.HasPrefix(colName, "__") {
-			continue
-		}
+	for _, colName := range orderedColumns(payload, tableSchema) {
+		value := payload[colName]
 
 		col := pgIdent(colName)
 		columns = append(columns, col)
@@ -62,7 +67,7 @@ func (b *PostgresBuilder) BuildInsert(table string, payload map[string]any, tabl
 	}
 
 	if len(pkColumns) == 0 {
-		return "", nil, fmt.Errorf("no primary key for table %s", table)
+		return "", nil, fmt.Errorf("%w for table %s", ErrNoPrimaryKey, table)
 	}
 
 	sql := fmt.Sprintf(
@@ -77,6 +82,97 @@ func (b *PostgresBuilder) BuildInsert(table string, payload map[string]any, tabl
 	return sql, values, nil
 }
 
+// BuildInsertRows creates a multi-row INSERT ... ON CONFLICT ... DO UPDATE statement.
+func (b *PostgresBuilder) BuildInsertRows(table string, rows []map[string]any, tableSchema *schema.TableSchema) (string, []any, error) {
+	if len(tableSchema.PrimaryKeys) == 0 {
+		return "", nil, fmt.Errorf("%w for table %s", ErrNoPrimaryKey, table)
+	}
+
+	columns, err := insertRowColumns(rows, tableSchema)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var idents []string
+	var updateClauses []string
+	for _, colName := range columns {
+		col := pgIdent(colName)
+		idents = append(idents, col)
+
+		// Skip primary keys in ON CONFLICT DO UPDATE
+		if colInfo, ok := tableSchema.Columns[colName]; ok && !colInfo.IsPrimary {
+			updateClauses = append(updateClauses, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+		}
+	}
+
+	// Add deleted_at = NULL for upsert (un-delete if re-inserted)
+	idents = append(idents, "deleted_at")
+	updateClauses = append(updateClauses, "deleted_at = NULL")
+
+	var pkColumns []string
+	for _, pk := range tableSchema.PrimaryKeys {
+		pkColumns = append(pkColumns, pgIdent(pk))
+	}
+
+	tuples := make([]string, 0, len(rows))
+	values := make([]any, 0, len(rows)*len(idents))
+	placeholders := make([]string, len(idents))
+	for _, row := range rows {
+		for i := range idents {
+			placeholders[i] = fmt.Sprintf("$%d", len(values)+i+1)
+		}
+		tuples = append(tuples, "("+strings.Join(placeholders, ", ")+")")
+		for _, colName := range columns {
+			values = append(values, row[colName])
+		}
+		values = append(values, nil)
+	}
+
+	sql := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES %s ON CONFLICT (%s) DO UPDATE SET %s",
+		pgIdent(table),
+		strings.Join(idents, ", "),
+		strings.Join(tuples, ", "),
+		strings.Join(pkColumns, ", "),
+		strings.Join(updateClauses, ", "),
+	)
+
+	return sql, values, nil
+}
+
+// BuildCopyRow returns the upsert BuildInsert would create as structured
+// rows for bulk loading, with columns in table order. Names are lowercase
+// but not quoted; deleted_at is left to the writer's merge.
+func (b *PostgresBuilder) BuildCopyRow(table string, payload map[string]any, tableSchema *schema.TableSchema) (*writer.CopyRows, error) {
+	if len(tableSchema.PrimaryKeys) == 0 {
+		return nil, fmt.Errorf("%w for table %s", ErrNoPrimaryKey, table)
+	}
+
+	columns, err := insertRowColumns([]map[string]any{payload}, tableSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	idents := make([]string, 0, len(columns))
+	row := make([]any, 0, len(columns))
+	for _, colName := range columns {
+		idents = append(idents, pgName(colName))
+		row = append(row, payload[colName])
+	}
+
+	var pkColumns []string
+	for _, pk := range tableSchema.PrimaryKeys {
+		pkColumns = append(pkColumns, pgName(pk))
+	}
+
+	return &writer.CopyRows{
+		Table:       pgName(table),
+		Columns:     idents,
+		PrimaryKeys: pkColumns,
+		Rows:        [][]any{row},
+	}, nil
+}
+
 // BuildUpdate creates an UPDATE statement with PostgreSQL syntax.
 func (b *PostgresBuilder) BuildUpdate(table string, payload map[string]any, tableSchema *schema.TableSchema) (string, []any, error) {
 	var setClauses []string
@@ -84,14 +180,10 @@ func (b *PostgresBuilder) BuildUpdate(table string, payload map[string]any, tabl
 	var pkValues []any
 	paramIdx := 1
 
-	for colName, value := range payload {
-		if strings.HasPrefix(colName, "__") {
-			continue
-		}
+	for _, colName := range orderedColumns(payload, tableSchema) {
+		value := payload[colName]
 
-		colInfo, exists := tableSchema.Columns[colName]
-		if exists && colInfo.IsPrimary {
-			pkValues = append(pkValues, value)
+		if colInfo, exists := tableSchema.Columns[colName]; exists && colInfo.IsPrimary {
 			continue
 		}
 
@@ -101,17 +193,23 @@ func (b *PostgresBuilder) BuildUpdate(table string, payload map[string]any, tabl
 	}
 
 	if len(tableSchema.PrimaryKeys) == 0 {
-		return "", nil, fmt.Errorf("no primary key for table %s", table)
+		return "", nil, fmt.Errorf("%w for table %s", ErrNoPrimaryKey, table)
 	}
 
+	// In WHERE clause order
+	for _, pk := range tableSchema.PrimaryKeys {
+		if value, ok := payload[pk]; ok {
+			pkValues = append(pkValues, value)
+		}
+	}
 	if len(pkValues) != len(tableSchema.PrimaryKeys) {
-		return "", nil, fmt.Errorf("missing primary key values in payload")
+		return "", nil, fmt.Errorf("%w in payload", ErrMissingPrimaryKey)
 	}
 
 	// If no non-PK columns to update, skip the update
 	// This can happen if CDC sends an update event with only PK columns
 	if len(setClauses) == 0 {
-		return "", nil, fmt.Errorf("no columns to update for table %s (only primary key columns in payload)", table)
+		return "", nil, fmt.Errorf("%w for table %s (only primary key columns in payload)", ErrNoColumns, table)
 	}
 
 	var whereClauses []string
@@ -134,7 +232,7 @@ func (b *PostgresBuilder) BuildUpdate(table string, payload map[string]any, tabl
 // BuildDelete creates a soft-delete UPDATE statement.
 func (b *PostgresBuilder) BuildDelete(table string, payload map[string]any, tableSchema *schema.TableSchema) (string, []any, error) {
 	if len(tableSchema.PrimaryKeys) == 0 {
-		return "", nil, fmt.Errorf("no primary key for table %s", table)
+		return "", nil, fmt.Errorf("%w for table %s", ErrNoPrimaryKey, table)
 	}
 
 	var pkValues []any
@@ -145,7 +243,7 @@ func (b *PostgresBuilder) BuildDelete(table string, payload map[string]any, tabl
 	}
 
 	if len(pkValues) != len(tableSchema.PrimaryKeys) {
-		return "", nil, fmt.Errorf("missing primary key values for soft delete")
+		return "", nil, fmt.Errorf("%w for soft delete", ErrMissingPrimaryKey)
 	}
 
 	var whereClauses []string
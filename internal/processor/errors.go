@@ -0,0 +1,45 @@
+package processor
+
+import "errors"
+
+// Reason codes for events that cannot be turned into SQL. They label
+// cdc_events_failed_total and DLQ entries, and select the configured action.
+const (
+	ReasonSchemaLookup      = "schema_lookup"
+	ReasonNoPrimaryKey      = "no_primary_key"
+	ReasonMissingPrimaryKey = "missing_primary_key"
+	ReasonNoColumns         = "no_columns"
+	ReasonUnknownColumn     = "unknown_column"
+	ReasonUnknownOperation  = "unknown_operation"
+	ReasonBuildError        = "build_error"
+)
+
+var (
+	ErrSchemaLookup      = errors.New("schema lookup failed")
+	ErrNoPrimaryKey      = errors.New("no primary key")
+	ErrMissingPrimaryKey = errors.New("missing primary key values")
+	ErrNoColumns         = errors.New("no columns to update")
+	ErrUnknownColumn     = errors.New("unknown column")
+	ErrUnknownOperation  = errors.New("unknown operation")
+)
+
+// Reason classifies an error returned by BuildSQL. Errors that match none of
+// the known causes are reported as ReasonBuildError.
+func Reason(err error) string {
+	switch {
+	case errors.Is(err, ErrSchemaLookup):
+		return ReasonSchemaLookup
+	case errors.Is(err, ErrNoPrimaryKey):
+		return ReasonNoPrimaryKey
+	case errors.Is(err, ErrMissingPrimaryKey):
+		return ReasonMissingPrimaryKey
+	case errors.Is(err, ErrNoColumns):
+		return ReasonNoColumns
+	case errors.Is(err, ErrUnknownColumn):
+		return ReasonUnknownColumn
+	case errors.Is(err, ErrUnknownOperation):
+		return ReasonUnknownOperation
+	default:
+		return ReasonBuildError
+	}
+}
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sparkiss/pos-cdc/internal/schema"
+)
+
+func TestReason(t *testing.T) {
+	mysql := NewMySQLBuilder()
+	pg := NewPostgresBuilder()
+	orders := createOrdersSchema()
+	noPK := &schema.TableSchema{Name: "audit", Columns: map[string]*schema.ColumnInfo{}}
+
+	tests := []struct {
+		name  string
+		build func() error
+		want  string
+	}{
+		{"mysql missing pk", func() error {
+			_, _, err := mysql.BuildUpdate("orders", map[string]any{"status": "paid"}, orders)
+			return err
+		}, ReasonMissingPrimaryKey},
+		{"mysql pk-only update", func() error {
+			_, _, err := mysql.BuildUpdate("orders", map[string]any{"id": int64(1)}, orders)
+			return err
+		}, ReasonNoColumns},
+		{"mysql no primary key", func() error {
+			_, _, err := mysql.BuildDelete("audit", map[string]any{"id": int64(1)}, noPK)
+			return err
+		}, ReasonNoPrimaryKey},
+		{"postgres missing pk for delete", func() error {
+			_, _, err := pg.BuildDelete("orders", map[string]any{"status": "paid"}, orders)
+			return err
+		}, ReasonMissingPrimaryKey},
+		{"postgres no primary key", func() error {
+			_, _, err := pg.BuildInsert("audit", map[string]any{"id": int64(1)}, noPK)
+			return err
+		}, ReasonNoPrimaryKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.build()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := Reason(err); got != tt.want {
+				t.Errorf("Reason(%v) = %q, want %q", err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReason_Wrapped(t *testing.T) {
+	if got := Reason(errors.Join(errors.New("context"), ErrSchemaLookup)); got != ReasonSchemaLookup {
+		t.Errorf("Reason() = %q, want %q", got, ReasonSchemaLookup)
+	}
+	if got := Reason(errors.New("something else")); got != ReasonBuildError {
+		t.Errorf("Reason() = %q, want %q", got, ReasonBuildError)
+	}
+}
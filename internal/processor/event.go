@@ -3,12 +3,15 @@ package processor
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/metrics"
 	"github.com/sparkiss/pos-cdc/internal/models"
 	"github.com/sparkiss/pos-cdc/internal/schema"
+	"github.com/sparkiss/pos-cdc/internal/writer"
 	"github.com/sparkiss/pos-cdc/pkg/logger"
 	"go.uber.org/zap"
 )
@@ -19,6 +22,10 @@ type Processor struct {
 	converter  *schema.Converter
 	sqlBuilder SQLBuilder
 	targetType config.TargetType
+
+	// dropUnknown removes payload columns missing from the target table
+	// instead of failing the event with ErrUnknownColumn
+	dropUnknown bool
 }
 
 // New creates a Processor with the specified target type.
@@ -39,14 +46,23 @@ func New(schemaCache *schema.SchemaCache, sourceLocation, targetLocation *time.L
 	}
 }
 
+// DropUnknownColumns makes the processor build SQL without payload columns
+// that the target table does not have, instead of failing the event.
+func (p *Processor) DropUnknownColumns() {
+	p.dropUnknown = true
+}
+
 // BuildSQL converts a CDC event into a SQL query with parameters.
 func (p *Processor) BuildSQL(event *models.CDCEvent) (string, []any, error) {
 	tableSchema, err := p.schema.GetTableSchema(event.SourceTable)
 	if err != nil {
-		return "", nil, fmt.Errorf("schema lookup failed for %s: %w", event.SourceTable, err)
+		return "", nil, fmt.Errorf("%w for %s: %w", ErrSchemaLookup, event.SourceTable, err)
 	}
 
-	convertedPayload := p.convertPayload(event.Payload, tableSchema)
+	convertedPayload := p.convertPayload(event.Payload, event.Fields, tableSchema)
+	if err := p.checkColumns(event.SourceTable, convertedPayload, tableSchema, true); err != nil {
+		return "", nil, err
+	}
 
 	op := event.GetOperation()
 
@@ -63,11 +79,83 @@ func (p *Processor) BuildSQL(event *models.CDCEvent) (string, []any, error) {
 	case models.OperationDelete:
 		return p.sqlBuilder.BuildDelete(event.SourceTable, convertedPayload, tableSchema)
 	default:
-		return "", nil, fmt.Errorf("unknown operation: %s", event.Operation)
+		return "", nil, fmt.Errorf("%w: %s", ErrUnknownOperation, event.Operation)
 	}
 }
 
-func (p *Processor) convertPayload(payload map[string]any, tableSchema *schema.TableSchema) map[string]any {
+// BuildInsertRows converts insert events for one table into a single
+// multi-row upsert. The events must share their columns and must not repeat
+// a primary key; at most InsertRowLimit rows fit in one statement.
+func (p *Processor) BuildInsertRows(events []*models.CDCEvent) (string, []any, error) {
+	if len(events) == 0 {
+		return "", nil, fmt.Errorf("%w: no rows to insert", ErrNoColumns)
+	}
+	table := events[0].SourceTable
+
+	tableSchema, err := p.schema.GetTableSchema(table)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w for %s: %w", ErrSchemaLookup, table, err)
+	}
+
+	rows := make([]map[string]any, 0, len(events))
+	seen := make(map[string]bool, len(events))
+	for _, event := range events {
+		if event.SourceTable != table || event.GetOperation() != models.OperationInsert {
+			return "", nil, fmt.Errorf("multi-row insert needs inserts into %s, got %s on %s",
+				table, event.GetOperation(), event.SourceTable)
+		}
+
+		row := p.convertPayload(event.Payload, event.Fields, tableSchema)
+		if err := p.checkColumns(table, row, tableSchema, false); err != nil {
+			return "", nil, err
+		}
+		pk := make([]string, 0, len(tableSchema.PrimaryKeys))
+		for _, col := range tableSchema.PrimaryKeys {
+			pk = append(pk, fmt.Sprint(row[col]))
+		}
+		key := strings.Join(pk, "\x00")
+		if seen[key] {
+			return "", nil, fmt.Errorf("multi-row insert into %s repeats primary key %v", table, pk)
+		}
+		seen[key] = true
+		rows = append(rows, row)
+	}
+
+	logger.Log.Debug("Building multi-row insert",
+		zap.String("table", table),
+		zap.Int("rows", len(rows)),
+		zap.String("target", string(p.targetType)))
+
+	return p.sqlBuilder.BuildInsertRows(table, rows, tableSchema)
+}
+
+// BuildCopyRow converts a PostgreSQL insert event into a row for bulk
+// loading (see writer.CopyRows).
+func (p *Processor) BuildCopyRow(event *models.CDCEvent) (*writer.CopyRows, error) {
+	builder, ok := p.sqlBuilder.(*PostgresBuilder)
+	if !ok {
+		return nil, fmt.Errorf("bulk loading needs a %s target, got %s", config.TargetPostgres, p.targetType)
+	}
+	if event.GetOperation() != models.OperationInsert {
+		return nil, fmt.Errorf("bulk loading needs an insert, got %s", event.GetOperation())
+	}
+
+	tableSchema, err := p.schema.GetTableSchema(event.SourceTable)
+	if err != nil {
+		return nil, fmt.Errorf("%w for %s: %w", ErrSchemaLookup, event.SourceTable, err)
+	}
+
+	row := p.convertPayload(event.Payload, event.Fields, tableSchema)
+	if err := p.checkColumns(event.SourceTable, row, tableSchema, false); err != nil {
+		return nil, err
+	}
+	return builder.BuildCopyRow(event.SourceTable, row, tableSchema)
+}
+
+// convertPayload converts each column value for the target. When the message
+// carried a Connect schema, the column's logical type takes precedence over
+// the target column's data type.
+func (p *Processor) convertPayload(payload map[string]any, fields map[string]models.FieldSchema, tableSchema *schema.TableSchema) map[string]any {
 	converted := make(map[string]any, len(payload))
 
 	for colName, value := range payload {
@@ -80,7 +168,9 @@ func (p *Processor) convertPayload(payload map[string]any, tableSchema *schema.T
 		// This handles case where CDC sends MySQL column names (potentially mixed case)
 		// but schema has PostgreSQL column names (lowercase)
 		colInfo := p.findColumnInfo(tableSchema, colName)
-		if colInfo != nil {
+		if v, ok := p.converter.ConvertLogical(fields[colName], colInfo, value); ok {
+			converted[colName] = v
+		} else if colInfo != nil {
 			converted[colName] = p.converter.ConvertValue(colInfo, value)
 		} else {
 			converted[colName] = value
@@ -92,6 +182,39 @@ func (p *Processor) convertPayload(payload map[string]any, tableSchema *schema.T
 	return converted
 }
 
+// checkColumns verifies that every payload column, other than __ metadata
+// fields, exists in the target table. Unknown columns are removed from the
+// converted payload when dropping is enabled and fail the event otherwise.
+// They are counted only when count is set: BuildInsertRows and BuildCopyRow
+// see events BuildSQL has already checked.
+func (p *Processor) checkColumns(table string, payload map[string]any, tableSchema *schema.TableSchema, count bool) error {
+	var unknown []string
+	for colName := range payload {
+		if !strings.HasPrefix(colName, "__") && p.findColumnInfo(tableSchema, colName) == nil {
+			unknown = append(unknown, colName)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	slices.Sort(unknown)
+	if count {
+		metrics.UnknownColumns.WithLabelValues(table).Add(float64(len(unknown)))
+	}
+
+	if !p.dropUnknown {
+		return fmt.Errorf("%w in %s: %s", ErrUnknownColumn, table, strings.Join(unknown, ", "))
+	}
+
+	for _, colName := range unknown {
+		delete(payload, colName)
+	}
+	logger.Log.Debug("Dropped unknown columns",
+		zap.String("table", table),
+		zap.Strings("columns", unknown))
+	return nil
+}
+
 // findColumnInfo looks up column info with case-insensitive fallback.
 // PostgreSQL uses lowercase identifiers, but CDC payload may have original case.
 func (p *Processor) findColumnInfo(tableSchema *schema.TableSchema, colName string) *schema.ColumnInfo {
@@ -1,14 +1,21 @@
 package processor
 
 import (
+	"errors"
+	"maps"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
 	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/metrics"
 	"github.com/sparkiss/pos-cdc/internal/models"
 	"github.com/sparkiss/pos-cdc/internal/schema"
+	"github.com/sparkiss/pos-cdc/internal/writer"
 	"github.com/sparkiss/pos-cdc/pkg/logger"
 )
 
@@ -33,11 +40,11 @@ func createOrdersSchema() *schema.TableSchema {
 	return &schema.TableSchema{
 		Name: "orders",
 		Columns: map[string]*schema.ColumnInfo{
-			"id":          {Name: "id", DataType: "bigint", IsPrimary: true},
-			"customer_id": {Name: "customer_id", DataType: "bigint"},
-			"total":       {Name: "total", DataType: "decimal"},
-			"status":      {Name: "status", DataType: "varchar"},
-			"created_at":  {Name: "created_at", DataType: "datetime"},
+			"id":          {Name: "id", DataType: "bigint", IsPrimary: true, Ordinal: 1},
+			"customer_id": {Name: "customer_id", DataType: "bigint", Ordinal: 2},
+			"total":       {Name: "total", DataType: "decimal", Ordinal: 3},
+			"status":      {Name: "status", DataType: "varchar", Ordinal: 4},
+			"created_at":  {Name: "created_at", DataType: "datetime", Ordinal: 5},
 		},
 		PrimaryKeys: []string{"id"},
 	}
@@ -48,9 +55,9 @@ func createUsersSchema() *schema.TableSchema {
 	return &schema.TableSchema{
 		Name: "user_roles",
 		Columns: map[string]*schema.ColumnInfo{
-			"user_id": {Name: "user_id", DataType: "bigint", IsPrimary: true},
-			"role_id": {Name: "role_id", DataType: "bigint", IsPrimary: true},
-			"granted": {Name: "granted", DataType: "datetime"},
+			"user_id": {Name: "user_id", DataType: "bigint", IsPrimary: true, Ordinal: 1},
+			"role_id": {Name: "role_id", DataType: "bigint", IsPrimary: true, Ordinal: 2},
+			"granted": {Name: "granted", DataType: "datetime", Ordinal: 3},
 		},
 		PrimaryKeys: []string{"user_id", "role_id"},
 	}
@@ -337,7 +344,7 @@ func TestProcessor_ConvertPayload(t *testing.T) {
 		"__source_db": "pos",                  // meta field
 	}
 
-	converted := p.convertPayload(payload, tableSchema)
+	converted := p.convertPayload(payload, nil, tableSchema)
 
 	// Meta fields should be passed through unchanged
 	if converted["__op"] != "c" {
@@ -372,7 +379,7 @@ func TestProcessor_ConvertPayload_UnknownColumn(t *testing.T) {
 		"unknown_column": "value",
 	}
 
-	converted := p.convertPayload(payload, tableSchema)
+	converted := p.convertPayload(payload, nil, tableSchema)
 
 	// Unknown columns should be passed through
 	if converted["unknown_column"] != "value" {
@@ -380,6 +387,71 @@ func TestProcessor_ConvertPayload_UnknownColumn(t *testing.T) {
 	}
 }
 
+func TestProcessor_CheckColumns(t *testing.T) {
+	p := newTestProcessor()
+	tableSchema := createOrdersSchema()
+
+	unknown := metrics.UnknownColumns.WithLabelValues("orders")
+	before := testutil.ToFloat64(unknown)
+
+	payload := map[string]any{"id": int64(1), "legacy": "x", "__op": "c"}
+	err := p.checkColumns("orders", payload, tableSchema, true)
+	if !errors.Is(err, ErrUnknownColumn) {
+		t.Fatalf("checkColumns() error = %v, want ErrUnknownColumn", err)
+	}
+	if got := Reason(err); got != ReasonUnknownColumn {
+		t.Errorf("Reason() = %q, want %q", got, ReasonUnknownColumn)
+	}
+
+	// Later checks of the same event only drop
+	p.DropUnknownColumns()
+	if err := p.checkColumns("orders", maps.Clone(payload), tableSchema, false); err != nil {
+		t.Fatalf("checkColumns() with drop error = %v", err)
+	}
+	if got := testutil.ToFloat64(unknown) - before; got != 1 {
+		t.Errorf("unknown columns counted = %v, want 1", got)
+	}
+
+	if err := p.checkColumns("orders", payload, tableSchema, true); err != nil {
+		t.Fatalf("checkColumns() with drop error = %v", err)
+	}
+	if _, ok := payload["legacy"]; ok {
+		t.Error("unknown column should be dropped")
+	}
+	if len(payload) != 2 {
+		t.Errorf("known and metadata columns should be kept, got %v", payload)
+	}
+}
+
+func TestIdentifierQuoting(t *testing.T) {
+	tableSchema := &schema.TableSchema{
+		Name: "order",
+		Columns: map[string]*schema.ColumnInfo{
+			"id":         {Name: "id", DataType: "bigint", IsPrimary: true, Ordinal: 1},
+			"group":      {Name: "group", DataType: "varchar", Ordinal: 2},
+			"odd`na\"me": {Name: "odd`na\"me", DataType: "varchar", Ordinal: 3},
+		},
+		PrimaryKeys: []string{"id"},
+	}
+	payload := map[string]any{"id": int64(1), "group": "a", "odd`na\"me": "b"}
+
+	query, _, err := NewMySQLBuilder().BuildInsert("order", payload, tableSchema)
+	if err != nil {
+		t.Fatalf("MySQL BuildInsert() error = %v", err)
+	}
+	if want := "INSERT INTO `order` (`id`, `group`, `odd``na\"me`, `deleted_at`)"; !strings.HasPrefix(query, want) {
+		t.Errorf("MySQL query = %s, want prefix %s", query, want)
+	}
+
+	query, _, err = NewPostgresBuilder().BuildInsert("order", payload, tableSchema)
+	if err != nil {
+		t.Fatalf("PostgreSQL BuildInsert() error = %v", err)
+	}
+	if want := `INSERT INTO "order" ("id", "group", "odd` + "`" + `na""me", deleted_at)`; !strings.HasPrefix(query, want) {
+		t.Errorf("PostgreSQL query = %s, want prefix %s", query, want)
+	}
+}
+
 func TestCDCEvent_GetOperation_Integration(t *testing.T) {
 	// Test that models.CDCEvent.GetOperation() works correctly with processor
 	tests := []struct {
@@ -419,9 +491,9 @@ func TestPostgresBuilder_BuildInsert(t *testing.T) {
 		t.Fatalf("BuildInsert() error = %v", err)
 	}
 
-	// Check PostgreSQL-specific syntax (lowercase, unquoted identifiers)
-	if !strings.HasPrefix(sql, "INSERT INTO orders") {
-		t.Errorf("SQL should start with INSERT INTO orders (lowercase, unquoted), got: %s", sql)
+	// Check PostgreSQL-specific syntax (lowercase, quoted identifiers)
+	if !strings.HasPrefix(sql, `INSERT INTO "orders"`) {
+		t.Errorf("SQL should start with INSERT INTO \"orders\" (lowercase, quoted), got: %s", sql)
 	}
 	if !strings.Contains(sql, "ON CONFLICT") {
 		t.Error("SQL should contain ON CONFLICT")
@@ -455,12 +527,12 @@ func TestPostgresBuilder_BuildDelete(t *testing.T) {
 		t.Fatalf("BuildDelete() error = %v", err)
 	}
 
-	// Check PostgreSQL-specific syntax (lowercase, unquoted identifiers)
-	if !strings.HasPrefix(sql, "UPDATE orders SET deleted_at = $1") {
-		t.Errorf("SQL should be UPDATE with $1 for soft delete (lowercase, unquoted), got: %s", sql)
+	// Check PostgreSQL-specific syntax (lowercase, quoted identifiers)
+	if !strings.HasPrefix(sql, `UPDATE "orders" SET deleted_at = $1`) {
+		t.Errorf("SQL should be UPDATE with $1 for soft delete (lowercase, quoted), got: %s", sql)
 	}
-	if !strings.Contains(sql, "WHERE id = $2") {
-		t.Errorf("SQL should contain WHERE id = $2, got: %s", sql)
+	if !strings.Contains(sql, `WHERE "id" = $2`) {
+		t.Errorf("SQL should contain WHERE \"id\" = $2, got: %s", sql)
 	}
 
 	// Args: 1 (deleted_at timestamp) + 1 (WHERE id) = 2
@@ -468,3 +540,179 @@ func TestPostgresBuilder_BuildDelete(t *testing.T) {
 		t.Errorf("args count = %d, want 2", len(args))
 	}
 }
+
+func TestProcessor_ConvertPayload_LogicalTypes(t *testing.T) {
+	p := newTestProcessor()
+	tableSchema := createOrdersSchema()
+
+	payload := map[string]any{
+		"id":    int64(1),
+		"total": "JxA=",
+	}
+	fields := map[string]models.FieldSchema{
+		"id":    {Type: "int64"},
+		"total": {Type: "bytes", Name: "org.apache.kafka.connect.data.Decimal", Parameters: map[string]string{"scale": "2"}},
+	}
+
+	converted := p.convertPayload(payload, fields, tableSchema)
+
+	// The Decimal logical type decodes the base64 unscaled value
+	if converted["total"] != "100.00" {
+		t.Errorf("total = %v, want 100.00", converted["total"])
+	}
+	if converted["id"] != int64(1) {
+		t.Errorf("id = %v, want 1", converted["id"])
+	}
+}
+
+func insertRows() []map[string]any {
+	return []map[string]any{
+		{"id": int64(1), "status": "open", "__op": "r"},
+		{"id": int64(2), "status": "paid", "__op": "r"},
+	}
+}
+
+func TestBuilders_ColumnOrder(t *testing.T) {
+	payload := map[string]any{
+		"status":      "pending",
+		"notes":       "extra",
+		"id":          int64(1),
+		"__op":        "c",
+		"total":       "99.99",
+		"customer_id": int64(100),
+		"archived":    false,
+	}
+
+	// Repeated to catch map iteration order leaking into the SQL
+	for range 20 {
+		sql, args, err := NewMySQLBuilder().BuildInsert("orders", payload, createOrdersSchema())
+		if err != nil {
+			t.Fatalf("BuildInsert() error = %v", err)
+		}
+		want := "INSERT INTO `orders` (`id`, `customer_id`, `total`, `status`, `archived`, `notes`, `deleted_at`) " +
+			"VALUES (?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE `customer_id` = VALUES(`customer_id`), " +
+			"`total` = VALUES(`total`), `status` = VALUES(`status`), `deleted_at` = NULL"
+		if sql != want {
+			t.Fatalf("SQL = %s\nwant  %s", sql, want)
+		}
+		wantArgs := []any{int64(1), int64(100), "99.99", "pending", false, "extra", nil}
+		if !reflect.DeepEqual(args, wantArgs) {
+			t.Fatalf("args = %v, want %v", args, wantArgs)
+		}
+	}
+}
+
+func TestPostgresBuilder_BuildUpdate_PrimaryKeyOrder(t *testing.T) {
+	tableSchema := createUsersSchema()
+	tableSchema.PrimaryKeys = []string{"role_id", "user_id"}
+
+	payload := map[string]any{"user_id": int64(1), "role_id": int64(2), "granted": "2025-01-01 12:00:00"}
+	sql, args, err := NewPostgresBuilder().BuildUpdate("user_roles", payload, tableSchema)
+	if err != nil {
+		t.Fatalf("BuildUpdate() error = %v", err)
+	}
+
+	want := `UPDATE "user_roles" SET "granted" = $1 WHERE "role_id" = $2 AND "user_id" = $3`
+	if sql != want {
+		t.Errorf("SQL = %s\nwant  %s", sql, want)
+	}
+	if wantArgs := []any{"2025-01-01 12:00:00", int64(2), int64(1)}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestMySQLBuilder_BuildInsertRows(t *testing.T) {
+	sql, args, err := NewMySQLBuilder().BuildInsertRows("orders", insertRows(), createOrdersSchema())
+	if err != nil {
+		t.Fatalf("BuildInsertRows() error = %v", err)
+	}
+
+	want := "INSERT INTO `orders` (`id`, `status`, `deleted_at`) VALUES (?, ?, ?), (?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE `status` = VALUES(`status`), `deleted_at` = NULL"
+	if sql != want {
+		t.Errorf("SQL = %s\nwant  %s", sql, want)
+	}
+	wantArgs := []any{int64(1), "open", nil, int64(2), "paid", nil}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestPostgresBuilder_BuildInsertRows(t *testing.T) {
+	sql, args, err := NewPostgresBuilder().BuildInsertRows("Orders", insertRows(), createOrdersSchema())
+	if err != nil {
+		t.Fatalf("BuildInsertRows() error = %v", err)
+	}
+
+	want := `INSERT INTO "orders" ("id", "status", deleted_at) VALUES ($1, $2, $3), ($4, $5, $6) ` +
+		`ON CONFLICT ("id") DO UPDATE SET "status" = EXCLUDED."status", deleted_at = NULL`
+	if sql != want {
+		t.Errorf("SQL = %s\nwant  %s", sql, want)
+	}
+	if len(args) != 6 {
+		t.Errorf("args count = %d, want 6", len(args))
+	}
+}
+
+func TestPostgresBuilder_BuildCopyRow(t *testing.T) {
+	payload := map[string]any{"Status": "open", "ID": int64(1), "__op": "r"}
+	tableSchema := createOrdersSchema()
+	tableSchema.PrimaryKeys = []string{"ID"}
+
+	got, err := NewPostgresBuilder().BuildCopyRow("Orders", payload, tableSchema)
+	if err != nil {
+		t.Fatalf("BuildCopyRow() error = %v", err)
+	}
+
+	want := &writer.CopyRows{
+		Table:       "orders",
+		Columns:     []string{"id", "status"},
+		PrimaryKeys: []string{"id"},
+		Rows:        [][]any{{int64(1), "open"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildCopyRow() = %+v, want %+v", got, want)
+	}
+
+	tableSchema.PrimaryKeys = nil
+	if _, err := NewPostgresBuilder().BuildCopyRow("orders", payload, tableSchema); !errors.Is(err, ErrNoPrimaryKey) {
+		t.Errorf("BuildCopyRow() error = %v, want ErrNoPrimaryKey", err)
+	}
+}
+
+func TestBuildInsertRows_Errors(t *testing.T) {
+	builders := map[string]SQLBuilder{"mysql": NewMySQLBuilder(), "postgres": NewPostgresBuilder()}
+	mismatched := []map[string]any{
+		{"id": int64(1), "status": "open"},
+		{"id": int64(2), "total": "1.00"},
+	}
+
+	// One more row than fits with two columns plus deleted_at
+	tooMany := make([]map[string]any, InsertRowLimit(2)+1)
+	for i := range tooMany {
+		tooMany[i] = map[string]any{"id": int64(i), "status": "open"}
+	}
+
+	for name, b := range builders {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := b.BuildInsertRows("orders", mismatched, createOrdersSchema()); err == nil {
+				t.Error("BuildInsertRows() should fail for rows with different columns")
+			}
+			if _, _, err := b.BuildInsertRows("orders", tooMany, createOrdersSchema()); err == nil {
+				t.Error("BuildInsertRows() should fail past the placeholder limit")
+			}
+			if _, _, err := b.BuildInsertRows("orders", nil, createOrdersSchema()); err == nil {
+				t.Error("BuildInsertRows() should fail without rows")
+			}
+		})
+	}
+}
+
+func TestInsertRowLimit(t *testing.T) {
+	if got := InsertRowLimit(4); got != 13107 {
+		t.Errorf("InsertRowLimit(4) = %d, want 13107", got)
+	}
+	if got := InsertRowLimit(4) * 5; got > MaxPlaceholders {
+		t.Errorf("InsertRowLimit(4) rows use %d placeholders, over %d", got, MaxPlaceholders)
+	}
+}
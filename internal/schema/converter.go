@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -36,6 +37,9 @@ func (c *Converter) ConvertValue(colInfo *ColumnInfo, value any) any {
 	if value == nil {
 		return nil
 	}
+	if n, ok := value.(json.Number); ok {
+		value = numberValue(n)
+	}
 
 	switch colInfo.DataType {
 	// Temporal types - MySQL: datetime, timestamp; PostgreSQL: timestamp with/without time zone
@@ -78,6 +82,18 @@ func (c *Converter) ConvertValue(colInfo *ColumnInfo, value any) any {
 	}
 }
 
+// numberValue turns a decoded JSON number into the Go type the database
+// drivers bind: int64 when it is an integer in range, float64 otherwise.
+func numberValue(n json.Number) any {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
+
 func (c *Converter) convertToDateTime(value any) any {
 	switch v := value.(type) {
 	case float64:
@@ -104,6 +120,14 @@ func (c *Converter) epochToDateTime(v int64) any {
 	// Step 1: Get UTC time from epoch (this gives us the wall-clock values)
 	utcTime := time.UnixMilli(v)
 
+	return c.wallClockToTarget(utcTime)
+}
+
+// wallClockToTarget reads the date and clock fields of t as source-timezone
+// wall-clock time and returns the value in the target's format.
+// For MySQL: returns string "2006-01-02 15:04:05" in the target timezone
+// For PostgreSQL: returns time.Time with source timezone (pgx handles TZ)
+func (c *Converter) wallClockToTarget(utcTime time.Time) any {
 	// Step 2: Treat those wall-clock values as source timezone
 	sourceWallClock := time.Date(
 		utcTime.Year(), utcTime.Month(), utcTime.Day(),
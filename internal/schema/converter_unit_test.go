@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -394,6 +395,29 @@ func TestConverter_ConvertValue_Passthrough(t *testing.T) {
 	}
 }
 
+func TestConverter_ConvertValue_JSONNumber(t *testing.T) {
+	c := NewConverter(nil, nil, config.TargetPostgres)
+
+	tests := []struct {
+		dataType string
+		input    json.Number
+		want     any
+	}{
+		{"bigint", "9007199254740993", int64(9007199254740993)}, // 2^53 + 1
+		{"double precision", "3.25", float64(3.25)},
+		{"boolean", "1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dataType, func(t *testing.T) {
+			col := &ColumnInfo{Name: "test_col", DataType: tt.dataType}
+			if got := c.ConvertValue(col, tt.input); got != tt.want {
+				t.Errorf("ConvertValue(%s) = %v (%T), want %v", tt.input, got, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestConverter_ConvertValue_Blob(t *testing.T) {
 	c := NewConverter(nil, nil, config.TargetMySQL)
 	col := &ColumnInfo{Name: "data", DataType: "blob"}
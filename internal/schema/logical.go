@@ -0,0 +1,241 @@
+package schema
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/models"
+)
+
+// Kafka Connect / Debezium logical type names carried in the embedded schema
+// when value.converter.schemas.enable=true.
+const (
+	debeziumDate                 = "io.debezium.time.Date"
+	debeziumTime                 = "io.debezium.time.Time"
+	debeziumMicroTime            = "io.debezium.time.MicroTime"
+	debeziumNanoTime             = "io.debezium.time.NanoTime"
+	debeziumTimestamp            = "io.debezium.time.Timestamp"
+	debeziumMicroTimestamp       = "io.debezium.time.MicroTimestamp"
+	debeziumNanoTimestamp        = "io.debezium.time.NanoTimestamp"
+	debeziumZonedTimestamp       = "io.debezium.time.ZonedTimestamp"
+	debeziumVariableScaleDecimal = "io.debezium.data.VariableScaleDecimal"
+	connectDate                  = "org.apache.kafka.connect.data.Date"
+	connectTime                  = "org.apache.kafka.connect.data.Time"
+	connectTimestamp             = "org.apache.kafka.connect.data.Timestamp"
+	connectDecimal               = "org.apache.kafka.connect.data.Decimal"
+)
+
+// ConvertLogical converts a value using the logical type from the message's
+// embedded schema rather than the target column's data type. It returns
+// false when the logical type is unknown, so the caller can fall back to
+// ConvertValue. colInfo may be nil for columns missing from the target.
+func (c *Converter) ConvertLogical(field models.FieldSchema, colInfo *ColumnInfo, value any) (any, bool) {
+	if field.Name == "" {
+		return nil, false
+	}
+	if value == nil {
+		return nil, true
+	}
+
+	var result any
+	var err error
+
+	switch field.Name {
+	case debeziumDate, connectDate:
+		var days int64
+		if days, err = toInt64(value); err == nil {
+			result = c.daysToDate(days)
+		}
+	case debeziumTimestamp, connectTimestamp:
+		var ms int64
+		if ms, err = toInt64(value); err == nil {
+			result = c.wallClockToTarget(time.UnixMilli(ms).UTC())
+		}
+	case debeziumMicroTimestamp:
+		var us int64
+		if us, err = toInt64(value); err == nil {
+			result = c.wallClockToTarget(time.UnixMicro(us).UTC())
+		}
+	case debeziumNanoTimestamp:
+		var ns int64
+		if ns, err = toInt64(value); err == nil {
+			result = c.wallClockToTarget(time.Unix(0, ns).UTC())
+		}
+	case debeziumZonedTimestamp:
+		result, err = c.zonedTimestamp(value)
+	case debeziumTime, connectTime:
+		var ms int64
+		if ms, err = toInt64(value); err == nil {
+			result = durationToTimeString(time.Duration(ms) * time.Millisecond)
+		}
+	case debeziumMicroTime:
+		var us int64
+		if us, err = toInt64(value); err == nil {
+			result = durationToTimeString(time.Duration(us) * time.Microsecond)
+		}
+	case debeziumNanoTime:
+		var ns int64
+		if ns, err = toInt64(value); err == nil {
+			result = durationToTimeString(time.Duration(ns))
+		}
+	case connectDecimal:
+		result, err = decodeDecimal(value, field.Parameters["scale"])
+	case debeziumVariableScaleDecimal:
+		result, err = decodeVariableScaleDecimal(value)
+	default:
+		return nil, false
+	}
+
+	if err != nil {
+		// Leave the value untouched and let the type-based path handle it
+		return nil, false
+	}
+	return c.adaptToColumn(colInfo, result), true
+}
+
+// zonedTimestamp parses an ISO-8601 timestamp with offset. Unlike
+// Timestamp, this is a real instant, so no source-timezone reinterpretation.
+func (c *Converter) zonedTimestamp(value any) (any, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected string, got %T", value)
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, err
+	}
+	if c.targetType == config.TargetPostgres {
+		return t, nil
+	}
+	return t.In(c.targetLocation).Format("2006-01-02 15:04:05"), nil
+}
+
+// adaptToColumn formats temporal values as text when the target column is a
+// character type, which pgx cannot bind time.Time to.
+func (c *Converter) adaptToColumn(colInfo *ColumnInfo, value any) any {
+	t, ok := value.(time.Time)
+	if !ok || colInfo == nil || !isTextType(colInfo.DataType) {
+		return value
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func isTextType(dataType string) bool {
+	switch dataType {
+	case "varchar", "char", "text", "longtext", "mediumtext", "tinytext", "character varying", "character":
+		return true
+	}
+	return false
+}
+
+// durationToTimeString formats time since midnight as HH:MM:SS, with a
+// fractional part only when there are sub-second digits.
+func durationToTimeString(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	mins := d / time.Minute
+	d -= mins * time.Minute
+	secs := d / time.Second
+	d -= secs * time.Second
+
+	s := fmt.Sprintf("%02d:%02d:%02d", hours, mins, secs)
+	if d > 0 {
+		frac := strings.TrimRight(fmt.Sprintf("%09d", d.Nanoseconds()), "0")
+		s += "." + frac
+	}
+	return s
+}
+
+// decodeDecimal decodes a Connect Decimal: the unscaled value as a
+// big-endian two's complement byte array, base64 encoded in JSON.
+func decodeDecimal(value any, scaleParam string) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("expected base64 string, got %T", value)
+	}
+	scale, err := strconv.Atoi(scaleParam)
+	if err != nil {
+		return "", fmt.Errorf("invalid decimal scale %q: %w", scaleParam, err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return formatDecimal(unscaledFromBytes(raw), scale), nil
+}
+
+// decodeVariableScaleDecimal decodes Debezium's {"scale": n, "value": base64}
+// struct used for DECIMAL columns without a fixed scale.
+func decodeVariableScaleDecimal(value any) (string, error) {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("expected struct, got %T", value)
+	}
+	scale, err := toInt64(m["scale"])
+	if err != nil {
+		return "", fmt.Errorf("invalid scale: %w", err)
+	}
+	return decodeDecimal(m["value"], strconv.FormatInt(scale, 10))
+}
+
+// unscaledFromBytes interprets b as a big-endian two's complement integer.
+func unscaledFromBytes(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+	}
+	return n
+}
+
+// formatDecimal renders unscaled * 10^-scale without losing precision.
+func formatDecimal(unscaled *big.Int, scale int) string {
+	if scale <= 0 {
+		return new(big.Int).Mul(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil)).String()
+	}
+
+	digits := new(big.Int).Abs(unscaled).String()
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	point := len(digits) - scale
+
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// toInt64 accepts the numeric types produced by JSON and Avro decoding.
+func toInt64(value any) (int64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, err
+		}
+		return int64(f), nil
+	case float64:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case int32:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("expected number, got %T", value)
+	}
+}
@@ -0,0 +1,113 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/models"
+)
+
+func TestConverter_ConvertLogical_MySQL(t *testing.T) {
+	c := NewConverter(time.UTC, time.UTC, config.TargetMySQL)
+
+	tests := []struct {
+		name  string
+		field models.FieldSchema
+		value any
+		want  any
+	}{
+		{"Date", models.FieldSchema{Name: debeziumDate}, float64(20089), "2025-01-01"},
+		{"connect Date", models.FieldSchema{Name: connectDate}, int32(20089), "2025-01-01"},
+		{"Timestamp", models.FieldSchema{Name: debeziumTimestamp}, float64(1735734645000), "2025-01-01 12:30:45"},
+		{"MicroTimestamp", models.FieldSchema{Name: debeziumMicroTimestamp}, float64(1735734645123456), "2025-01-01 12:30:45"},
+		{"NanoTimestamp", models.FieldSchema{Name: debeziumNanoTimestamp}, int64(1735734645123456789), "2025-01-01 12:30:45"},
+		{"ZonedTimestamp", models.FieldSchema{Name: debeziumZonedTimestamp}, "2025-01-01T12:30:45-05:00", "2025-01-01 17:30:45"},
+		{"Time", models.FieldSchema{Name: debeziumTime}, float64(45045000), "12:30:45"},
+		{"MicroTime", models.FieldSchema{Name: debeziumMicroTime}, float64(45045123456), "12:30:45.123456"},
+		{"NanoTime", models.FieldSchema{Name: debeziumNanoTime}, int64(45045000000500), "12:30:45.0000005"},
+		{"NanoTime json.Number", models.FieldSchema{Name: debeziumNanoTime}, json.Number("45045000000501"), "12:30:45.000000501"},
+		{"Decimal", models.FieldSchema{Name: connectDecimal, Parameters: map[string]string{"scale": "2"}}, "AQ==", "0.01"},
+		{"Decimal negative", models.FieldSchema{Name: connectDecimal, Parameters: map[string]string{"scale": "2"}}, "/zg=", "-2.00"},
+		{"Decimal large", models.FieldSchema{Name: connectDecimal, Parameters: map[string]string{"scale": "3"}}, "AJlg", "39.264"},
+		{"Decimal zero scale", models.FieldSchema{Name: connectDecimal, Parameters: map[string]string{"scale": "0"}}, "AQ==", "1"},
+		{"VariableScaleDecimal", models.FieldSchema{Name: debeziumVariableScaleDecimal}, map[string]any{"scale": float64(4), "value": "MDk="}, "1.2345"},
+		{"nil value", models.FieldSchema{Name: debeziumTimestamp}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := c.ConvertLogical(tt.field, nil, tt.value)
+			if !ok {
+				t.Fatalf("ConvertLogical() ok = false, want true")
+			}
+			if got != tt.want {
+				t.Errorf("ConvertLogical() = %v (%T), want %v", got, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverter_ConvertLogical_SourceTimezone(t *testing.T) {
+	denver, _ := time.LoadLocation("America/Denver")
+	newYork, _ := time.LoadLocation("America/New_York")
+	c := NewConverter(denver, newYork, config.TargetMySQL)
+
+	// Timestamp is source wall-clock time encoded as UTC epoch
+	got, _ := c.ConvertLogical(models.FieldSchema{Name: debeziumTimestamp}, nil, float64(1735734645000))
+	if got != "2025-01-01 14:30:45" {
+		t.Errorf("Timestamp = %v, want 2025-01-01 14:30:45", got)
+	}
+
+	// ZonedTimestamp is an absolute instant and ignores the source timezone
+	got, _ = c.ConvertLogical(models.FieldSchema{Name: debeziumZonedTimestamp}, nil, "2025-01-01T12:30:45Z")
+	if got != "2025-01-01 07:30:45" {
+		t.Errorf("ZonedTimestamp = %v, want 2025-01-01 07:30:45", got)
+	}
+}
+
+func TestConverter_ConvertLogical_Postgres(t *testing.T) {
+	c := NewConverter(time.UTC, time.UTC, config.TargetPostgres)
+
+	got, ok := c.ConvertLogical(models.FieldSchema{Name: debeziumMicroTimestamp}, &ColumnInfo{DataType: "timestamp without time zone"}, float64(1735734645123456))
+	if !ok {
+		t.Fatal("ConvertLogical() ok = false")
+	}
+	ts, isTime := got.(time.Time)
+	if !isTime {
+		t.Fatalf("ConvertLogical() = %T, want time.Time", got)
+	}
+	if want := time.Date(2025, 1, 1, 12, 30, 45, 123456000, time.UTC); !ts.Equal(want) {
+		t.Errorf("ConvertLogical() = %v, want %v", ts, want)
+	}
+
+	// A text target column gets a string instead of time.Time
+	got, _ = c.ConvertLogical(models.FieldSchema{Name: debeziumTimestamp}, &ColumnInfo{DataType: "character varying"}, float64(1735734645000))
+	if got != "2025-01-01T12:30:45Z" {
+		t.Errorf("ConvertLogical() into varchar = %v, want RFC3339 string", got)
+	}
+}
+
+func TestConverter_ConvertLogical_Fallback(t *testing.T) {
+	c := NewConverter(time.UTC, time.UTC, config.TargetMySQL)
+
+	tests := []struct {
+		name  string
+		field models.FieldSchema
+		value any
+	}{
+		{"no logical type", models.FieldSchema{Type: "int64"}, float64(1)},
+		{"unknown logical type", models.FieldSchema{Name: "io.debezium.data.Json"}, `{}`},
+		{"wrong value type", models.FieldSchema{Name: debeziumTimestamp}, "not a number"},
+		{"missing decimal scale", models.FieldSchema{Name: connectDecimal}, "AQ=="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := c.ConvertLogical(tt.field, nil, tt.value); ok {
+				t.Error("ConvertLogical() ok = true, want false")
+			}
+		})
+	}
+}
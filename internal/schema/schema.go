@@ -14,6 +14,7 @@ type ColumnInfo struct {
 	DataType   string // datetime, timestamp, decimal, varchar, int, bigint, etc.
 	IsNullable bool
 	IsPrimary  bool
+	Ordinal    int // 1-based position in the table; 0 if unknown
 }
 
 // TableSchema holds all metadata for a table
@@ -81,7 +82,8 @@ func (s *SchemaCache) queryTableSchema(table string) (*TableSchema, error) {
 			SELECT
 				column_name,
 				data_type,
-				is_nullable
+				is_nullable,
+				ordinal_position
 			FROM information_schema.columns
 			WHERE table_schema = 'public' AND table_name = $1
 			ORDER BY ordinal_position
@@ -93,7 +95,8 @@ func (s *SchemaCache) queryTableSchema(table string) (*TableSchema, error) {
 			SELECT
 				COLUMN_NAME,
 				DATA_TYPE,
-				IS_NULLABLE
+				IS_NULLABLE,
+				ORDINAL_POSITION
 			FROM information_schema.COLUMNS
 			WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
 			ORDER BY ORDINAL_POSITION
@@ -110,7 +113,7 @@ func (s *SchemaCache) queryTableSchema(table string) (*TableSchema, error) {
 	for rows.Next() {
 		var col ColumnInfo
 		var nullable string
-		if err := rows.Scan(&col.Name, &col.DataType, &nullable); err != nil {
+		if err := rows.Scan(&col.Name, &col.DataType, &nullable, &col.Ordinal); err != nil {
 			return nil, fmt.Errorf("failed to scan column: %w", err)
 		}
 		col.IsNullable = nullable == "YES"
@@ -0,0 +1,51 @@
+package writer
+
+import (
+	"context"
+	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+)
+
+// timeouts bounds how long a writer spends on a batch and on each of its
+// statements. A zero duration leaves that limit off.
+type timeouts struct {
+	batch     time.Duration
+	statement time.Duration
+}
+
+func newTimeouts(cfg *config.Config) timeouts {
+	return timeouts{
+		batch:     time.Duration(cfg.BatchTimeoutSec) * time.Second,
+		statement: time.Duration(cfg.StatementTimeoutMS) * time.Millisecond,
+	}
+}
+
+// withTimeout derives a context that expires after timeout, or is only
+// cancelled with its parent when timeout is zero
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// sleepCtx waits for d, or returns ctx's error as soon as ctx is done
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryBackoff returns the wait before the given retry (1-based), doubling
+// from backoffMS
+func retryBackoff(backoffMS, attempt int) time.Duration {
+	// #nosec G115 - attempt is bounded by maxRetries (typically < 10), no overflow risk
+	return time.Duration(backoffMS*(1<<uint(attempt-1))) * time.Millisecond
+}
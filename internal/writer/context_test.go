@@ -0,0 +1,66 @@
+package writer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSleepCtx(t *testing.T) {
+	if err := sleepCtx(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("sleepCtx() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := sleepCtx(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("sleepCtx() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("sleepCtx() waited %v after cancellation", elapsed)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("zero timeout should not set a deadline")
+	}
+
+	ctx, cancel = withTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Deadline() = %v, %v, want within a minute", deadline, ok)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	for i, w := range want {
+		if got := retryBackoff(1000, i+1); got != w {
+			t.Errorf("retryBackoff(1000, %d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestExecuteBatch_Cancelled(t *testing.T) {
+	// Never dialled: a cancelled context fails before a connection is taken
+	db, err := sql.Open("mysql", "cdc@tcp(127.0.0.1:1)/none")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	w := &MySQLWriter{db: db, maxRetries: 3, backoffMS: 60000}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = w.ExecuteBatch(ctx, []Query{{SQL: "SELECT 1", Table: "orders", Op: "INSERT"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ExecuteBatch() error = %v, want context.Canceled", err)
+	}
+}
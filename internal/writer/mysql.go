@@ -1,6 +1,7 @@
 package writer
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -19,11 +20,22 @@ type MySQLWriter struct {
 	db         *sql.DB
 	maxRetries int
 	backoffMS  int
+
+	// storeOffsets records Kafka offsets in the offsets table with each batch
+	storeOffsets bool
+
+	// stmts caches prepared statements by SQL; nil when disabled
+	stmts *stmtCache[*sql.Stmt]
+
+	timeouts timeouts
 }
 
 // Compile-time check that MySQLWriter implements Writer interface.
 var _ Writer = (*MySQLWriter)(nil)
 
+// Compile-time check that MySQLWriter implements OffsetStore interface.
+var _ OffsetStore = (*MySQLWriter)(nil)
+
 // NewMySQL creates a new MySQL writer from configuration.
 func NewMySQL(cfg *config.Config) (*MySQLWriter, error) {
 	db, err := sql.Open("mysql", cfg.TargetDSN())
@@ -47,31 +59,38 @@ func NewMySQL(cfg *config.Config) (*MySQLWriter, error) {
 		zap.String("database", cfg.TargetDB.Database))
 
 	return &MySQLWriter{
-		db:         db,
-		maxRetries: cfg.MaxRetries,
-		backoffMS:  cfg.RetryBackoffMS,
+		db:           db,
+		maxRetries:   cfg.MaxRetries,
+		backoffMS:    cfg.RetryBackoffMS,
+		storeOffsets: cfg.ExactlyOnce(),
+		stmts:        newSQLStmtCache(db, cfg.StmtCacheSize),
+		timeouts:     newTimeouts(cfg),
 	}, nil
 }
 
 // ExecuteBatch executes multiple queries in a single transaction with retry
-func (w *MySQLWriter) ExecuteBatch(queries []Query) error {
+func (w *MySQLWriter) ExecuteBatch(ctx context.Context, queries []Query) error {
 	if len(queries) == 0 {
 		return nil
 	}
 
+	ctx, cancel := withTimeout(ctx, w.timeouts.batch)
+	defer cancel()
+
 	var err error
 	for attempt := 0; attempt <= w.maxRetries; attempt++ {
 		if attempt > 0 {
-			// #nosec G115 - attempt is bounded by maxRetries (typically < 10), no overflow risk
-			backoff := time.Duration(w.backoffMS*(1<<uint(attempt-1))) * time.Millisecond
+			backoff := retryBackoff(w.backoffMS, attempt)
 			logger.Log.Warn("Retrying batch after error",
 				zap.Int("attempt", attempt),
 				zap.Duration("backoff", backoff),
 				zap.Error(err))
-			time.Sleep(backoff)
+			if sleepErr := sleepCtx(ctx, backoff); sleepErr != nil {
+				return fmt.Errorf("batch abandoned during retry backoff: %w (last error: %v)", sleepErr, err)
+			}
 		}
 
-		err = w.executeBatchOnce(queries)
+		err = w.executeBatchOnce(ctx, queries)
 		if err == nil {
 			if attempt > 0 {
 				logger.Log.Info("Batch succeeded after retry",
@@ -83,9 +102,6 @@ func (w *MySQLWriter) ExecuteBatch(queries []Query) error {
 		// Only retry on deadlock errors
 		if !isDeadlock(err) {
 			// Non-retryable error, fail immediately
-			for _, q := range queries {
-				metrics.EventsFailed.WithLabelValues(q.Table, q.Op, "execution_error").Inc()
-			}
 			return err
 		}
 
@@ -101,17 +117,17 @@ func (w *MySQLWriter) ExecuteBatch(queries []Query) error {
 }
 
 // executeBatchOnce executes the batch without retry logic
-func (w *MySQLWriter) executeBatchOnce(queries []Query) error {
+func (w *MySQLWriter) executeBatchOnce(ctx context.Context, queries []Query) error {
 	start := time.Now()
 
-	tx, err := w.db.Begin()
+	tx, err := w.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
+	exec := cachedExec(ctx, tx, w.stmts, w.timeouts.statement)
 	for i, q := range queries {
-		_, err := tx.Exec(q.SQL, q.Args...)
-		if err != nil {
+		if err := exec(q.SQL, q.Args...); err != nil {
 			_ = tx.Rollback()
 			logger.Log.Error("Batch query failed",
 				zap.Int("index", i),
@@ -122,6 +138,13 @@ func (w *MySQLWriter) executeBatchOnce(queries []Query) error {
 		}
 	}
 
+	if w.storeOffsets {
+		if err := storeOffsets(exec, mysqlUpsertOffsetSQL, queries); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit batch: %w", err)
 	}
@@ -129,7 +152,7 @@ func (w *MySQLWriter) executeBatchOnce(queries []Query) error {
 	// Record success metrics
 	duration := time.Since(start).Seconds()
 	for _, q := range queries {
-		metrics.EventsProcessed.WithLabelValues(q.Table, q.Op).Inc()
+		metrics.EventsProcessed.WithLabelValues(q.Table, q.Op).Add(float64(q.EventCount()))
 		metrics.QueryDuration.WithLabelValues(q.Table, q.Op).Observe(duration / float64(len(queries)))
 	}
 
@@ -149,7 +172,23 @@ func isDeadlock(err error) bool {
 		strings.Contains(errStr, "Deadlock found")
 }
 
+// EnsureOffsetsTable creates the offsets table if it does not exist.
+func (w *MySQLWriter) EnsureOffsetsTable() error {
+	if _, err := w.db.Exec(mysqlCreateOffsetsSQL); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", OffsetsTable, err)
+	}
+	return nil
+}
+
+// LoadOffsets returns the last applied offset by topic and partition.
+func (w *MySQLWriter) LoadOffsets() (map[string]map[int32]int64, error) {
+	return loadOffsets(w.db, mysqlSelectOffsetsSQL)
+}
+
 func (w *MySQLWriter) Close() error {
+	if w.stmts != nil {
+		w.stmts.Close()
+	}
 	return w.db.Close()
 }
 
@@ -0,0 +1,112 @@
+package writer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// OffsetsTable is the target table holding the last applied Kafka offsets.
+const OffsetsTable = "cdc_offsets"
+
+const (
+	mysqlCreateOffsetsSQL = "CREATE TABLE IF NOT EXISTS `" + OffsetsTable + "` (" +
+		"`topic` VARCHAR(255) NOT NULL, " +
+		"`kafka_partition` INT NOT NULL, " +
+		"`kafka_offset` BIGINT NOT NULL, " +
+		"`updated_at` DATETIME NOT NULL, " +
+		"PRIMARY KEY (`topic`, `kafka_partition`))"
+
+	mysqlUpsertOffsetSQL = "INSERT INTO `" + OffsetsTable + "` " +
+		"(`topic`, `kafka_partition`, `kafka_offset`, `updated_at`) VALUES (?, ?, ?, UTC_TIMESTAMP()) " +
+		"ON DUPLICATE KEY UPDATE `kafka_offset` = VALUES(`kafka_offset`), `updated_at` = VALUES(`updated_at`)"
+
+	mysqlSelectOffsetsSQL = "SELECT `topic`, `kafka_partition`, `kafka_offset` FROM `" + OffsetsTable + "`"
+
+	pgCreateOffsetsSQL = "CREATE TABLE IF NOT EXISTS " + OffsetsTable + " (" +
+		"topic TEXT NOT NULL, " +
+		"kafka_partition INTEGER NOT NULL, " +
+		"kafka_offset BIGINT NOT NULL, " +
+		"updated_at TIMESTAMPTZ NOT NULL, " +
+		"PRIMARY KEY (topic, kafka_partition))"
+
+	pgUpsertOffsetSQL = "INSERT INTO " + OffsetsTable + " " +
+		"(topic, kafka_partition, kafka_offset, updated_at) VALUES ($1, $2, $3, now()) " +
+		"ON CONFLICT (topic, kafka_partition) DO UPDATE SET kafka_offset = EXCLUDED.kafka_offset, updated_at = EXCLUDED.updated_at"
+
+	pgSelectOffsetsSQL = "SELECT topic, kafka_partition, kafka_offset FROM " + OffsetsTable
+)
+
+// topicPartition identifies a Kafka partition.
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// latestOffsets returns the highest offset per topic/partition in the batch.
+// Queries without a topic (not sourced from Kafka) are ignored.
+func latestOffsets(queries []Query) map[topicPartition]int64 {
+	offsets := make(map[topicPartition]int64)
+	for _, q := range queries {
+		if q.Topic == "" {
+			continue
+		}
+		tp := topicPartition{topic: q.Topic, partition: q.Partition}
+		if cur, ok := offsets[tp]; !ok || q.Offset > cur {
+			offsets[tp] = q.Offset
+		}
+	}
+	return offsets
+}
+
+// loadOffsets runs the dialect-specific select and groups rows by topic.
+func loadOffsets(db *sql.DB, query string) (map[string]map[int32]int64, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query offsets: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	offsets := make(map[string]map[int32]int64)
+	for rows.Next() {
+		var topic string
+		var partition int32
+		var offset int64
+		if err := rows.Scan(&topic, &partition, &offset); err != nil {
+			return nil, fmt.Errorf("failed to scan offset: %w", err)
+		}
+		if offsets[topic] == nil {
+			offsets[topic] = make(map[int32]int64)
+		}
+		offsets[topic][partition] = offset
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read offsets: %w", err)
+	}
+	return offsets, nil
+}
+
+// execFunc executes a statement inside an open transaction
+type execFunc func(query string, args ...any) error
+
+// txExec adapts a database/sql transaction to execFunc. Each statement runs
+// under ctx, for at most timeout when it is not zero.
+func txExec(ctx context.Context, tx *sql.Tx, timeout time.Duration) execFunc {
+	return func(query string, args ...any) error {
+		ctx, cancel := withTimeout(ctx, timeout)
+		defer cancel()
+		_, err := tx.ExecContext(ctx, query, args...)
+		return err
+	}
+}
+
+// storeOffsets upserts the batch's latest offsets inside the open transaction.
+func storeOffsets(exec execFunc, upsertSQL string, queries []Query) error {
+	for tp, offset := range latestOffsets(queries) {
+		if err := exec(upsertSQL, tp.topic, tp.partition, offset); err != nil {
+			return fmt.Errorf("failed to store offset for %s/%d: %w", tp.topic, tp.partition, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package writer
+
+import "testing"
+
+func TestLatestOffsets(t *testing.T) {
+	queries := []Query{
+		{Table: "orders", Topic: "pos_mysql.pos.orders", Partition: 0, Offset: 10},
+		{Table: "orders", Topic: "pos_mysql.pos.orders", Partition: 0, Offset: 12},
+		{Table: "orders", Topic: "pos_mysql.pos.orders", Partition: 0, Offset: 11},
+		{Table: "orders", Topic: "pos_mysql.pos.orders", Partition: 1, Offset: 3},
+		{Table: "items", Topic: "pos_mysql.pos.items", Partition: 0, Offset: 7},
+		{Table: "manual"}, // not sourced from Kafka
+	}
+
+	got := latestOffsets(queries)
+
+	want := map[topicPartition]int64{
+		{topic: "pos_mysql.pos.orders", partition: 0}: 12,
+		{topic: "pos_mysql.pos.orders", partition: 1}: 3,
+		{topic: "pos_mysql.pos.items", partition: 0}:  7,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("latestOffsets() returned %d partitions, want %d: %v", len(got), len(want), got)
+	}
+	for tp, offset := range want {
+		if got[tp] != offset {
+			t.Errorf("latestOffsets()[%v] = %d, want %d", tp, got[tp], offset)
+		}
+	}
+}
+
+func TestLatestOffsets_Empty(t *testing.T) {
+	if got := latestOffsets(nil); len(got) != 0 {
+		t.Errorf("latestOffsets(nil) = %v, want empty", got)
+	}
+}
@@ -0,0 +1,331 @@
+package writer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+
+	"github.com/sparkiss/pos-cdc/internal/config"
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+	"github.com/sparkiss/pos-cdc/pkg/logger"
+)
+
+// PgxWriter implements the Writer interface for PostgreSQL on a native pgx
+// pool. Each batch is sent as one pgx.Batch, so its statements are
+// pipelined in a single round trip instead of one per statement, and
+// statements are prepared once per connection and cached.
+type PgxWriter struct {
+	pool       *pgxpool.Pool
+	db         *sql.DB // database/sql view of pool, for schema queries
+	maxRetries int
+	backoffMS  int
+
+	// storeOffsets records Kafka offsets in the offsets table with each batch
+	storeOffsets bool
+
+	// copyMinRows is the smallest run of snapshot rows loaded with COPY;
+	// zero disables bulk loading
+	copyMinRows int
+
+	// Prepared statements are per connection, so each connection has its
+	// own cache. With stmtCacheSize zero, pgx's built-in cache is used.
+	stmtCacheSize int
+	stmtsMu       sync.Mutex
+	stmts         map[*pgx.Conn]*stmtCache[*pgconn.StatementDescription]
+
+	timeouts timeouts
+}
+
+// Compile-time check that PgxWriter implements Writer interface.
+var _ Writer = (*PgxWriter)(nil)
+
+// Compile-time check that PgxWriter implements OffsetStore interface.
+var _ OffsetStore = (*PgxWriter)(nil)
+
+// NewPgx creates a pipelining PostgreSQL writer from configuration.
+func NewPgx(cfg *config.Config) (*PgxWriter, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.TargetPostgresDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %w", err)
+	}
+
+	w := &PgxWriter{
+		maxRetries:    cfg.MaxRetries,
+		backoffMS:     cfg.RetryBackoffMS,
+		storeOffsets:  cfg.ExactlyOnce(),
+		copyMinRows:   cfg.BulkLoadMinRows,
+		stmtCacheSize: cfg.StmtCacheSize,
+		stmts:         make(map[*pgx.Conn]*stmtCache[*pgconn.StatementDescription]),
+		timeouts:      newTimeouts(cfg),
+	}
+
+	poolCfg.MaxConns = 25
+	poolCfg.MinConns = 5
+	poolCfg.MaxConnLifetime = 5 * time.Minute
+	if w.stmtCacheSize > 0 {
+		// Statements prepared by connStmts are used by name; the rest are
+		// sent unprepared instead of filling pgx's own cache
+		poolCfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeExec
+		poolCfg.BeforeClose = w.forgetConn
+	} else {
+		poolCfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	logger.Log.Info("Connected to PostgreSQL (pgx pipeline)",
+		zap.String("host", cfg.TargetPG.Host),
+		zap.Int("port", cfg.TargetPG.Port),
+		zap.String("database", cfg.TargetPG.Database))
+
+	w.pool = pool
+	w.db = stdlib.OpenDBFromPool(pool)
+	return w, nil
+}
+
+// ExecuteBatch executes multiple queries in a single transaction with retry logic.
+func (w *PgxWriter) ExecuteBatch(ctx context.Context, queries []Query) error {
+	if len(queries) == 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, w.timeouts.batch)
+	defer cancel()
+
+	var err error
+	for attempt := 0; attempt <= w.maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := retryBackoff(w.backoffMS, attempt)
+			logger.Log.Warn("Retrying batch after error",
+				zap.Int("attempt", attempt),
+				zap.Duration("backoff", backoff),
+				zap.Error(err))
+			if sleepErr := sleepCtx(ctx, backoff); sleepErr != nil {
+				return fmt.Errorf("batch abandoned during retry backoff: %w (last error: %v)", sleepErr, err)
+			}
+		}
+
+		err = w.executeBatchOnce(ctx, queries)
+		if err == nil {
+			if attempt > 0 {
+				logger.Log.Info("Batch succeeded after retry",
+					zap.Int("attempts", attempt+1))
+			}
+			return nil
+		}
+
+		if !isPostgresDeadlock(err) {
+			return err
+		}
+
+		metrics.EventsFailed.WithLabelValues("batch", "transaction", "deadlock").Inc()
+	}
+
+	for _, q := range queries {
+		metrics.EventsFailed.WithLabelValues(q.Table, q.Op, "deadlock_exhausted").Inc()
+	}
+	return fmt.Errorf("deadlock persisted after %d retries: %w", w.maxRetries, err)
+}
+
+func (w *PgxWriter) executeBatchOnce(ctx context.Context, queries []Query) error {
+	start := time.Now()
+
+	conn, err := w.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if runs := copyRuns(queries, w.copyMinRows); len(runs) > 0 {
+		// The statements around the COPY runs use the cache like a pipeline
+		if err := w.prepareBatch(ctx, conn.Conn(), outsideRuns(queries, runs)); err != nil {
+			return err
+		}
+		err = copyTx(ctx, conn.Conn(), queries, runs, w.storeOffsets, w.timeouts.statement)
+	} else {
+		err = w.sendBatch(ctx, conn.Conn(), queries)
+	}
+	if err != nil {
+		return err
+	}
+
+	recordBatch(queries, start)
+	return nil
+}
+
+// sendBatch pipelines the queries and the offset upserts inside one
+// transaction and reads their results in order. The statements share a
+// round trip, so their timeout is left to the server's statement_timeout.
+func (w *PgxWriter) sendBatch(ctx context.Context, conn *pgx.Conn, queries []Query) error {
+	if err := w.prepareBatch(ctx, conn, queries); err != nil {
+		return err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// No-op once committed. Not bound to ctx, which may be what failed.
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	if ms := w.timeouts.statement.Milliseconds(); ms > 0 {
+		if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", ms)); err != nil {
+			return fmt.Errorf("failed to set statement timeout: %w", err)
+		}
+	}
+
+	batch := queueBatch(queries, w.storeOffsets)
+	results := tx.SendBatch(ctx, batch)
+
+	for i, q := range queries {
+		if _, err := results.Exec(); err != nil {
+			_ = results.Close()
+			logger.Log.Error("Batch query failed",
+				zap.Int("index", i),
+				zap.String("table", q.Table),
+				zap.String("op", q.Op),
+				zap.String("sql", q.SQL),
+				zap.Error(err))
+			return fmt.Errorf("failed to execute %s on %s: %w", q.Op, q.Table, err)
+		}
+	}
+	for range batch.Len() - len(queries) {
+		if _, err := results.Exec(); err != nil {
+			_ = results.Close()
+			return fmt.Errorf("failed to store offsets: %w", err)
+		}
+	}
+	if err := results.Close(); err != nil {
+		return fmt.Errorf("failed to read batch results: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return nil
+}
+
+// prepareBatch prepares the batch's statements that are not yet cached on
+// conn, so the pipeline executes them by name.
+func (w *PgxWriter) prepareBatch(ctx context.Context, conn *pgx.Conn, queries []Query) error {
+	stmts := w.connStmts(conn)
+	if stmts == nil {
+		return nil
+	}
+
+	for i, q := range queries {
+		if _, err := stmts.Get(ctx, q.SQL); err != nil {
+			logger.Log.Error("Batch query failed",
+				zap.Int("index", i),
+				zap.String("table", q.Table),
+				zap.String("op", q.Op),
+				zap.String("sql", q.SQL),
+				zap.Error(err))
+			return fmt.Errorf("failed to prepare %s on %s: %w", q.Op, q.Table, err)
+		}
+	}
+	if w.storeOffsets {
+		if _, err := stmts.Get(ctx, pgUpsertOffsetSQL); err != nil {
+			return fmt.Errorf("failed to prepare offset upsert: %w", err)
+		}
+	}
+	return nil
+}
+
+// connStmts returns the statement cache of conn, or nil when caching is
+// disabled. The caller must hold conn.
+func (w *PgxWriter) connStmts(conn *pgx.Conn) *stmtCache[*pgconn.StatementDescription] {
+	if w.stmtCacheSize <= 0 {
+		return nil
+	}
+
+	w.stmtsMu.Lock()
+	defer w.stmtsMu.Unlock()
+
+	stmts, ok := w.stmts[conn]
+	if !ok {
+		// Named after their SQL, so pgx matches queued queries to them
+		stmts = newStmtCache(w.stmtCacheSize,
+			func(ctx context.Context, query string) (*pgconn.StatementDescription, error) {
+				return conn.Prepare(ctx, query, query)
+			},
+			func(sd *pgconn.StatementDescription) {
+				_ = conn.Deallocate(context.Background(), sd.SQL)
+			})
+		w.stmts[conn] = stmts
+	}
+	return stmts
+}
+
+// forgetConn drops the statement cache of a connection the pool closes
+func (w *PgxWriter) forgetConn(conn *pgx.Conn) {
+	w.stmtsMu.Lock()
+	defer w.stmtsMu.Unlock()
+	delete(w.stmts, conn)
+}
+
+// queueBatch queues the queries followed, when withOffsets is set, by the
+// upserts of the batch's latest offsets.
+func queueBatch(queries []Query, withOffsets bool) *pgx.Batch {
+	batch := &pgx.Batch{}
+	for _, q := range queries {
+		batch.Queue(q.SQL, q.Args...)
+	}
+	if withOffsets {
+		// Queuing cannot fail
+		_ = storeOffsets(func(query string, args ...any) error {
+			batch.Queue(query, args...)
+			return nil
+		}, pgUpsertOffsetSQL, queries)
+	}
+	return batch
+}
+
+// EnsureOffsetsTable creates the offsets table if it does not exist.
+func (w *PgxWriter) EnsureOffsetsTable() error {
+	if _, err := w.pool.Exec(context.Background(), pgCreateOffsetsSQL); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", OffsetsTable, err)
+	}
+	return nil
+}
+
+// LoadOffsets returns the last applied offset by topic and partition.
+func (w *PgxWriter) LoadOffsets() (map[string]map[int32]int64, error) {
+	return loadOffsets(w.db, pgSelectOffsetsSQL)
+}
+
+func (w *PgxWriter) Close() error {
+	err := w.db.Close()
+	w.pool.Close()
+
+	// The closed connections' statements are gone with them
+	w.stmtsMu.Lock()
+	clear(w.stmts)
+	w.stmtsMu.Unlock()
+	return err
+}
+
+func (w *PgxWriter) Ping() error {
+	return w.pool.Ping(context.Background())
+}
+
+func (w *PgxWriter) DB() *sql.DB {
+	return w.db
+}
@@ -0,0 +1,73 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestQueueBatch(t *testing.T) {
+	queries := []Query{
+		{SQL: "INSERT a", Args: []any{1}, Topic: "pos.orders", Partition: 0, Offset: 4},
+		{SQL: "UPDATE b", Args: []any{2, "x"}, Topic: "pos.orders", Partition: 0, Offset: 5},
+	}
+
+	batch := queueBatch(queries, false)
+	if batch.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", batch.Len())
+	}
+	for i, q := range batch.QueuedQueries {
+		if q.SQL != queries[i].SQL || fmt.Sprint(q.Arguments) != fmt.Sprint(queries[i].Args) {
+			t.Errorf("queued[%d] = %s %v, want %s %v", i, q.SQL, q.Arguments, queries[i].SQL, queries[i].Args)
+		}
+	}
+
+	batch = queueBatch(queries, true)
+	if batch.Len() != 3 {
+		t.Fatalf("Len() = %d, want 2 queries and 1 offset upsert", batch.Len())
+	}
+	last := batch.QueuedQueries[2]
+	if last.SQL != pgUpsertOffsetSQL || fmt.Sprint(last.Arguments) != "[pos.orders 0 5]" {
+		t.Errorf("offset upsert = %s %v, want latest offset 5", last.SQL, last.Arguments)
+	}
+}
+
+func TestPgxWriter_ConnStmts(t *testing.T) {
+	w := &PgxWriter{
+		stmtCacheSize: 2,
+		stmts:         make(map[*pgx.Conn]*stmtCache[*pgconn.StatementDescription]),
+	}
+	a, b := &pgx.Conn{}, &pgx.Conn{}
+
+	stmtsA := w.connStmts(a)
+	if stmtsA == nil || w.connStmts(a) != stmtsA {
+		t.Fatal("connStmts() should return the same cache for a connection")
+	}
+	if w.connStmts(b) == stmtsA {
+		t.Error("connStmts() should give each connection its own cache")
+	}
+
+	// A closed connection's cache is dropped, not reused by a new one
+	w.forgetConn(a)
+	if len(w.stmts) != 1 {
+		t.Errorf("len(stmts) = %d, want 1 after forgetConn", len(w.stmts))
+	}
+	if w.connStmts(a) == stmtsA {
+		t.Error("connStmts() after forgetConn should start a new cache")
+	}
+}
+
+func TestPgxWriter_PrepareBatchWithoutCache(t *testing.T) {
+	w := &PgxWriter{storeOffsets: true}
+
+	// Nothing is prepared, so the connection is never used
+	if err := w.prepareBatch(context.Background(), nil, []Query{{SQL: "INSERT a"}}); err != nil {
+		t.Errorf("prepareBatch() error = %v, want nil with caching disabled", err)
+	}
+	if w.connStmts(nil) != nil {
+		t.Error("connStmts() should return nil with caching disabled")
+	}
+}
@@ -1,6 +1,7 @@
 package writer
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -19,11 +20,26 @@ type PostgresWriter struct {
 	db         *sql.DB
 	maxRetries int
 	backoffMS  int
+
+	// storeOffsets records Kafka offsets in the offsets table with each batch
+	storeOffsets bool
+
+	// copyMinRows is the smallest run of snapshot rows loaded with COPY;
+	// zero disables bulk loading
+	copyMinRows int
+
+	// stmts caches prepared statements by SQL; nil when disabled
+	stmts *stmtCache[*sql.Stmt]
+
+	timeouts timeouts
 }
 
 // Compile-time check that PostgresWriter implements Writer interface.
 var _ Writer = (*PostgresWriter)(nil)
 
+// Compile-time check that PostgresWriter implements OffsetStore interface.
+var _ OffsetStore = (*PostgresWriter)(nil)
+
 // NewPostgres creates a new PostgreSQL writer from configuration.
 func NewPostgres(cfg *config.Config) (*PostgresWriter, error) {
 	db, err := sql.Open("pgx", cfg.TargetPostgresDSN())
@@ -45,31 +61,39 @@ func NewPostgres(cfg *config.Config) (*PostgresWriter, error) {
 		zap.String("database", cfg.TargetPG.Database))
 
 	return &PostgresWriter{
-		db:         db,
-		maxRetries: cfg.MaxRetries,
-		backoffMS:  cfg.RetryBackoffMS,
+		db:           db,
+		maxRetries:   cfg.MaxRetries,
+		backoffMS:    cfg.RetryBackoffMS,
+		storeOffsets: cfg.ExactlyOnce(),
+		copyMinRows:  cfg.BulkLoadMinRows,
+		stmts:        newSQLStmtCache(db, cfg.StmtCacheSize),
+		timeouts:     newTimeouts(cfg),
 	}, nil
 }
 
 // ExecuteBatch executes multiple queries in a single transaction with retry logic.
-func (w *PostgresWriter) ExecuteBatch(queries []Query) error {
+func (w *PostgresWriter) ExecuteBatch(ctx context.Context, queries []Query) error {
 	if len(queries) == 0 {
 		return nil
 	}
 
+	ctx, cancel := withTimeout(ctx, w.timeouts.batch)
+	defer cancel()
+
 	var err error
 	for attempt := 0; attempt <= w.maxRetries; attempt++ {
 		if attempt > 0 {
-			// #nosec G115 - attempt is bounded by maxRetries (typically < 10), no overflow risk
-			backoff := time.Duration(w.backoffMS*(1<<uint(attempt-1))) * time.Millisecond
+			backoff := retryBackoff(w.backoffMS, attempt)
 			logger.Log.Warn("Retrying batch after error",
 				zap.Int("attempt", attempt),
 				zap.Duration("backoff", backoff),
 				zap.Error(err))
-			time.Sleep(backoff)
+			if sleepErr := sleepCtx(ctx, backoff); sleepErr != nil {
+				return fmt.Errorf("batch abandoned during retry backoff: %w (last error: %v)", sleepErr, err)
+			}
 		}
 
-		err = w.executeBatchOnce(queries)
+		err = w.executeBatchOnce(ctx, queries)
 		if err == nil {
 			if attempt > 0 {
 				logger.Log.Info("Batch succeeded after retry",
@@ -79,9 +103,6 @@ func (w *PostgresWriter) ExecuteBatch(queries []Query) error {
 		}
 
 		if !isPostgresDeadlock(err) {
-			for _, q := range queries {
-				metrics.EventsFailed.WithLabelValues(q.Table, q.Op, "execution_error").Inc()
-			}
 			return err
 		}
 
@@ -94,20 +115,50 @@ func (w *PostgresWriter) ExecuteBatch(queries []Query) error {
 	return fmt.Errorf("deadlock persisted after %d retries: %w", w.maxRetries, err)
 }
 
-func (w *PostgresWriter) executeBatchOnce(queries []Query) error {
+func (w *PostgresWriter) executeBatchOnce(ctx context.Context, queries []Query) error {
 	start := time.Now()
 
-	tx, err := w.db.Begin()
+	if runs := copyRuns(queries, w.copyMinRows); len(runs) > 0 {
+		if err := w.copyBatch(ctx, queries, runs); err != nil {
+			return err
+		}
+		recordBatch(queries, start)
+		return nil
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	for i, q := range queries {
-		_, err := tx.Exec(q.SQL, q.Args...)
-		if err != nil {
+	exec := cachedExec(ctx, tx, w.stmts, w.timeouts.statement)
+	if err := execQueries(exec, queries, 0); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if w.storeOffsets {
+		if err := storeOffsets(exec, pgUpsertOffsetSQL, queries); err != nil {
 			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	recordBatch(queries, start)
+	return nil
+}
+
+// execQueries executes queries in order inside an open transaction. first
+// is the batch index of queries[0], for logging.
+func execQueries(exec execFunc, queries []Query, first int) error {
+	for i, q := range queries {
+		if err := exec(q.SQL, q.Args...); err != nil {
 			logger.Log.Error("Batch query failed",
-				zap.Int("index", i),
+				zap.Int("index", first+i),
 				zap.String("table", q.Table),
 				zap.String("op", q.Op),
 				zap.String("sql", q.SQL),
@@ -115,21 +166,19 @@ func (w *PostgresWriter) executeBatchOnce(queries []Query) error {
 			return fmt.Errorf("failed to execute %s on %s: %w", q.Op, q.Table, err)
 		}
 	}
+	return nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit batch: %w", err)
-	}
-
+// recordBatch updates metrics for a committed batch
+func recordBatch(queries []Query, start time.Time) {
 	duration := time.Since(start).Seconds()
 	for _, q := range queries {
-		metrics.EventsProcessed.WithLabelValues(q.Table, q.Op).Inc()
+		metrics.EventsProcessed.WithLabelValues(q.Table, q.Op).Add(float64(q.EventCount()))
 		metrics.QueryDuration.WithLabelValues(q.Table, q.Op).Observe(duration / float64(len(queries)))
 	}
 
 	logger.Log.Debug("Batch committed",
 		zap.Int("count", len(queries)))
-
-	return nil
 }
 
 // isPostgresDeadlock checks if the error is a PostgreSQL deadlock.
@@ -143,7 +192,23 @@ func isPostgresDeadlock(err error) bool {
 		strings.Contains(errStr, "deadlock detected")
 }
 
+// EnsureOffsetsTable creates the offsets table if it does not exist.
+func (w *PostgresWriter) EnsureOffsetsTable() error {
+	if _, err := w.db.Exec(pgCreateOffsetsSQL); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", OffsetsTable, err)
+	}
+	return nil
+}
+
+// LoadOffsets returns the last applied offset by topic and partition.
+func (w *PostgresWriter) LoadOffsets() (map[string]map[int32]int64, error) {
+	return loadOffsets(w.db, pgSelectOffsetsSQL)
+}
+
 func (w *PostgresWriter) Close() error {
+	if w.stmts != nil {
+		w.stmts.Close()
+	}
 	return w.db.Close()
 }
 
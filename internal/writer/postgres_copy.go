@@ -0,0 +1,318 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"go.uber.org/zap"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+	"github.com/sparkiss/pos-cdc/pkg/logger"
+)
+
+// copyRun is a range of queries, queries[start:end], loaded with one COPY
+type copyRun struct {
+	start, end int
+}
+
+// outsideRuns returns the queries that are not part of any run, in order.
+func outsideRuns(queries []Query, runs []copyRun) []Query {
+	var rest []Query
+	next := 0
+	for _, run := range runs {
+		rest = append(rest, queries[next:run.start]...)
+		next = run.end
+	}
+	return append(rest, queries[next:]...)
+}
+
+// copyRuns finds the runs of consecutive queries carrying rows for the same
+// table and columns that hold at least minRows rows between them. Shorter
+// runs are cheaper to execute as plain upserts.
+func copyRuns(queries []Query, minRows int) []copyRun {
+	if minRows <= 0 {
+		return nil
+	}
+
+	var runs []copyRun
+	start, rows := 0, 0
+	for i, q := range queries {
+		if i > start && q.Copy != nil && sameCopyShape(queries[start].Copy, q.Copy) {
+			rows += len(q.Copy.Rows)
+			continue
+		}
+		if rows >= minRows {
+			runs = append(runs, copyRun{start: start, end: i})
+		}
+		start, rows = i, 0
+		if q.Copy != nil {
+			rows = len(q.Copy.Rows)
+		}
+	}
+	if rows >= minRows {
+		runs = append(runs, copyRun{start: start, end: len(queries)})
+	}
+	return runs
+}
+
+func sameCopyShape(a, b *CopyRows) bool {
+	return a != nil && a.Table == b.Table && slices.Equal(a.Columns, b.Columns)
+}
+
+// copyBatch applies a batch containing copy runs on the native pgx
+// connection behind the pool (see copyTx).
+func (w *PostgresWriter) copyBatch(ctx context.Context, queries []Query, runs []copyRun) error {
+	conn, err := w.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	return conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("bulk loading needs a pgx connection, got %T", driverConn)
+		}
+		return copyTx(ctx, stdConn.Conn(), queries, runs, w.storeOffsets, w.timeouts.statement)
+	})
+}
+
+// copyTx applies a batch containing copy runs in one transaction. Queries
+// outside the runs are executed as usual; each run is streamed into a
+// temporary staging table with COPY and merged into the target with a
+// single upsert. Each statement, COPY included, runs for at most
+// stmtTimeout when it is not zero.
+func copyTx(ctx context.Context, conn *pgx.Conn, queries []Query, runs []copyRun, withOffsets bool, stmtTimeout time.Duration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// No-op once committed. Not bound to ctx, which may be what failed.
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	exec := func(query string, args ...any) error {
+		ctx, cancel := withTimeout(ctx, stmtTimeout)
+		defer cancel()
+		_, err := tx.Exec(ctx, query, args...)
+		return err
+	}
+
+	next := 0
+	for _, run := range runs {
+		if err := execQueries(exec, queries[next:run.start], next); err != nil {
+			return err
+		}
+		if err := copyMerge(ctx, tx, exec, queries[run.start:run.end], stmtTimeout); err != nil {
+			return err
+		}
+		next = run.end
+	}
+	if err := execQueries(exec, queries[next:], next); err != nil {
+		return err
+	}
+
+	if withOffsets {
+		if err := storeOffsets(exec, pgUpsertOffsetSQL, queries); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	for _, run := range runs {
+		for _, q := range queries[run.start:run.end] {
+			metrics.BulkLoadedRows.WithLabelValues(q.Copy.Table).Add(float64(len(q.Copy.Rows)))
+		}
+	}
+	return nil
+}
+
+// copyMerge loads the rows of a copy run into a staging table and upserts
+// them into the target. A repeated primary key keeps its latest row, since
+// one upsert cannot change a row twice. exec runs the statements around
+// the COPY.
+func copyMerge(ctx context.Context, tx pgx.Tx, exec execFunc, queries []Query, stmtTimeout time.Duration) error {
+	c := queries[0].Copy
+	rows := latestCopyRows(queries)
+	table := quoteIdent(c.Table)
+	stage := quoteIdent("cdc_stage_" + c.Table)
+	columns := quoteIdents(c.Columns)
+
+	data, err := copyCSV(rows)
+	if err != nil {
+		return fmt.Errorf("failed to encode rows for %s: %w", c.Table, err)
+	}
+
+	create := fmt.Sprintf("CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA",
+		stage, columns, table)
+	if err := exec(create); err != nil {
+		return fmt.Errorf("failed to create staging table for %s: %w", c.Table, err)
+	}
+
+	copyCtx, cancel := withTimeout(ctx, stmtTimeout)
+	defer cancel()
+	copySQL := fmt.Sprintf("COPY %s (%s) FROM STDIN WITH (FORMAT csv)", stage, columns)
+	if _, err := tx.Conn().PgConn().CopyFrom(copyCtx, bytes.NewReader(data), copySQL); err != nil {
+		return fmt.Errorf("failed to copy rows into %s: %w", c.Table, err)
+	}
+
+	if err := exec(copyMergeSQL(c, stage)); err != nil {
+		return fmt.Errorf("failed to merge staged rows into %s: %w", c.Table, err)
+	}
+
+	// Dropped now so a later run for the same table can stage again
+	if err := exec("DROP TABLE " + stage); err != nil {
+		return fmt.Errorf("failed to drop staging table for %s: %w", c.Table, err)
+	}
+
+	logger.Log.Debug("Bulk loaded rows",
+		zap.String("table", c.Table),
+		zap.Int("rows", len(rows)))
+	return nil
+}
+
+// copyMergeSQL upserts the staged rows with the same behavior as
+// PostgresBuilder.BuildInsert, including un-deleting re-inserted rows.
+// stage is already quoted.
+func copyMergeSQL(c *CopyRows, stage string) string {
+	var updateClauses []string
+	for _, name := range c.Columns {
+		if !slices.Contains(c.PrimaryKeys, name) {
+			col := quoteIdent(name)
+			updateClauses = append(updateClauses, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+		}
+	}
+	updateClauses = append(updateClauses, "deleted_at = NULL")
+
+	columns := quoteIdents(c.Columns)
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s, deleted_at) SELECT %s, NULL FROM %s ON CONFLICT (%s) DO UPDATE SET %s",
+		quoteIdent(c.Table),
+		columns,
+		columns,
+		stage,
+		quoteIdents(c.PrimaryKeys),
+		strings.Join(updateClauses, ", "),
+	)
+}
+
+// quoteIdent quotes a PostgreSQL identifier
+func quoteIdent(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// quoteIdents quotes and joins a column list
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// latestCopyRows returns the rows of a copy run, keeping only the last row
+// for each primary key
+func latestCopyRows(queries []Query) [][]any {
+	c := queries[0].Copy
+	var pkIdx []int
+	for _, pk := range c.PrimaryKeys {
+		pkIdx = append(pkIdx, slices.Index(c.Columns, pk))
+	}
+
+	var rows [][]any
+	index := make(map[string]int)
+	for _, q := range queries {
+		for _, row := range q.Copy.Rows {
+			pk := make([]string, len(pkIdx))
+			for j, i := range pkIdx {
+				if i >= 0 {
+					pk[j] = fmt.Sprint(row[i])
+				}
+			}
+			key := strings.Join(pk, "\x00")
+			if i, ok := index[key]; ok {
+				rows[i] = row
+				continue
+			}
+			index[key] = len(rows)
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// copyCSV encodes rows for COPY ... WITH (FORMAT csv). Values are quoted so
+// that only NULL is left empty and unquoted.
+func copyCSV(rows [][]any) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, row := range rows {
+		for i, v := range row {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			text, ok, err := copyText(v)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			buf.WriteByte('"')
+			buf.WriteString(strings.ReplaceAll(text, `"`, `""`))
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// copyText formats a value in PostgreSQL's text input format. It reports
+// false for NULL.
+func copyText(value any) (string, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		return v, true, nil
+	case []byte:
+		return `\x` + hex.EncodeToString(v), true, nil
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999999Z07:00"), true, nil
+	case bool:
+		return strconv.FormatBool(v), true, nil
+	case json.Number:
+		return v.String(), true, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true, nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), true, nil
+	case driver.Valuer:
+		dv, err := v.Value()
+		if err != nil {
+			return "", false, err
+		}
+		return copyText(dv)
+	case map[string]any, []any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", false, err
+		}
+		return string(b), true, nil
+	default:
+		return fmt.Sprint(v), true, nil
+	}
+}
@@ -0,0 +1,123 @@
+package writer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func copyQuery(table string, ids ...int) Query {
+	c := &CopyRows{
+		Table:       table,
+		Columns:     []string{"id", "status"},
+		PrimaryKeys: []string{"id"},
+	}
+	for _, id := range ids {
+		c.Rows = append(c.Rows, []any{id, fmt.Sprintf("s%d", id)})
+	}
+	return Query{Table: table, Op: "INSERT", Copy: c, Rows: len(ids)}
+}
+
+func TestCopyRuns(t *testing.T) {
+	other := copyQuery("orders", 9)
+	other.Copy.Columns = []string{"id", "total"}
+
+	queries := []Query{
+		copyQuery("orders", 1, 2),
+		copyQuery("orders", 3),
+		{Table: "orders", Op: "UPDATE"}, // breaks the run
+		copyQuery("orders", 4),          // too short
+		copyQuery("items", 1, 2, 3),
+		other, // other columns
+		copyQuery("items", 4, 5, 6),
+	}
+
+	got := copyRuns(queries, 3)
+	want := []copyRun{{start: 0, end: 2}, {start: 4, end: 5}, {start: 6, end: 7}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("copyRuns() = %v, want %v", got, want)
+	}
+
+	if got := copyRuns(queries, 0); got != nil {
+		t.Errorf("copyRuns() = %v, want nil when disabled", got)
+	}
+}
+
+func TestCopyRuns_SnapshotBatch(t *testing.T) {
+	// A worker batch of snapshot rows larger than BATCH_SIZE, one query
+	// per row, is loaded with a single COPY
+	queries := make([]Query, 300)
+	for i := range queries {
+		queries[i] = copyQuery("orders", i)
+	}
+
+	got := copyRuns(queries, 250)
+	if want := []copyRun{{start: 0, end: 300}}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("copyRuns() = %v, want %v", got, want)
+	}
+}
+
+func TestOutsideRuns(t *testing.T) {
+	queries := []Query{
+		{SQL: "UPDATE a"},
+		copyQuery("orders", 1, 2, 3),
+		{SQL: "UPDATE b"},
+		copyQuery("orders", 4, 5, 6),
+		{SQL: "DELETE c"},
+	}
+
+	var got []string
+	for _, q := range outsideRuns(queries, []copyRun{{start: 1, end: 2}, {start: 3, end: 4}}) {
+		got = append(got, q.SQL)
+	}
+	if want := []string{"UPDATE a", "UPDATE b", "DELETE c"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("outsideRuns() = %v, want %v", got, want)
+	}
+}
+
+func TestCopyMergeSQL(t *testing.T) {
+	c := &CopyRows{
+		Table:       "user_roles",
+		Columns:     []string{"granted", "role_id", "user_id"},
+		PrimaryKeys: []string{"user_id", "role_id"},
+	}
+
+	got := copyMergeSQL(c, `"cdc_stage_user_roles"`)
+	want := `INSERT INTO "user_roles" ("granted", "role_id", "user_id", deleted_at) ` +
+		`SELECT "granted", "role_id", "user_id", NULL FROM "cdc_stage_user_roles" ` +
+		`ON CONFLICT ("user_id", "role_id") DO UPDATE SET "granted" = EXCLUDED."granted", deleted_at = NULL`
+	if got != want {
+		t.Errorf("copyMergeSQL() = %s\nwant %s", got, want)
+	}
+}
+
+func TestLatestCopyRows(t *testing.T) {
+	queries := []Query{copyQuery("orders", 1, 2), copyQuery("orders", 1, 3)}
+	queries[1].Copy.Rows[0][1] = "latest"
+
+	got := latestCopyRows(queries)
+	if want := "[[1 latest] [2 s2] [3 s3]]"; fmt.Sprint(got) != want {
+		t.Errorf("latestCopyRows() = %v, want %s", got, want)
+	}
+}
+
+func TestCopyCSV(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 30, 0, 500000000, time.FixedZone("", -7*3600))
+	rows := [][]any{
+		{int64(1), "say \"hi\", bye", nil, ""},
+		{2.5, true, at, []byte{0xde, 0xad}},
+		{map[string]any{"a": 1}, "multi\nline", int32(7), float32(0.25)},
+	}
+
+	got, err := copyCSV(rows)
+	if err != nil {
+		t.Fatalf("copyCSV() error = %v", err)
+	}
+
+	want := `"1","say ""hi"", bye",,""` + "\n" +
+		`"2.5","true","2025-03-01 12:30:00.5-07:00","\xdead"` + "\n" +
+		`"{""a"":1}","multi` + "\n" + `line","7","0.25"` + "\n"
+	if string(got) != want {
+		t.Errorf("copyCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package writer
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/sparkiss/pos-cdc/internal/metrics"
+)
+
+// stmtCache is an LRU of prepared statements keyed by SQL text. Builders
+// emit columns in table order, so every event with the same table,
+// operation and columns shares one statement. Evicted statements are
+// released.
+type stmtCache[S any] struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // most recently used first; values are *stmtEntry[S]
+	entries  map[string]*list.Element
+	prepare  func(ctx context.Context, query string) (S, error)
+	release  func(stmt S)
+}
+
+type stmtEntry[S any] struct {
+	query string
+	stmt  S
+}
+
+func newStmtCache[S any](capacity int, prepare func(ctx context.Context, query string) (S, error), release func(stmt S)) *stmtCache[S] {
+	return &stmtCache[S]{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		prepare:  prepare,
+		release:  release,
+	}
+}
+
+// Get returns the statement for query, preparing it on a miss. The lock is
+// not held while preparing, so a miss does not stall other callers.
+func (c *stmtCache[S]) Get(ctx context.Context, query string) (S, error) {
+	if stmt, ok := c.lookup(query); ok {
+		metrics.StmtCacheHits.Inc()
+		return stmt, nil
+	}
+	metrics.StmtCacheMisses.Inc()
+
+	stmt, err := c.prepare(ctx, query)
+	if err != nil {
+		return stmt, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Prepared concurrently by another caller: keep theirs
+	if el, ok := c.entries[query]; ok {
+		c.release(stmt)
+		c.order.MoveToFront(el)
+		return el.Value.(*stmtEntry[S]).stmt, nil
+	}
+
+	c.entries[query] = c.order.PushFront(&stmtEntry[S]{query: query, stmt: stmt})
+	for c.order.Len() > c.capacity {
+		c.evict(c.order.Back())
+	}
+	return stmt, nil
+}
+
+func (c *stmtCache[S]) lookup(query string) (S, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[query]
+	if !ok {
+		var zero S
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*stmtEntry[S]).stmt, true
+}
+
+func (c *stmtCache[S]) evict(el *list.Element) {
+	entry := el.Value.(*stmtEntry[S])
+	c.order.Remove(el)
+	delete(c.entries, entry.query)
+	c.release(entry.stmt)
+}
+
+// Len returns the number of cached statements
+func (c *stmtCache[S]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Close releases every cached statement
+func (c *stmtCache[S]) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.order.Len() > 0 {
+		c.evict(c.order.Back())
+	}
+}
+
+// newSQLStmtCache caches statements prepared on db, or returns nil when
+// capacity is zero. A statement in use by a transaction stays open until
+// the transaction ends, even if it is evicted meanwhile.
+func newSQLStmtCache(db *sql.DB, capacity int) *stmtCache[*sql.Stmt] {
+	if capacity <= 0 {
+		return nil
+	}
+	return newStmtCache(capacity, db.PrepareContext, func(stmt *sql.Stmt) { _ = stmt.Close() })
+}
+
+// cachedExec executes statements from cache inside tx, like txExec. A nil
+// cache executes the SQL directly.
+func cachedExec(ctx context.Context, tx *sql.Tx, cache *stmtCache[*sql.Stmt], timeout time.Duration) execFunc {
+	if cache == nil {
+		return txExec(ctx, tx, timeout)
+	}
+	return func(query string, args ...any) error {
+		ctx, cancel := withTimeout(ctx, timeout)
+		defer cancel()
+
+		stmt, err := cache.Get(ctx, query)
+		if err != nil {
+			return err
+		}
+		_, err = tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+		return err
+	}
+}
@@ -0,0 +1,101 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// fakeStmts prepares statements as "stmt:<sql>" and records releases
+type fakeStmts struct {
+	mu       sync.Mutex
+	prepared []string
+	released []string
+}
+
+func (f *fakeStmts) prepare(_ context.Context, query string) (string, error) {
+	if query == "BAD" {
+		return "", errors.New("syntax error")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prepared = append(f.prepared, query)
+	return "stmt:" + query, nil
+}
+
+func (f *fakeStmts) release(stmt string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.released = append(f.released, stmt)
+}
+
+func TestStmtCache_LRU(t *testing.T) {
+	f := &fakeStmts{}
+	c := newStmtCache(2, f.prepare, f.release)
+
+	for _, q := range []string{"A", "B", "A", "C", "A", "B"} {
+		stmt, err := c.Get(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", q, err)
+		}
+		if stmt != "stmt:"+q {
+			t.Errorf("Get(%s) = %s", q, stmt)
+		}
+	}
+
+	// C evicts B (A was used more recently), then B evicts C
+	if want := []string{"A", "B", "C", "B"}; !slices.Equal(f.prepared, want) {
+		t.Errorf("prepared = %v, want %v", f.prepared, want)
+	}
+	if want := []string{"stmt:B", "stmt:C"}; !slices.Equal(f.released, want) {
+		t.Errorf("released = %v, want %v", f.released, want)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+
+	c.Close()
+	if c.Len() != 0 || len(f.released) != 4 {
+		t.Errorf("after Close: Len() = %d, released = %v", c.Len(), f.released)
+	}
+}
+
+func TestStmtCache_PrepareError(t *testing.T) {
+	f := &fakeStmts{}
+	c := newStmtCache(2, f.prepare, f.release)
+
+	if _, err := c.Get(context.Background(), "BAD"); err == nil {
+		t.Fatal("Get() should return the prepare error")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d, want failed statement not cached", c.Len())
+	}
+}
+
+func TestStmtCache_Concurrent(t *testing.T) {
+	f := &fakeStmts{}
+	c := newStmtCache(4, f.prepare, f.release)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for j := range 100 {
+				if _, err := c.Get(context.Background(), fmt.Sprintf("Q%d", (i+j)%6)); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	if c.Len() > 4 {
+		t.Errorf("Len() = %d, want at most 4", c.Len())
+	}
+	// Every statement prepared was either released or is still cached
+	if got := len(f.prepared) - len(f.released); got != c.Len() {
+		t.Errorf("prepared %d, released %d, cached %d", len(f.prepared), len(f.released), c.Len())
+	}
+}
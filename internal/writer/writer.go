@@ -1,13 +1,17 @@
 package writer
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
 // Writer defines the interface for database writers.
 // Both MySQL and PostgreSQL writers implement this interface.
 type Writer interface {
 	// ExecuteBatch executes multiple queries in a single transaction with retry logic.
-	// Handles deadlocks by retrying with exponential backoff.
-	ExecuteBatch(queries []Query) error
+	// Handles deadlocks by retrying with exponential backoff. Cancelling ctx
+	// abandons the batch, including a pending retry; the transaction is rolled back.
+	ExecuteBatch(ctx context.Context, queries []Query) error
 
 	// Ping verifies the database connection is alive.
 	Ping() error
@@ -20,6 +24,17 @@ type Writer interface {
 	DB() *sql.DB
 }
 
+// OffsetStore persists applied Kafka offsets in the target database.
+// Offsets are written in the same transaction as the batch, so a replay
+// after a crash can resume exactly after the last applied event.
+type OffsetStore interface {
+	// EnsureOffsetsTable creates the offsets table if it does not exist.
+	EnsureOffsetsTable() error
+
+	// LoadOffsets returns the last applied offset by topic and partition.
+	LoadOffsets() (map[string]map[int32]int64, error)
+}
+
 // Query represents a single database operation.
 // Used by both MySQL and PostgreSQL writers.
 type Query struct {
@@ -27,4 +42,39 @@ type Query struct {
 	Args  []any
 	Table string
 	Op    string
+
+	// Kafka position of the source event, recorded in the offsets table
+	// when exactly-once delivery is enabled
+	Topic     string
+	Partition int32
+	Offset    int64
+
+	// Source event time in epoch milliseconds (__ts_ms), used for
+	// replication latency metrics
+	Timestamp int64
+
+	// Rows is the number of events a multi-row statement applies; 0 means one
+	Rows int
+
+	// Copy holds the statement's rows for bulk loading. Set on snapshot
+	// inserts so a writer can load long runs of them with COPY instead of
+	// executing SQL; nil otherwise.
+	Copy *CopyRows
+}
+
+// CopyRows is an upsert in structured form. Identifiers are unquoted
+// target names.
+type CopyRows struct {
+	Table       string
+	Columns     []string
+	PrimaryKeys []string
+	Rows        [][]any
+}
+
+// EventCount returns how many events the query applies
+func (q Query) EventCount() int {
+	if q.Rows > 0 {
+		return q.Rows
+	}
+	return 1
 }